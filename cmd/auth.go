@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+	"todoapp/internal/store"
+)
+
+// tokenCommand holds the flags that manage the tokens of an auth file.
+type tokenCommand struct {
+	mint   string // subject of a token to mint (-mint-token)
+	scope  string
+	ttl    time.Duration
+	revoke string // ID of a token to revoke (-revoke-token)
+	list   bool   // -tokens
+}
+
+func (c tokenCommand) given() bool {
+	return c.mint != "" || c.revoke != "" || c.list
+}
+
+// handleTokens mints, revokes or lists the tokens of the auth file at
+// “path”, creating it with a new signing key on the first -mint-token.
+func handleTokens(path string, cmd tokenCommand, traceID string) {
+	if path == "" {
+		slog.Error("-mint-token, -revoke-token and -tokens need -auth-file", "traceID", traceID)
+		os.Exit(2)
+	}
+	config, err := store.LoadAuthConfig(path)
+	if errors.Is(err, fs.ErrNotExist) && cmd.mint != "" {
+		config, err = &store.AuthConfig{}, nil
+	}
+	if err != nil {
+		slog.Error("Failed to load auth file", "file", path, "error", err, "traceID", traceID)
+		os.Exit(1)
+	}
+	switch {
+	case cmd.mint != "":
+		token, claims, err := config.MintToken(cmd.mint, cmd.scope, cmd.ttl)
+		if err == nil {
+			err = config.Save(path)
+		}
+		if err != nil {
+			slog.Error("Failed to mint token", "subject", cmd.mint, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		fmt.Printf("Minted %s token %s for %s%s\n%s\n", claims.Scope, claims.ID, claims.Subject, expiry(claims), token)
+	case cmd.revoke != "":
+		err := config.Revoke(cmd.revoke)
+		if err == nil {
+			err = config.Save(path)
+		}
+		if err != nil {
+			slog.Error("Failed to revoke token", "id", cmd.revoke, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		fmt.Printf("Revoked token %s\n", cmd.revoke)
+	default:
+		printTokens(config)
+	}
+}
+
+// printTokens lists the API keys and minted tokens of an auth file.
+func printTokens(config *store.AuthConfig) {
+	revoked := map[string]bool{}
+	for _, id := range config.Revoked {
+		revoked[id] = true
+	}
+	fmt.Println("API keys:")
+	for _, k := range config.APIKeys {
+		fmt.Printf("  %s (%s)\n", k.Name, k.Scope)
+	}
+	fmt.Println("Tokens:")
+	for _, t := range config.Tokens {
+		state := ""
+		if revoked[t.ID] {
+			state = ", revoked"
+		}
+		fmt.Printf("  %s %s (%s%s%s)\n", t.ID, t.Subject, t.Scope, expiry(t), state)
+	}
+}
+
+// expiry describes when a token expires, or "" if it does not.
+func expiry(t store.TokenClaims) string {
+	if t.ExpiresAt == 0 {
+		return ""
+	}
+	return ", expires " + time.Unix(t.ExpiresAt, 0).Format("2006-01-02 15:04")
+}
@@ -0,0 +1,610 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+	"todoapp/internal/store"
+)
+
+// cliCommand holds the flags that select what a non-server run does.
+type cliCommand struct {
+	create    store.CreateRequest // used when Description is set (-add)
+	update    store.UpdateRequest // applied to every item of updateIDs
+	updateIDs []store.ItemRef     // -update-id
+	move      store.MoveRequest   // used when ID is set (-move-id)
+	deleteIDs []store.ItemRef     // -delete-id
+	restoreID store.ItemRef
+	trash     bool // list the trash
+	historyID store.ItemRef
+	ready     bool
+	search    string // words to search for (-search)
+	undo      int    // steps to undo (-undo)
+	redo      int    // steps to redo (-redo)
+	// query selects what the listing shows: -list, -status, -text and so on.
+	query      store.Query
+	showLists  bool
+	newList    string
+	deleteList string
+	// webhook is registered when URL is set (-add-webhook).
+	webhook       store.WebhookRequest
+	showWebhooks  bool
+	deleteWebhook string
+	// user is created when ID is set (-add-user).
+	user       store.UserRequest
+	showUsers  bool
+	deleteUser string
+	// dependency is set by -block-id or -unblock-id.
+	dependency struct {
+		id       store.ItemRef
+		blockers []store.ItemRef
+		remove   bool
+	}
+}
+
+// stepsFlag is a flag that may be given bare or with a count: "-undo" is
+// one step, "-undo=3" three.
+type stepsFlag int
+
+func (f *stepsFlag) String() string {
+	if f == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*f))
+}
+
+func (f *stepsFlag) Set(s string) error {
+	if s == "true" {
+		*f = 1
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return errors.New("expected a positive number of steps")
+	}
+	*f = stepsFlag(n)
+	return nil
+}
+
+func (f *stepsFlag) IsBoolFlag() bool { return true }
+
+// printUndone reports what -undo or -redo did when asked for “asked” steps.
+func printUndone(verb string, done []store.UndoStep, asked int) {
+	for _, step := range done {
+		fmt.Printf("%s: %s\n", verb, step.Summary())
+	}
+	if len(done) < asked {
+		fmt.Printf("%s %d of %d steps\n", verb, len(done), asked)
+	}
+}
+
+// parseRefs splits a comma-separated list of item numbers or UUIDs.
+func parseRefs(s string) []store.ItemRef {
+	var refs []store.ItemRef
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			refs = append(refs, store.ItemRef(f))
+		}
+	}
+	return refs
+}
+
+// resolve looks up an item given on the command line by number or UUID,
+// exiting if there is no such item.
+func resolve(actor *store.ToDoActor, ref store.ItemRef, traceID string) int {
+	id, err := actor.Resolve(ref)
+	if err != nil {
+		slog.Error("Unknown item", "item", ref, "error", err, "traceID", traceID)
+		os.Exit(1)
+	}
+	return id
+}
+
+// printLists shows every list with how many items it holds.
+func printLists(lists []store.ListSummary) {
+	fmt.Println("Lists:")
+	for _, l := range lists {
+		fmt.Printf("  %s (items: %d", l.Name, l.Items)
+		if l.Trashed > 0 {
+			fmt.Printf(", in the trash: %d", l.Trashed)
+		}
+		fmt.Println(")")
+	}
+}
+
+// printWebhooks shows the registered webhooks and the events they get.
+func printWebhooks(hooks []store.Webhook) {
+	if len(hooks) == 0 {
+		fmt.Println("No webhooks.")
+		return
+	}
+	fmt.Println("Webhooks:")
+	for _, h := range hooks {
+		events := "all events"
+		if len(h.Events) > 0 {
+			events = strings.Join(h.Events, ", ")
+		}
+		fmt.Printf("  %s %s (%s)\n", h.ID, h.URL, events)
+	}
+}
+
+// printWebhook reports a new webhook with its secret, which is not shown
+// again.
+func printWebhook(h store.Webhook) {
+	fmt.Printf("Added webhook %s for %s\nSecret (keep it, it is not shown again): %s\n", h.ID, h.URL, h.Secret)
+}
+
+// printUsers shows the user accounts.
+func printUsers(users []store.User) {
+	if len(users) == 0 {
+		fmt.Println("No users.")
+		return
+	}
+	fmt.Println("Users:")
+	for _, u := range users {
+		fmt.Printf("  %s", u.ID)
+		if u.Name != "" {
+			fmt.Printf(" (%s)", u.Name)
+		}
+		if u.Admin {
+			fmt.Print(" admin")
+		}
+		fmt.Println()
+	}
+}
+
+// printSearch shows the results of -search.
+func printSearch(results []store.SearchResult) {
+	if len(results) == 0 {
+		fmt.Println("No matching items.")
+		return
+	}
+	fmt.Println("Matching items, best first:")
+	for _, r := range results {
+		fmt.Printf("  [%d] %s (status: %s, score: %.2f, matched: %s)\n", r.ID, r.Description, r.Status, r.Score, strings.Join(r.Matches, ", "))
+	}
+}
+
+// printReady lists items that can be worked on now, in dependency order.
+func printReady(items []store.Item) {
+	if len(items) == 0 {
+		fmt.Println("Nothing is ready to work on.")
+		return
+	}
+	fmt.Println("Ready to work on:")
+	for _, it := range items {
+		fmt.Printf("  [%d] %s\n", it.ID, it.Description)
+	}
+}
+
+// printTrashed reports the items a -delete-id batch moved to the trash.
+func printTrashed(results []store.BatchResult) {
+	for _, r := range results {
+		fmt.Printf("Moved item %d to the trash (undo with -restore-id=%d)\n", r.IDs[0], r.IDs[0])
+		if len(r.IDs) > 1 {
+			fmt.Printf("Moved %d subtasks to the trash\n", len(r.IDs)-1)
+		}
+	}
+}
+
+// printTrash lists the items in the trash and when they will be purged.
+func printTrash(items []store.Item, retention time.Duration) {
+	var trashed []store.Item
+	for _, it := range items {
+		if !it.DeletedAt.IsZero() {
+			trashed = append(trashed, it)
+		}
+	}
+	if len(trashed) == 0 {
+		fmt.Println("The trash is empty.")
+		return
+	}
+	fmt.Println("Trash:")
+	for _, it := range trashed {
+		fmt.Printf("  [%d] %s (deleted: %s", it.ID, it.Description, it.DeletedAt.Format(time.RFC3339))
+		if retention > 0 {
+			fmt.Printf(", purged after: %s", it.DeletedAt.Add(retention).Format(time.RFC3339))
+		}
+		fmt.Println(")")
+	}
+}
+
+// printHistory lists the recorded changes to item “id”, as it was given.
+func printHistory(id store.ItemRef, changes []store.Change) {
+	if len(changes) == 0 {
+		fmt.Printf("No recorded changes for item %s.\n", id)
+		return
+	}
+	fmt.Printf("History of item %s:\n", id)
+	for _, c := range changes {
+		who := c.Actor
+		if who == "" {
+			who = "unknown"
+		}
+		var what string
+		switch c.Field {
+		case store.FieldCreated:
+			what = "created as " + historyValue(c.New)
+		case store.FieldPurged:
+			what = "purged from the trash"
+		default:
+			what = fmt.Sprintf("%s: %s → %s", c.Field, historyValue(c.Old), historyValue(c.New))
+		}
+		fmt.Printf("  %s  %-20s %s  (trace %s)\n", c.At.Format(time.RFC3339), who, what, c.TraceID)
+	}
+}
+
+// historyValue formats a recorded JSON value on one line.
+func historyValue(v []byte) string {
+	if len(v) == 0 {
+		return "(unset)"
+	}
+	var b bytes.Buffer
+	if err := json.Compact(&b, v); err != nil {
+		return string(v)
+	}
+	return b.String()
+}
+
+// cliIdentity names the person running the CLI for the history, as "cli:" and their login.
+func cliIdentity() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return "cli:" + u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return "cli:" + name
+	}
+	return "cli"
+}
+
+// splitTags splits a comma-separated -tags value; normalization happens in the store.
+func splitTags(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+func handleCLI(actor *store.ToDoActor, ctx context.Context, cmd cliCommand, retention time.Duration, traceID string) {
+	switch {
+	case cmd.create.Description != "":
+		in, err := cmd.create.Input()
+		var item store.Item
+		if err == nil {
+			in.ParentID = resolve(actor, cmd.create.ParentID, traceID)
+			item, err = actor.CreateItem(ctx, in)
+		}
+		if err != nil {
+			slog.Error("Failed to add item", "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		fmt.Printf("Added: [%d] %s (uuid: %s)\n", item.ID, item.Description, item.UUID)
+	case len(cmd.updateIDs) > 0:
+		// Several items are updated as one batch: all of them or none.
+		patch, err := cmd.update.Patch()
+		if err == nil && patch.Empty() {
+			err = errors.New("nothing to update; give -update-text, -update-status, -reopen, -priority, -due, -tags, -repeat or -assign")
+		}
+		var results []store.BatchResult
+		if err == nil {
+			ops := make([]store.BatchOp, len(cmd.updateIDs))
+			for i, ref := range cmd.updateIDs {
+				ops[i] = store.BatchOp{Op: store.OpUpdate, ID: resolve(actor, ref, traceID), Patch: patch}
+			}
+			results, err = actor.Batch(ctx, ops)
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			slog.Error("No item to update", "ids", cmd.updateIDs, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		if err != nil {
+			slog.Error("Failed to update items", "ids", cmd.updateIDs, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		next := actor.GetItems()
+		for _, r := range results {
+			fmt.Printf("Updated: [%d] %s (status: %s)\n", r.Item.ID, r.Item.Description, r.Item.Status)
+			for _, it := range next {
+				if it.PreviousID == r.Item.ID && it.CompletedAt.IsZero() {
+					fmt.Printf("Next occurrence: [%d] due %s\n", it.ID, it.DueAt.Format(time.DateOnly))
+				}
+			}
+		}
+	case !cmd.move.ID.IsZero():
+		id, parentID := resolve(actor, cmd.move.ID, traceID), resolve(actor, cmd.move.ParentID, traceID)
+		item, err := actor.MoveItemToList(ctx, id, cmd.move.List, parentID)
+		if err != nil {
+			slog.Error("Failed to move item", "id", id, "parent", parentID, "list", cmd.move.List, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		fmt.Printf("Moved: [%d] under [%d] in list %s\n", item.ID, item.ParentID, item.List)
+	case !cmd.dependency.id.IsZero():
+		id := resolve(actor, cmd.dependency.id, traceID)
+		var item store.Item
+		var err error
+		for _, ref := range cmd.dependency.blockers {
+			b := resolve(actor, ref, traceID)
+			if cmd.dependency.remove {
+				item, err = actor.RemoveDependency(ctx, id, b)
+			} else {
+				item, err = actor.AddDependency(ctx, id, b)
+			}
+			if err != nil {
+				slog.Error("Failed to change dependency", "id", id, "blocker", b, "error", err, "traceID", traceID)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Updated dependencies: [%d] blocked by %v\n", item.ID, item.BlockedBy)
+	case cmd.ready:
+		printReady(actor.Ready())
+	case len(cmd.deleteIDs) > 0:
+		ops := make([]store.BatchOp, len(cmd.deleteIDs))
+		for i, ref := range cmd.deleteIDs {
+			ops[i] = store.BatchOp{Op: store.OpDelete, ID: resolve(actor, ref, traceID)}
+		}
+		results, err := actor.Batch(ctx, ops)
+		if errors.Is(err, store.ErrNotFound) {
+			slog.Error("No item to delete", "ids", cmd.deleteIDs, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		if err != nil {
+			slog.Error("Failed to delete items", "ids", cmd.deleteIDs, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		printTrashed(results)
+	case !cmd.restoreID.IsZero():
+		id := resolve(actor, cmd.restoreID, traceID)
+		ids, err := actor.RestoreItem(ctx, id)
+		if err != nil {
+			slog.Error("Failed to restore item", "id", id, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		fmt.Printf("Restored item %d\n", id)
+		if len(ids) > 1 {
+			fmt.Printf("Restored %d subtasks\n", len(ids)-1)
+		}
+	case cmd.trash:
+		printTrash(actor.GetAllItems(), retention)
+	case !cmd.historyID.IsZero():
+		id := resolve(actor, cmd.historyID, traceID)
+		changes, err := actor.History(ctx, id)
+		if err != nil {
+			slog.Error("Failed to get history", "id", id, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		printHistory(cmd.historyID, changes)
+	case cmd.undo != 0 || cmd.redo != 0:
+		verb, steps, undo := "Undid", cmd.undo, actor.Undo
+		if cmd.redo != 0 {
+			verb, steps, undo = "Redid", cmd.redo, actor.Redo
+		}
+		done, err := undo(ctx, steps)
+		if err != nil && len(done) == 0 {
+			slog.Error("Failed to undo or redo", "steps", steps, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		if err != nil {
+			slog.Warn("Stopped before all steps were done", "error", err, "traceID", traceID)
+		}
+		printUndone(verb, done, steps)
+	case cmd.search != "":
+		results, err := actor.Search(cmd.search, cmd.query.List, cmd.query.Limit)
+		if err != nil {
+			slog.Error("Failed to search", "q", cmd.search, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		printSearch(results)
+	case cmd.showLists:
+		printLists(actor.Lists())
+	case cmd.newList != "":
+		list, err := actor.CreateList(ctx, cmd.newList)
+		if err != nil {
+			slog.Error("Failed to create list", "name", cmd.newList, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		fmt.Printf("Created list %s\n", list.Name)
+	case cmd.deleteList != "":
+		if err := actor.DeleteList(ctx, cmd.deleteList); err != nil {
+			slog.Error("Failed to delete list", "name", cmd.deleteList, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted list %s\n", cmd.deleteList)
+	case cmd.webhook.URL != "":
+		hook, err := actor.CreateWebhook(ctx, store.Webhook{URL: cmd.webhook.URL, Events: cmd.webhook.Events, Secret: cmd.webhook.Secret})
+		if err != nil {
+			slog.Error("Failed to add webhook", "url", cmd.webhook.URL, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		printWebhook(hook)
+	case cmd.showWebhooks:
+		printWebhooks(actor.Webhooks())
+	case cmd.deleteWebhook != "":
+		if err := actor.DeleteWebhook(ctx, cmd.deleteWebhook); err != nil {
+			slog.Error("Failed to delete webhook", "id", cmd.deleteWebhook, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted webhook %s\n", cmd.deleteWebhook)
+	case cmd.user.ID != "":
+		user, err := actor.CreateUser(ctx, store.User{ID: cmd.user.ID, Name: cmd.user.Name, Admin: cmd.user.Admin})
+		if err != nil {
+			slog.Error("Failed to add user", "id", cmd.user.ID, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		fmt.Printf("Added user %s\n", user.ID)
+	case cmd.showUsers:
+		printUsers(actor.Users())
+	case cmd.deleteUser != "":
+		if err := actor.DeleteUser(ctx, cmd.deleteUser); err != nil {
+			slog.Error("Failed to delete user", "id", cmd.deleteUser, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted user %s\n", cmd.deleteUser)
+	default:
+		page, err := actor.Query(cmd.query)
+		if err != nil {
+			slog.Error("Failed to show list", "list", cmd.query.List, "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+		printPage(ctx, cmd.query, page)
+	}
+}
+
+// printPage prints a page of the listing and, if there are more, how to
+// get the next one.
+func printPage(ctx context.Context, q store.Query, page store.Page) {
+	store.PrintTree(ctx, page.Items)
+	switch {
+	case page.Next == "":
+	case q.Offset > 0:
+		fmt.Printf("Showing %d of %d; next page: -offset=%d\n", len(page.Items), page.Total, q.Offset+len(page.Items))
+	default:
+		fmt.Printf("Showing %d of %d; next page: -cursor=%s\n", len(page.Items), page.Total, page.Next)
+	}
+}
+
+// proxyCLI runs a CLI command against the API server that owns the store.
+func proxyCLI(client *store.Client, ctx context.Context, cmd cliCommand, traceID string) {
+	var err error
+	switch {
+	case cmd.create.Description != "":
+		var item store.Item
+		if item, err = client.AddItem(ctx, cmd.create); err == nil {
+			fmt.Printf("Added: [%d] %s (uuid: %s)\n", item.ID, item.Description, item.UUID)
+		}
+	case len(cmd.updateIDs) > 0:
+		ops := make([]store.BatchOpRequest, len(cmd.updateIDs))
+		for i, ref := range cmd.updateIDs {
+			ops[i] = store.BatchOpRequest{Op: store.OpUpdate, UpdateRequest: cmd.update}
+			ops[i].ID = ref
+		}
+		var results []store.BatchResult
+		if results, err = client.Batch(ctx, ops); err == nil {
+			for _, r := range results {
+				fmt.Printf("Updated: [%d] %s (status: %s)\n", r.Item.ID, r.Item.Description, r.Item.Status)
+			}
+		}
+	case !cmd.move.ID.IsZero():
+		var item store.Item
+		if item, err = client.MoveItem(ctx, cmd.move); err == nil {
+			fmt.Printf("Moved: [%d] under [%d] in list %s\n", item.ID, item.ParentID, item.List)
+		}
+	case !cmd.dependency.id.IsZero():
+		for _, b := range cmd.dependency.blockers {
+			if cmd.dependency.remove {
+				err = client.Unblock(ctx, cmd.dependency.id, b)
+			} else {
+				err = client.Block(ctx, cmd.dependency.id, b)
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			fmt.Printf("Updated dependencies: [%s]\n", cmd.dependency.id)
+		}
+	case cmd.ready:
+		var items []store.Item
+		if items, err = client.Ready(ctx); err == nil {
+			printReady(items)
+		}
+	case len(cmd.deleteIDs) > 0:
+		ops := make([]store.BatchOpRequest, len(cmd.deleteIDs))
+		for i, ref := range cmd.deleteIDs {
+			ops[i] = store.BatchOpRequest{Op: store.OpDelete}
+			ops[i].ID = ref
+		}
+		var results []store.BatchResult
+		if results, err = client.Batch(ctx, ops); err == nil {
+			printTrashed(results)
+		}
+	case !cmd.restoreID.IsZero():
+		var item store.Item
+		if item, err = client.RestoreItem(ctx, cmd.restoreID); err == nil {
+			fmt.Printf("Restored item %d\n", item.ID)
+		}
+	case cmd.trash:
+		var items []store.Item
+		if items, err = client.GetAllItems(ctx); err == nil {
+			printTrash(items, 0)
+		}
+	case !cmd.historyID.IsZero():
+		var changes []store.Change
+		if changes, err = client.History(ctx, cmd.historyID); err == nil {
+			printHistory(cmd.historyID, changes)
+		}
+	case cmd.undo != 0:
+		var done []store.UndoStep
+		if done, err = client.Undo(ctx, cmd.undo); err == nil {
+			printUndone("Undid", done, cmd.undo)
+		}
+	case cmd.redo != 0:
+		var done []store.UndoStep
+		if done, err = client.Redo(ctx, cmd.redo); err == nil {
+			printUndone("Redid", done, cmd.redo)
+		}
+	case cmd.search != "":
+		var results []store.SearchResult
+		if results, err = client.Search(ctx, cmd.search, cmd.query.List, cmd.query.Limit); err == nil {
+			printSearch(results)
+		}
+	case cmd.showLists:
+		var lists []store.ListSummary
+		if lists, err = client.Lists(ctx); err == nil {
+			printLists(lists)
+		}
+	case cmd.newList != "":
+		var list store.List
+		if list, err = client.CreateList(ctx, cmd.newList); err == nil {
+			fmt.Printf("Created list %s\n", list.Name)
+		}
+	case cmd.deleteList != "":
+		if err = client.DeleteList(ctx, cmd.deleteList); err == nil {
+			fmt.Printf("Deleted list %s\n", cmd.deleteList)
+		}
+	case cmd.webhook.URL != "":
+		var hook store.Webhook
+		if hook, err = client.AddWebhook(ctx, cmd.webhook); err == nil {
+			printWebhook(hook)
+		}
+	case cmd.showWebhooks:
+		var hooks []store.Webhook
+		if hooks, err = client.Webhooks(ctx); err == nil {
+			printWebhooks(hooks)
+		}
+	case cmd.deleteWebhook != "":
+		if err = client.DeleteWebhook(ctx, cmd.deleteWebhook); err == nil {
+			fmt.Printf("Deleted webhook %s\n", cmd.deleteWebhook)
+		}
+	case cmd.user.ID != "":
+		var user store.User
+		if user, err = client.AddUser(ctx, cmd.user); err == nil {
+			fmt.Printf("Added user %s\n", user.ID)
+		}
+	case cmd.showUsers:
+		var users []store.User
+		if users, err = client.Users(ctx); err == nil {
+			printUsers(users)
+		}
+	case cmd.deleteUser != "":
+		if err = client.DeleteUser(ctx, cmd.deleteUser); err == nil {
+			fmt.Printf("Deleted user %s\n", cmd.deleteUser)
+		}
+	default:
+		var page store.Page
+		if page, err = client.QueryItems(ctx, cmd.query); err == nil {
+			printPage(ctx, cmd.query, page)
+		}
+	}
+	if err != nil {
+		slog.Error("Proxied command failed", "server", client.BaseURL, "error", err, "traceID", traceID)
+		os.Exit(1)
+	}
+}
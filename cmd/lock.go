@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+	"todoapp/internal/store"
+)
+
+// acquireLock takes the store lock according to “mode”: "fail" gives up at
+// once, "wait" and "proxy" poll for up to “timeout”. A proxying caller gets
+// the *store.LockedError back immediately when the owner is a server.
+func acquireLock(ctx context.Context, filePath string, owner store.LockOwner, mode string, timeout time.Duration) (*store.FileLock, error) {
+	lock, err := store.TryLock(filePath, owner)
+	var locked *store.LockedError
+	if mode == "fail" || !errors.As(err, &locked) {
+		return lock, err
+	}
+	if mode == "proxy" && locked.Owner != nil && locked.Owner.Server != "" {
+		return nil, err
+	}
+	traceID, _ := ctx.Value(store.TraceIDKey).(string)
+	slog.Info("Waiting for store lock", "file", filePath, "holder", locked.Error(), "timeout", timeout, "traceID", traceID)
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return store.Lock(waitCtx, filePath, owner, 50*time.Millisecond)
+}
+
+// serverURL turns a listen address such as ":8080" into a URL other processes can dial.
+func serverURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
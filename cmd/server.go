@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"todoapp/internal/store"
+)
+
+const templateHTML = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>ToDo List</title>
+    <style>
+        body { font-family: Arial, sans-serif; background: #f7f7f7; }
+        .container { max-width: 600px; margin: 40px auto; background: #fff; border-radius: 8px; box-shadow: 0 2px 8px rgba(0,0,0,0.08); padding: 32px; }
+        h1 { color: #3498db; }
+        h2 { color: #555; font-size: 1.1em; border-bottom: 1px solid #eee; padding-bottom: 4px; }
+        ul { padding-left: 1.2em; }
+        li { margin-bottom: 0.5em; }
+        .priority-high, .priority-urgent { color: #c0392b; font-weight: bold; }
+        .blocked { color: #e67e22; margin-left: 6px; font-size: 0.9em; }
+        .progress { color: #27ae60; margin-left: 6px; font-size: 0.9em; }
+        .trashed { color: #999; text-decoration: line-through; }
+        .list { color: #8e44ad; margin-left: 4px; font-size: 0.9em; }
+        mark { background: #fdf2a5; padding: 0 1px; }
+        .tag { background: #eaf2fb; color: #2c6ca3; border-radius: 4px; padding: 0 4px; margin-left: 4px; font-size: 0.9em; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>ToDo List</h1>
+        <form method="get" action="/list"><input type="search" name="q" value="{{.Query}}" placeholder="Search items"> <button>Search</button></form>
+        {{range .Groups}}
+        <h2>{{.Name}} <small>({{len .Items}})</small></h2>
+        <ul>
+            {{range .Items}}
+                <li style="margin-left: {{.Depth}}em"{{if not .DeletedAt.IsZero}} class="trashed" title="In the trash since {{.DeletedAt.Format "2006-01-02 15:04"}}"{{end}}>
+                    <strong>[{{.ID}}]</strong> {{highlight .Description $.Query}} 
+                    <em>(Status: {{.Status}}, Created: {{.CreatedAt.Format "2006-01-02 15:04"}}{{if not .DueAt.IsZero}}, Due: {{.DueAt.Format "2006-01-02"}}{{end}}{{with .Recurrence}}, Repeats: {{.}}{{end}})</em>
+                    {{if .Priority}}<span class="priority-{{.Priority}}">{{.Priority}}</span>{{end}}
+                    {{if and .List (ne .List "default")}}<span class="list">@{{.List}}</span>{{end}}
+                    {{range .Tags}}<span class="tag">#{{.}}</span>{{end}}
+                    {{with .BlockedBy}}<span class="blocked">blocked by {{range $i, $id := .}}{{if $i}}, {{end}}[{{$id}}]{{end}}</span>{{end}}
+                    {{with .Progress}}<span class="progress">{{.Done}}/{{.Total}} subtasks completed</span>{{end}}
+                    {{with .CreatedBy}}<span class="list">by {{.}}</span>{{end}}
+                </li>
+            {{end}}
+        </ul>
+        {{else}}
+        <p>{{if .Query}}No items match.{{else}}No items found.{{end}}</p>
+        {{end}}
+    </div>
+    <script>
+        // Reload when an item changes, once things have settled.
+        if (window.EventSource) {
+            let timer;
+            // Pass the page's access_token, if any, on to the stream.
+            const events = new EventSource("/events" + location.search);
+            for (const type of ["created", "updated", "deleted", "reset"]) {
+                events.addEventListener(type, () => {
+                    clearTimeout(timer);
+                    timer = setTimeout(() => location.reload(), 300);
+                });
+            }
+        }
+    </script>
+</body>
+</html>
+`
+
+// startAPIServer starts listening, loads the store with “open”, and serves
+// the API and the web frontend until a signal comes in. Until the store has
+// loaded only the probes and metrics answer; everything else gets 503. With
+// an “auth” everything but the static pages and the probes needs credentials.
+func startAPIServer(ctx context.Context, addr string, open func(*store.Metrics) (*store.ToDoActor, error), auth *store.Authenticator, webhooks store.WebhookConfig, traceID string, sigChan chan os.Signal) {
+	metrics := store.NewMetrics()
+	health := &store.Health{}
+	// mux serves the API and /list; it is set before loaded is closed.
+	var mux *http.ServeMux
+	loaded := make(chan struct{})
+	gate := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-loaded:
+			mux.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "The store is still loading", http.StatusServiceUnavailable)
+		}
+	})
+
+	root := http.NewServeMux()
+	root.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	root.HandleFunc("GET /healthz", health.Healthz)
+	root.HandleFunc("GET /readyz", health.Readyz)
+	if auth != nil {
+		root.Handle("GET /metrics", store.AuthMiddleware(auth, metrics))
+		root.Handle("/", store.AuthMiddleware(auth, gate))
+	} else {
+		root.Handle("GET /metrics", metrics)
+		root.Handle("/", gate)
+	}
+	// route names the pattern a request matches, for the metrics.
+	route := func(r *http.Request) string {
+		if _, pattern := root.Handler(r); pattern != "/" {
+			return pattern
+		}
+		select {
+		case <-loaded:
+			_, pattern := mux.Handler(r)
+			return pattern
+		default:
+			return ""
+		}
+	}
+	handler := metrics.Instrument(store.TraceIDMiddleware(store.IdentityMiddleware(root)), route)
+	server := &http.Server{Addr: addr, Handler: handler}
+
+	go func() {
+		slog.Info("Starting HTTP server", "addr", addr, "traceID", traceID)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("HTTP server error", "error", err, "traceID", traceID)
+			os.Exit(1)
+		}
+	}()
+
+	start := time.Now()
+	actor, err := open(metrics)
+	if err != nil {
+		slog.Error("Failed to load items", "error", err, "traceID", traceID)
+		os.Exit(1)
+	}
+	slog.Info("Loaded the store", "took", time.Since(start), "traceID", traceID)
+	// Event streams never go idle, so end them or Shutdown would wait for them.
+	server.RegisterOnShutdown(actor.CloseSubscriptions)
+
+	dispatcher := store.StartDispatcher(actor, webhooks)
+	api := &store.API{Actor: actor, Dispatcher: dispatcher}
+	mux = http.NewServeMux()
+	api.Routes(mux)
+	// /list?q= shows the search results for q, best first, with the
+	// matching words highlighted; otherwise it takes the /items parameters.
+	// Either way the items are grouped by assignee.
+	mux.HandleFunc("GET /list", func(w http.ResponseWriter, r *http.Request) {
+		search := r.URL.Query().Get("q")
+		q, err := store.ParseQuery(r.URL.Query())
+		q.User = store.UserFromContext(r.Context())
+		var page store.Page
+		switch {
+		case err != nil:
+		case search != "":
+			var results []store.SearchResult
+			results, err = actor.Search(search, q.List, q.Limit)
+			for _, res := range results {
+				page.Items = append(page.Items, store.ItemView{Item: res.Item})
+			}
+		default:
+			page, err = actor.Query(q)
+		}
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, store.ErrListNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		tmpl := template.Must(template.New("list").Funcs(template.FuncMap{"highlight": highlight}).Parse(templateHTML))
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data := struct {
+			Groups []assigneeGroup
+			Query  string
+		}{groupByAssignee(page.Items, actor.Users()), search}
+		if err := tmpl.Execute(w, data); err != nil {
+			http.Error(w, "Template error", http.StatusInternalServerError)
+		}
+	})
+
+	close(loaded)
+	health.SetActor(actor)
+	slog.Info("Ready to serve", "traceID", traceID)
+
+	<-sigChan
+	slog.Info("Interrupt received, shutting down server and flushing items...", "traceID", traceID)
+	ctxTimeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctxTimeout); err != nil {
+		slog.Error("Server shutdown error", "error", err, "traceID", traceID)
+	}
+	if err := dispatcher.Close(ctxTimeout); err != nil {
+		slog.Error("Webhook deliveries cut short", "error", err, "traceID", traceID)
+	}
+	if err := actor.Close(ctx); err != nil {
+		slog.Error("Failed to save items on interrupt", "error", err, "traceID", traceID)
+	} else {
+		slog.Info("Items saved successfully on interrupt", "traceID", traceID)
+	}
+}
+
+// assigneeGroup is the items of the /list page assigned to one user.
+type assigneeGroup struct {
+	Name  string
+	Items []store.ItemView
+}
+
+// groupByAssignee splits “items” by assignee, keeping their order within
+// each group. A subtask goes with its parent, so that subtrees stay whole
+// under the assignee of their root; one whose parent is not among “items”
+// starts a subtree of its own, at depth 0. Groups follow the order of
+// “users”, then come assignees whose account was removed, then the
+// unassigned items.
+func groupByAssignee(items []store.ItemView, users []store.User) []assigneeGroup {
+	type placement struct {
+		assignee string // of the subtree's root
+		depth    int
+	}
+	byID := map[string][]store.ItemView{}
+	placed := map[int]placement{}
+	for _, v := range items {
+		at := placement{v.AssigneeID, 0}
+		if parent, ok := placed[v.ParentID]; ok {
+			at = placement{parent.assignee, parent.depth + 1}
+		}
+		placed[v.ID] = at
+		v.Depth = at.depth
+		byID[at.assignee] = append(byID[at.assignee], v)
+	}
+	var groups []assigneeGroup
+	for _, u := range users {
+		if group, ok := byID[u.ID]; ok {
+			groups = append(groups, assigneeGroup{u.DisplayName(), group})
+			delete(byID, u.ID)
+		}
+	}
+	unassigned := byID[""]
+	delete(byID, "")
+	for _, id := range slices.Sorted(maps.Keys(byID)) {
+		groups = append(groups, assigneeGroup{id, byID[id]})
+	}
+	if len(unassigned) > 0 {
+		groups = append(groups, assigneeGroup{"Unassigned", unassigned})
+	}
+	return groups
+}
+
+// highlight escapes “text” for HTML and marks the words a search for
+// “query” matches.
+func highlight(text, query string) template.HTML {
+	var b strings.Builder
+	last := 0
+	for _, m := range store.MatchRanges(text, query) {
+		b.WriteString(template.HTMLEscapeString(text[last:m[0]]))
+		b.WriteString("<mark>" + template.HTMLEscapeString(text[m[0]:m[1]]) + "</mark>")
+		last = m[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+	return template.HTML(b.String())
+}
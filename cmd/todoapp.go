@@ -2,13 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"html/template"
 	"log/slog"
-	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 	"todoapp/internal/store"
@@ -16,146 +17,318 @@ import (
 	"github.com/google/uuid"
 )
 
-const templateHTML = `
-<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <title>ToDo List</title>
-    <style>
-        body { font-family: Arial, sans-serif; background: #f7f7f7; }
-        .container { max-width: 600px; margin: 40px auto; background: #fff; border-radius: 8px; box-shadow: 0 2px 8px rgba(0,0,0,0.08); padding: 32px; }
-        h1 { color: #3498db; }
-        ul { padding-left: 1.2em; }
-        li { margin-bottom: 0.5em; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <h1>ToDo List</h1>
-        <ul>
-            {{range .}}
-                <li>
-                    <strong>[{{.ID}}]</strong> {{.Description}} 
-                    <em>(Status: {{.Status}}, Created: {{.CreatedAt.Format "2006-01-02 15:04"}})</em>
-                </li>
-            {{else}}
-                <li>No items found.</li>
-            {{end}}
-        </ul>
-    </div>
-</body>
-</html>
-`
-
 func main() {
 
 	filePath := flag.String("file", "todos.json", "where to load/save the to-do list")
+	backend := flag.String("backend", "json", fmt.Sprintf("storage backend to use %v", store.Backends()))
+	journal := flag.Bool("journal", false, "append changes to a journal instead of rewriting the whole file (not with -start-server)")
+	compactEvery := flag.Int("compact-every", store.DefaultCompactEvery, "journal entries to accumulate before compacting into a snapshot")
 	addText := flag.String("add", "", "add a new to-do item")
-	updateID := flag.Int("update-id", 0, "the ID of the item you want to update")
+	updateID := flag.String("update-id", "", "the number or UUID of the item you want to update; give several, comma-separated, to update them all or none")
 	updateText := flag.String("update-text", "", "the new description for the item")
 	updateStatus := flag.String("update-status", "", "the new status for the item")
-	deleteID := flag.Int("delete-id", 0, "the ID of the item you want to delete")
+	reopen := flag.Bool("reopen", false, "with -update-id, move a completed item back to -update-status, or to the first status of the workflow")
+	workflowFile := flag.String("workflow", "", "JSON file declaring custom statuses and the allowed transitions between them")
+	deleteID := flag.String("delete-id", "", "the number or UUID of the item you want to move to the trash; give several, comma-separated, to delete them all or none")
+	restoreID := flag.String("restore-id", "", "the number or UUID of an item to take back out of the trash")
+	historyID := flag.String("history", "", "show who changed the item with this number or UUID, when, and what it was before")
+	var undoSteps, redoSteps stepsFlag
+	flag.Var(&undoSteps, "undo", "undo the last add, update, delete, restore, move or dependency change; -undo=N undoes the last N")
+	flag.Var(&redoSteps, "redo", "redo the last undone change; -redo=N redoes the last N")
+	list := flag.String("list", "", "the list to add to, move -move-id to, or show (default: add to the default list, show every list)")
+	showLists := flag.Bool("lists", false, "show every list with how many items it holds")
+	newList := flag.String("new-list", "", "create an empty list with this name")
+	deleteList := flag.String("delete-list", "", "delete the list with this name; it must have no items, even in the trash")
+	status := flag.String("status", "", "list only items with one of these comma-separated statuses")
+	text := flag.String("text", "", "list only items whose description or tags contain this text")
+	createdAfter := flag.String("created-after", "", "list only items created at or after this time (YYYY-MM-DD or RFC 3339)")
+	createdBefore := flag.String("created-before", "", "list only items created before this time (YYYY-MM-DD or RFC 3339)")
+	sortBy := flag.String("sort", "", "sort the listing by id, created, updated, due, priority, description or status instead of as a tree")
+	order := flag.String("order", "asc", "sort order for -sort: asc or desc")
+	limit := flag.Int("limit", 0, "list at most this many items (0 for all)")
+	offset := flag.Int("offset", 0, "skip this many items of the listing")
+	cursor := flag.String("cursor", "", "continue a listing where the previous page ended, as printed after it")
+	search := flag.String("search", "", "search the item descriptions for these words, best match first; words match the start of longer ones")
+	showTrash := flag.Bool("trash", false, "list the items in the trash")
+	trashRetention := flag.Duration("trash-retention", store.DefaultTrashRetention, "how long deleted items stay in the trash before they are purged (0 keeps them forever)")
+	priority := flag.String("priority", "", "priority for -add or -update-id: low, medium, high or urgent (empty clears it on update)")
+	due := flag.String("due", "", "due date for -add or -update-id, as YYYY-MM-DD or RFC 3339 (empty clears it on update)")
+	repeat := flag.String("repeat", "", "recurrence for -add or -update-id: daily[:N], weekly[:mon,thu], monthly[:DAY] or after:N (empty stops it on update)")
+	parentID := flag.String("parent", "", "number or UUID of the parent item for -add or -move-id (empty or 0 means top level)")
+	moveID := flag.String("move-id", "", "the number or UUID of the item to move under -parent")
+	blockID := flag.String("block-id", "", "the number or UUID of an item to mark as blocked by -blocked-by")
+	unblockID := flag.String("unblock-id", "", "the number or UUID of an item that is no longer blocked by -blocked-by")
+	blockedBy := flag.String("blocked-by", "", "comma-separated numbers or UUIDs of the blocking items for -block-id or -unblock-id")
+	force := flag.Bool("force", false, "with -update-status, start or complete an item even if its blockers are unfinished")
+	ready := flag.Bool("ready", false, "list not-started items whose blockers are all completed, in dependency order")
+	deletePolicy := flag.String("delete-policy", "refuse", "what deleting an item with subtasks does: refuse or cascade")
+	tags := flag.String("tags", "", "comma-separated tags for -add or -update-id (empty clears them on update)")
+	assign := flag.String("assign", "", "user ID to assign -add or -update-id to (empty unassigns on update)")
+	mine := flag.Bool("mine", false, "list only items you created or are assigned")
+	assignee := flag.String("assignee", "", "list only items assigned to this user ID, or to nobody with \"none\"")
+	createdBy := flag.String("created-by", "", "list only items created by this user ID")
+	addUser := flag.String("add-user", "", "create a user account with this ID; the first account is an admin")
+	userName := flag.String("user-name", "", "display name for -add-user")
+	admin := flag.Bool("admin", false, "make -add-user an admin, who may delete any item and manage the accounts")
+	showUsers := flag.Bool("users", false, "show the user accounts")
+	deleteUser := flag.String("delete-user", "", "remove the user account with this ID")
+	addWebhook := flag.String("add-webhook", "", "register a webhook that item changes are posted to, as JSON signed with HMAC-SHA256")
+	webhookEvents := flag.String("webhook-events", "", "comma-separated event types for -add-webhook: created, updated, deleted (default: all)")
+	webhookSecret := flag.String("webhook-secret", "", "signing secret for -add-webhook (default: generated and printed)")
+	showWebhooks := flag.Bool("webhooks", false, "show the registered webhooks")
+	deleteWebhook := flag.String("delete-webhook", "", "remove the webhook with this ID")
+	authFile := flag.String("auth-file", "", "JSON file of API keys and the token signing key; the server requires credentials when it is given")
+	mintToken := flag.String("mint-token", "", "mint a bearer token for this subject, signed with the key in -auth-file")
+	scope := flag.String("scope", store.ScopeRead, "scope of -mint-token: read or read-write")
+	tokenTTL := flag.Duration("token-ttl", 0, "how long -mint-token's token is valid (0 for no expiry)")
+	revokeToken := flag.String("revoke-token", "", "revoke the token with this ID in -auth-file")
+	showTokens := flag.Bool("tokens", false, "show the API keys and minted tokens of -auth-file")
+	token := flag.String("token", os.Getenv("TODOAPP_TOKEN"), "API key or token to send when forwarding a command to a server (default: $TODOAPP_TOKEN)")
 	serveAPI := flag.Bool("start-server", false, "Start HTTP API server")
+	addr := flag.String("addr", ":8080", "server mode: address to listen on")
+	lockMode := flag.String("lock-mode", "wait", "what to do when another process holds the file: wait, fail or proxy (forward the command to the server that owns it)")
+	lockTimeout := flag.Duration("lock-timeout", 5*time.Second, "how long -lock-mode=wait waits for the file")
+	flushInterval := flag.Duration("flush-interval", store.DefaultFlushInterval, "server mode: quiet period after a change before it is written to storage")
+	flushMaxDelay := flag.Duration("flush-max-delay", store.DefaultFlushMaxDelay, "server mode: longest a change may wait before it is written to storage")
+	webhookAttempts := flag.Int("webhook-attempts", 6, "server mode: tries per webhook delivery before it goes to the dead-letter log")
+	webhookBackoff := flag.Duration("webhook-backoff", time.Second, "server mode: wait before retrying a webhook delivery, doubled after each try")
+	webhookAllowPrivate := flag.Bool("webhook-allow-private", false, "server mode: let webhooks deliver to loopback, private and link-local addresses")
+	deadLetters := flag.String("dead-letters", "", "server mode: file failed webhook deliveries are appended to (default: -file with .dead-letters added)")
 	flag.Parse()
 
 	traceID := uuid.NewString()
 	ctx := context.WithValue(context.Background(), store.TraceIDKey, traceID)
+	ctx = context.WithValue(ctx, store.IdentityKey, cliIdentity())
+	// Flags that were given explicitly, so that an empty value can mean "clear".
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	// Tokens live in the auth file, not the store, so no lock is needed.
+	if tokens := (tokenCommand{mint: *mintToken, scope: *scope, ttl: *tokenTTL, revoke: *revokeToken, list: *showTokens}); tokens.given() {
+		handleTokens(*authFile, tokens, traceID)
+		return
+	}
 
-	items, err := store.LoadItems(ctx, *filePath)
+	cmd := cliCommand{
+		create: store.CreateRequest{
+			Description: *addText,
+			List:        *list,
+			Priority:    *priority,
+			DueAt:       *due,
+			Tags:        splitTags(*tags),
+			ParentID:    store.ItemRef(*parentID),
+			Recurrence:  *repeat,
+			AssigneeID:  *assign,
+		},
+		update: store.UpdateRequest{
+			Status: *updateStatus,
+			Reopen: *reopen,
+			Force:  *force,
+		},
+		move:       store.MoveRequest{ID: store.ItemRef(*moveID), ParentID: store.ItemRef(*parentID), List: *list},
+		updateIDs:  parseRefs(*updateID),
+		deleteIDs:  parseRefs(*deleteID),
+		restoreID:  store.ItemRef(*restoreID),
+		trash:      *showTrash,
+		historyID:  store.ItemRef(*historyID),
+		ready:      *ready,
+		search:     *search,
+		undo:       int(undoSteps),
+		redo:       int(redoSteps),
+		showLists:  *showLists,
+		newList:    *newList,
+		deleteList: *deleteList,
+		webhook: store.WebhookRequest{
+			URL:    *addWebhook,
+			Events: splitTags(*webhookEvents),
+			Secret: *webhookSecret,
+		},
+		showWebhooks:  *showWebhooks,
+		deleteWebhook: *deleteWebhook,
+		user:          store.UserRequest{ID: *addUser, Name: *userName, Admin: *admin},
+		showUsers:     *showUsers,
+		deleteUser:    *deleteUser,
+	}
+	query, err := store.ParseQuery(url.Values{
+		"list":           {*list},
+		"status":         {*status},
+		"text":           {*text},
+		"created_after":  {*createdAfter},
+		"created_before": {*createdBefore},
+		"created_by":     {*createdBy},
+		"assignee":       {*assignee},
+		"mine":           {strconv.FormatBool(*mine)},
+		"sort":           {*sortBy},
+		"order":          {*order},
+		"limit":          {strconv.Itoa(*limit)},
+		"offset":         {strconv.Itoa(*offset)},
+		"cursor":         {*cursor},
+	})
 	if err != nil {
-		slog.Error("Failed to load items", "file", *filePath, "error", err, "traceID", traceID)
-		os.Exit(1)
+		slog.Error("Invalid listing flags", "error", err, "traceID", traceID)
+		os.Exit(2)
+	}
+	query.User = store.UserFromContext(ctx)
+	cmd.query = query
+	switch {
+	case *blockID != "":
+		cmd.dependency.id = store.ItemRef(*blockID)
+	case *unblockID != "":
+		cmd.dependency.id, cmd.dependency.remove = store.ItemRef(*unblockID), true
+	}
+	if !cmd.dependency.id.IsZero() {
+		blockers := parseRefs(*blockedBy)
+		if len(blockers) == 0 {
+			slog.Error("-block-id and -unblock-id need -blocked-by with one or more item IDs", "blocked_by", *blockedBy, "traceID", traceID)
+			os.Exit(2)
+		}
+		cmd.dependency.blockers = blockers
+	}
+	if set["update-text"] {
+		cmd.update.Description = updateText
+	}
+	if set["priority"] {
+		cmd.update.Priority = priority
+	}
+	if set["due"] {
+		cmd.update.DueAt = due
+	}
+	if set["tags"] {
+		cmd.update.Tags = &cmd.create.Tags
+	}
+	if set["repeat"] {
+		cmd.update.Recurrence = repeat
+	}
+	if set["assign"] {
+		cmd.update.AssigneeID = assign
 	}
-	actor := store.NewToDoActor(items)
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	policy, err := store.ParseDeletePolicy(*deletePolicy)
+	if err != nil {
+		slog.Error("Invalid -delete-policy", "error", err, "traceID", traceID)
+		os.Exit(2)
+	}
+	workflow := store.DefaultWorkflow()
+	if *workflowFile != "" {
+		if workflow, err = store.LoadWorkflow(*workflowFile); err != nil {
+			slog.Error("Failed to load -workflow", "file", *workflowFile, "error", err, "traceID", traceID)
+			os.Exit(2)
+		}
+	}
 
-	if !*serveAPI {
-		handleCLI(actor, ctx, *filePath, *addText, *updateID, *updateText, *updateStatus, *deleteID, traceID)
+	switch *lockMode {
+	case "wait", "fail", "proxy":
+	default:
+		slog.Error("Invalid -lock-mode, expected wait, fail or proxy", "lock_mode", *lockMode, "traceID", traceID)
+		os.Exit(2)
+	}
+	// The server persists through write-behind, which saves whole snapshots,
+	// so a journal would never be appended to.
+	if *serveAPI && *journal {
+		slog.Error("-journal cannot be combined with -start-server; the server rewrites the whole file", "traceID", traceID)
+		os.Exit(2)
+	}
+	// Hold the lock for the whole load-mutate-save cycle (or, for a server, its lifetime).
+	owner := store.LockOwner{PID: os.Getpid()}
+	if *serveAPI {
+		owner.Server = serverURL(*addr)
+	}
+	lock, err := acquireLock(ctx, *filePath, owner, *lockMode, *lockTimeout)
+	var locked *store.LockedError
+	if errors.As(err, &locked) && !*serveAPI && *lockMode == "proxy" && locked.Owner != nil && locked.Owner.Server != "" {
+		slog.Info("Store is owned by a running server, forwarding command", "server", locked.Owner.Server, "traceID", traceID)
+		client := store.NewClient(locked.Owner.Server)
+		client.Token = *token
+		proxyCLI(client, ctx, cmd, traceID)
 		return
 	}
+	if err != nil {
+		slog.Error("Failed to lock store", "file", *filePath, "error", err, "traceID", traceID)
+		if locked != nil && locked.Owner != nil && locked.Owner.Server != "" {
+			fmt.Fprintf(os.Stderr, "%v\nStop the server, use its API, or rerun with -lock-mode=proxy.\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+		os.Exit(1)
+	}
+	defer lock.Unlock()
 
-	startAPIServer(actor, ctx, *filePath, traceID, sigChan)
-}
+	storage, err := store.OpenStorage(*backend, store.BackendOptions{
+		Path:         *filePath,
+		Journal:      *journal,
+		CompactEvery: *compactEvery,
+	})
+	if err != nil {
+		slog.Error("Failed to open storage", "backend", *backend, "file", *filePath, "error", err, "traceID", traceID)
+		os.Exit(1)
+	}
 
-func handleCLI(actor *store.ToDoActor, ctx context.Context, filePath, addText string, updateID int, updateText, updateStatus string, deleteID int, traceID string) {
-	switch {
-	case addText != "":
-		item := actor.AddItem(addText)
-		fmt.Printf("Added: [%d] %s\n", item.ID, item.Description)
-	case updateID != 0 && updateText != "":
-		if !actor.UpdateItem(updateID, updateText, "") {
-			slog.Error("No item to update", "id", updateID, "traceID", traceID)
+	if !*serveAPI {
+		snap, err := storage.Load(ctx)
+		if err != nil {
+			slog.Error("Failed to load items", "backend", *backend, "file", *filePath, "error", err, "traceID", traceID)
 			os.Exit(1)
 		}
-		fmt.Printf("Updated: [%d] %s\n", updateID, updateText)
-	case updateID != 0 && updateStatus != "":
-		if !actor.UpdateItem(updateID, "", updateStatus) {
-			slog.Error("No item to update", "id", updateID, "traceID", traceID)
-			os.Exit(1)
+		// Collect what the command changes so it can be written item by item.
+		var changes []store.JournalEntry
+		actor := store.NewToDoActor(snap.Items,
+			store.WithHistory(snap.History),
+			store.WithUndoLog(snap.Undo, snap.Redo),
+			store.WithLastID(snap.LastID),
+			store.WithLists(snap.Lists),
+			store.WithWebhooks(snap.Webhooks),
+			store.WithUsers(snap.Users),
+			store.WithDeletePolicy(policy),
+			store.WithWorkflow(workflow),
+			store.WithTrashRetention(*trashRetention),
+			store.WithChangeListener(func(e store.JournalEntry) { changes = append(changes, e) }))
+		handleCLI(actor, ctx, cmd, *trashRetention, traceID)
+		if len(changes) == 0 {
+			return
 		}
-		fmt.Printf("Updated status: [%d] %s\n", updateID, updateStatus)
-	case deleteID != 0:
-		if !actor.DeleteItem(deleteID) {
-			slog.Error("No item to delete", "id", deleteID, "traceID", traceID)
+		if err := storage.Apply(ctx, changes...); err != nil {
+			slog.Error("Failed to save items", "error", err, "traceID", traceID)
 			os.Exit(1)
 		}
-		fmt.Printf("Deleted item %d\n", deleteID)
-	default:
-		items := actor.GetItems()
-		store.PrintItems(ctx, items)
+		slog.Info("Saved changes to storage", "count", len(changes), "traceID", traceID)
+		return
 	}
 
-	// Save items on exit
-	items := actor.GetItems()
-	if err := store.SaveItems(ctx, filePath, items); err != nil {
-		slog.Error("Failed to save items", "file", filePath, "error", err, "traceID", traceID)
-		os.Exit(1)
-	}
-	slog.Info("Saved items to disk", "file", filePath, "count", len(items), "traceID", traceID)
-}
-
-func startAPIServer(actor *store.ToDoActor, ctx context.Context, filePath, traceID string, sigChan chan os.Signal) {
-	api := &store.API{Actor: actor}
-	mux := http.NewServeMux()
-	mux.HandleFunc("/create", api.Create)
-	mux.HandleFunc("/get", api.Get)
-	mux.HandleFunc("/update", api.Update)
-	mux.HandleFunc("/delete", api.Delete)
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
-	mux.HandleFunc("/list", func(w http.ResponseWriter, r *http.Request) {
-		items := actor.GetItems()
-		tmpl := template.Must(template.New("list").Parse(templateHTML))
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := tmpl.Execute(w, items); err != nil {
-			http.Error(w, "Template error", http.StatusInternalServerError)
+	// The server answers its probes while the store loads, so it is opened
+	// by startAPIServer.
+	open := func(metrics *store.Metrics) (*store.ToDoActor, error) {
+		snap, err := storage.Load(ctx)
+		if err != nil {
+			return nil, err
 		}
-	})
-
-	handler := store.TraceIDMiddleware(mux)
-	server := &http.Server{Addr: ":8080", Handler: handler}
-
-	go func() {
-		slog.Info("Starting HTTP server on :8080", "traceID", traceID)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("HTTP server error", "error", err, "traceID", traceID)
+		return store.NewToDoActor(snap.Items,
+			store.WithHistory(snap.History),
+			store.WithUndoLog(snap.Undo, snap.Redo),
+			store.WithLastID(snap.LastID),
+			store.WithLists(snap.Lists),
+			store.WithWebhooks(snap.Webhooks),
+			store.WithUsers(snap.Users),
+			store.WithDeletePolicy(policy),
+			store.WithWorkflow(workflow),
+			store.WithTrashRetention(*trashRetention),
+			store.WithMetrics(metrics),
+			store.WithWriteBehind(storage.Save, store.WriteBehindConfig{
+				Interval: *flushInterval,
+				MaxDelay: *flushMaxDelay,
+			})), nil
+	}
+	if *deadLetters == "" {
+		*deadLetters = *filePath + ".dead-letters"
+	}
+	var auth *store.Authenticator
+	if *authFile != "" {
+		if auth, err = store.NewAuthenticator(*authFile); err != nil {
+			slog.Error("Failed to load -auth-file", "file", *authFile, "error", err, "traceID", traceID)
 			os.Exit(1)
 		}
-	}()
-
-	<-sigChan
-	slog.Info("Interrupt received, shutting down server and saving items...", "traceID", traceID)
-	ctxTimeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := server.Shutdown(ctxTimeout); err != nil {
-		slog.Error("Server shutdown error", "error", err, "traceID", traceID)
-	}
-	items := actor.GetItems()
-	if err := store.SaveItems(ctx, filePath, items); err != nil {
-		slog.Error("Failed to save items on interrupt", "error", err, "traceID", traceID)
-	} else {
-		slog.Info("Items saved successfully on interrupt", "traceID", traceID)
 	}
+	webhooks := store.WebhookConfig{MaxAttempts: *webhookAttempts, Backoff: *webhookBackoff, DeadLetters: *deadLetters, AllowPrivate: *webhookAllowPrivate}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	startAPIServer(ctx, *addr, open, auth, webhooks, traceID, sigChan)
 }
@@ -1,101 +1,946 @@
 package store
 
 import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type actorMsg interface{}
 
+// itemReply carries a single item, or the reason there is none, back from the actor.
+type itemReply struct {
+	item Item
+	err  error
+}
+
 type getItemsMsg struct {
-	reply chan []Item
+	withTrash bool
+	reply     chan []Item
+}
+type getItemMsg struct {
+	id    int
+	reply chan itemReply
+}
+type findUUIDMsg struct {
+	uuid  string
+	reply chan itemReply
+}
+type createItemMsg struct {
+	by    origin
+	input ItemInput
+	reply chan itemReply
+}
+type patchItemMsg struct {
+	by    origin
+	id    int
+	patch ItemPatch
+	reply chan itemReply
 }
-type addItemMsg struct {
-	description string
-	reply       chan Item
+type moveItemMsg struct {
+	by       origin
+	id       int
+	list     string // "" keeps the item in its list, or moves it to the parent's
+	parentID int
+	reply    chan itemReply
 }
-type updateItemMsg struct {
-	id          int
-	description string
-	status      string
-	reply       chan bool
+type dependencyMsg struct {
+	by      origin
+	id      int
+	blocker int
+	remove  bool
+	reply   chan itemReply
+}
+type readyMsg struct {
+	reply chan []Item
 }
 type deleteItemMsg struct {
+	by        origin
+	id        int
+	ifVersion int // 0 for any version
+	reply     chan idsReply
+}
+type restoreItemMsg struct {
+	by    origin
+	id    int
+	reply chan idsReply
+}
+type purgeMsg struct {
+	by     origin
+	before time.Time
+	reply  chan []int
+}
+type idsReply struct {
+	ids []int // the item and the subtasks that went with it
+	err error
+}
+type historyMsg struct {
 	id    int
-	reply chan bool
+	reply chan historyReply
+}
+type historyReply struct {
+	changes []Change
+	err     error
+}
+type undoMsg struct {
+	by    origin
+	redo  bool
+	steps int
+	reply chan undoReply
+}
+type undoReply struct {
+	steps []UndoStep // the steps undone or redone, most recent first
+	err   error
+}
+type snapshotMsg struct {
+	reply chan Snapshot
+}
+type searchMsg struct {
+	query string
+	list  string // "" for every list
+	limit int    // 0 for every match
+	reply chan searchReply
+}
+type searchReply struct {
+	results []SearchResult
+	err     error
+}
+type queryMsg struct {
+	q     Query
+	reply chan queryReply
+}
+type queryReply struct {
+	page Page
+	err  error
+}
+type listsMsg struct {
+	reply chan []ListSummary
+}
+type createListMsg struct {
+	name  string
+	reply chan listReply
+}
+type deleteListMsg struct {
+	name  string
+	reply chan listReply
+}
+type listReply struct {
+	list List
+	err  error
 }
 
 type ToDoActor struct {
-	inbox chan actorMsg
-}
-
-func NewToDoActor(initial []Item) *ToDoActor {
-	inbox := make(chan actorMsg)
-	items := initial
-
-	go func() {
-		for msg := range inbox {
-			switch m := msg.(type) {
-			case getItemsMsg:
-				cp := make([]Item, len(items))
-				copy(cp, items)
-				m.reply <- cp
-			case addItemMsg:
-				newItem := Item{
-					ID:          nextID(items),
-					Description: m.description,
-					Status:      StatusNotStarted,
-					CreatedAt:   time.Now(),
-				}
-				items = append(items, newItem)
-				m.reply <- newItem
-			case updateItemMsg:
-				updated := false
-				for i := range items {
-					if items[i].ID == m.id {
-						if m.description != "" {
-							items[i].Description = m.description
-						}
-						if m.status != "" {
-							items[i].Status = m.status
-						}
-						updated = true
-						break
-					}
-				}
-				m.reply <- updated
-			case deleteItemMsg:
-				deleted := false
-				for i := range items {
-					if items[i].ID == m.id {
-						items = append(items[:i], items[i+1:]...)
-						deleted = true
-						break
-					}
-				}
-				m.reply <- deleted
+	inbox        chan actorMsg
+	persist      *writeBehind // nil unless WithWriteBehind was given
+	metrics      *Metrics     // nil unless WithMetrics was given
+	listener     func(JournalEntry)
+	deletePolicy DeletePolicy
+	workflow     *Workflow
+	retention    time.Duration // trash retention; 0 keeps trashed items forever
+	history      []Change      // initial history, see WithHistory
+	undo, redo   []UndoStep    // initial undo log, see WithUndoLog
+	lastID       int           // largest ID ever assigned, see WithLastID
+	lists        []List        // initial lists, see WithLists
+	webhooks     []Webhook     // initial webhooks, see WithWebhooks
+	users        []User        // initial accounts, see WithUsers
+	eventBuffer  int           // see WithEventBuffer
+	events       *eventLog
+	closed       chan struct{} // closed by Close
+	closeOnce    sync.Once
+}
+
+// ActorOption configures a ToDoActor.
+type ActorOption func(*ToDoActor)
+
+func NewToDoActor(initial []Item, opts ...ActorOption) *ToDoActor {
+	a := &ToDoActor{inbox: make(chan actorMsg), closed: make(chan struct{}), eventBuffer: DefaultEventBuffer}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.events = newEventLog(a.eventBuffer)
+	if a.workflow == nil {
+		a.workflow = DefaultWorkflow()
+	}
+	go a.run(initial)
+	if a.persist != nil {
+		go a.persist.run(a)
+	}
+	if a.retention > 0 {
+		go a.purgeLoop()
+	}
+	return a
+}
+
+// WithChangeListener makes the actor report every item it stores or
+// deletes to “fn”, in order. fn runs on the actor goroutine and must not
+// call back into the actor.
+func WithChangeListener(fn func(JournalEntry)) ActorOption {
+	return func(a *ToDoActor) {
+		a.listener = fn
+	}
+}
+
+// changed is called by the actor loop after every mutation with the
+// resulting writes, including the history records.
+func (a *ToDoActor) changed(entries ...JournalEntry) {
+	if a.persist != nil {
+		a.persist.markDirty()
+	}
+	if a.listener != nil {
+		for _, e := range entries {
+			a.listener(e)
+		}
+	}
+}
+
+func putEntry(it Item) JournalEntry {
+	return JournalEntry{Op: JournalPut, Item: &it}
+}
+
+// touch marks “it” as changed at “now”. Every write gives an item a new
+// UpdatedAt and a higher Version.
+func touch(it *Item, now time.Time) {
+	it.UpdatedAt = now
+	it.Version++
+}
+
+func deleteEntry(id int) JournalEntry {
+	return JournalEntry{Op: JournalDelete, ID: id}
+}
+
+// actorState is what the actor loop owns. Only the loop goroutine touches
+// it, through one method per message.
+type actorState struct {
+	a          *ToDoActor
+	items      []Item
+	history    []Change
+	undo, redo []UndoStep
+	lastID     int // largest ID ever assigned
+	lists      []List
+	webhooks   []Webhook
+	users      []User
+	index      *searchIndex
+	// tx is the batch being run, if any; its writes wait to be committed
+	// together.
+	tx *txn
+}
+
+func newActorState(a *ToDoActor, initial []Item) *actorState {
+	s := &actorState{
+		a:        a,
+		items:    initial,
+		history:  a.history,
+		undo:     trimSteps(a.undo),
+		redo:     a.redo,
+		lastID:   max(a.lastID, maxID(initial)),
+		lists:    initLists(a.lists, initial),
+		webhooks: slices.Clone(a.webhooks),
+		users:    slices.Clone(a.users),
+	}
+	// Items from files written before versions existed start at 1, so
+	// that every item has an ETag a client can send back.
+	for i := range s.items {
+		s.items[i].Version = max(s.items[i].Version, 1)
+	}
+	s.index = newSearchIndex(s.items)
+	return s
+}
+
+func (a *ToDoActor) run(initial []Item) {
+	s := newActorState(a, initial)
+	if a.retention > 0 {
+		s.purge(origin{actor: SystemIdentity, user: SystemIdentity}, time.Now().Add(-a.retention))
+	}
+	for msg := range a.inbox {
+		s.handle(msg)
+	}
+}
+
+func (s *actorState) hasList(name string) bool {
+	return slices.ContainsFunc(s.lists, func(l List) bool { return l.Name == name })
+}
+
+// newID hands out the next ID. IDs only ever grow, so an ID stays with its
+// item even after the item is purged.
+func (s *actorState) newID() int {
+	s.lastID++
+	return s.lastID
+}
+
+func (s *actorState) find(id int) int {
+	for i := range s.items {
+		if s.items[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// live is find for items that must not be in the trash.
+func (s *actorState) live(id int) (int, error) {
+	i := s.find(id)
+	switch {
+	case i < 0:
+		return -1, ErrNotFound
+	case !s.items[i].DeletedAt.IsZero():
+		return -1, ErrTrashed
+	}
+	return i, nil
+}
+
+func (s *actorState) exists(id int) bool {
+	_, err := s.live(id)
+	return err == nil
+}
+
+// record adds the history of one mutation to its writes and publishes its
+// events. “before” holds the previous version of every written item that
+// existed.
+func (s *actorState) record(by origin, before []Item, entries []JournalEntry, at time.Time) []JournalEntry {
+	s.index.apply(entries)
+	s.a.events.publish(itemEvents(by, before, entries, at))
+	changes := recordChanges(by, before, entries, at)
+	s.history = append(s.history, changes...)
+	for _, c := range changes {
+		entries = append(entries, historyEntry(c))
+	}
+	return entries
+}
+
+// commit records a mutation asked for by “by” in the history and the undo
+// log and reports its writes.
+func (s *actorState) commit(by origin, before []Item, entries ...JournalEntry) {
+	if s.tx != nil {
+		s.tx.add(before, entries)
+		return
+	}
+	now := time.Now()
+	step := newUndoStep(by, before, entries, now)
+	s.undo, s.redo = trimSteps(append(s.undo, step)), nil
+	entries = s.record(by, before, entries, now)
+	s.a.changed(append(entries, JournalEntry{Op: JournalStep, Step: &step})...)
+}
+
+// checkpoint returns a copy of the state that a batch can roll back to.
+// The history is only ever appended to, so its slice header is copy
+// enough; the search index is only written when a mutation is recorded,
+// which a batch does once it has succeeded.
+func (s *actorState) checkpoint() actorState {
+	c := *s
+	c.items = cloneItems(s.items)
+	c.undo, c.redo = slices.Clone(s.undo), slices.Clone(s.redo)
+	c.lists = slices.Clone(s.lists)
+	c.webhooks = slices.Clone(s.webhooks)
+	c.users = slices.Clone(s.users)
+	return c
+}
+
+// handle runs “msg”. A batch runs its operations through it too.
+func (s *actorState) handle(msg actorMsg) {
+	switch m := msg.(type) {
+	case getItemsMsg:
+		s.getItems(m)
+	case getItemMsg:
+		s.getItem(m)
+	case findUUIDMsg:
+		s.findUUID(m)
+	case historyMsg:
+		s.itemHistory(m)
+	case snapshotMsg:
+		s.snapshot(m)
+	case searchMsg:
+		s.search(m)
+	case queryMsg:
+		s.query(m)
+	case listsMsg:
+		s.summarizeLists(m)
+	case createListMsg:
+		s.createList(m)
+	case deleteListMsg:
+		s.deleteList(m)
+	case webhooksMsg:
+		s.listWebhooks(m)
+	case createWebhookMsg:
+		s.createWebhook(m)
+	case deleteWebhookMsg:
+		s.deleteWebhook(m)
+	case statusCountsMsg:
+		s.statusCounts(m)
+	case usersMsg:
+		s.listUsers(m)
+	case createUserMsg:
+		s.createUser(m)
+	case deleteUserMsg:
+		s.deleteUser(m)
+	case createItemMsg:
+		s.createItem(m)
+	case patchItemMsg:
+		s.patchItem(m)
+	case moveItemMsg:
+		s.moveItem(m)
+	case dependencyMsg:
+		s.setDependency(m)
+	case readyMsg:
+		s.ready(m)
+	case deleteItemMsg:
+		s.deleteItem(m)
+	case restoreItemMsg:
+		s.restoreItem(m)
+	case purgeMsg:
+		s.purgeTrash(m)
+	case undoMsg:
+		s.undoSteps(m)
+	case batchMsg:
+		s.batch(m)
+	}
+}
+
+// getItems replies with a copy of the items, the trashed ones too if asked.
+func (s *actorState) getItems(m getItemsMsg) {
+	if m.withTrash {
+		cp := make([]Item, len(s.items))
+		copy(cp, s.items)
+		m.reply <- cp
+	} else {
+		m.reply <- liveItems(s.items)
+	}
+}
+
+// getItem replies with the item, unless it is missing or in the trash.
+func (s *actorState) getItem(m getItemMsg) {
+	if i, err := s.live(m.id); err == nil {
+		m.reply <- itemReply{item: s.items[i]}
+	} else {
+		m.reply <- itemReply{err: err}
+	}
+}
+
+// findUUID replies with the item that has the UUID, trashed or not.
+func (s *actorState) findUUID(m findUUIDMsg) {
+	i := slices.IndexFunc(s.items, func(it Item) bool { return it.UUID == m.uuid })
+	if i < 0 {
+		m.reply <- itemReply{err: ErrNotFound}
+		return
+	}
+	m.reply <- itemReply{item: s.items[i]}
+}
+
+// snapshot replies with a copy of everything the store saves.
+func (s *actorState) snapshot(m snapshotMsg) {
+	m.reply <- Snapshot{Items: cloneItems(s.items), History: slices.Clone(s.history), Undo: slices.Clone(s.undo), Redo: slices.Clone(s.redo), LastID: s.lastID, Lists: slices.Clone(s.lists), Webhooks: slices.Clone(s.webhooks), Users: slices.Clone(s.users)}
+}
+
+// createItem adds an item, as a subtask if it has a parent.
+func (s *actorState) createItem(m createItemMsg) {
+	if m.input.ParentID != 0 && !s.exists(m.input.ParentID) {
+		m.reply <- itemReply{err: ErrParentNotFound}
+		return
+	}
+	// Subtasks live in their parent's list.
+	list := m.input.List
+	if m.input.ParentID != 0 {
+		parentList := s.items[s.find(m.input.ParentID)].List
+		if list != "" && list != parentList {
+			m.reply <- itemReply{err: &ValidationError{Field: "list", Message: fmt.Sprintf("parent item %d is in list %q", m.input.ParentID, parentList)}}
+			return
+		}
+		list = parentList
+	}
+	if list == "" {
+		list = DefaultList
+	}
+	if !s.hasList(list) {
+		m.reply <- itemReply{err: ErrListNotFound}
+		return
+	}
+	if err := checkAssignee(s.users, m.input.AssigneeID); err != nil {
+		m.reply <- itemReply{err: err}
+		return
+	}
+	now := time.Now()
+	newItem := Item{
+		ID:          s.newID(),
+		UUID:        uuid.NewString(),
+		Description: m.input.Description,
+		List:        list,
+		Status:      s.a.workflow.Initial(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Version:     1,
+		Priority:    m.input.Priority,
+		DueAt:       m.input.DueAt,
+		Tags:        m.input.Tags,
+		ParentID:    m.input.ParentID,
+		Recurrence:  m.input.Recurrence,
+		CreatedBy:   ownerOf(m.by),
+		AssigneeID:  m.input.AssigneeID,
+	}
+	s.items = append(s.items, newItem)
+	s.commit(m.by, nil, putEntry(newItem))
+	m.reply <- itemReply{item: newItem}
+}
+
+// patchItem changes an item. Finishing a recurring item creates its next
+// occurrence.
+func (s *actorState) patchItem(m patchItemMsg) {
+	i, err := s.live(m.id)
+	if err == nil && m.patch.IfVersion != 0 && m.patch.IfVersion != s.items[i].Version {
+		err = fmt.Errorf("%w: item %d is at version %d", ErrVersionMismatch, m.id, s.items[i].Version)
+	}
+	if err == nil && m.patch.AssigneeID != nil {
+		err = checkAssignee(s.users, *m.patch.AssigneeID)
+	}
+	if err != nil {
+		m.reply <- itemReply{err: err}
+		return
+	}
+	from := s.items[i].Status
+	if m.patch.Reopen && m.patch.Status == nil {
+		initial := s.a.workflow.Initial()
+		m.patch.Status = &initial
+	}
+	if to := m.patch.Status; to != nil {
+		if err := s.a.workflow.check(m.id, from, *to, m.patch.Reopen); err != nil {
+			m.reply <- itemReply{err: err}
+			return
+		}
+		if *to != from && !m.patch.Force && s.a.workflow.Category(*to) != CategoryTodo {
+			if blockers := unfinishedBlockers(liveItems(s.items), s.items[i], s.a.workflow); len(blockers) > 0 {
+				m.reply <- itemReply{err: &BlockedError{ID: m.id, Blockers: blockers}}
+				return
 			}
 		}
-	}()
-	return &ToDoActor{inbox: inbox}
+	}
+	now := time.Now()
+	old := s.items[i]
+	m.patch.apply(&s.items[i])
+	if s.items[i].Status != from || m.patch.Reopen {
+		s.a.workflow.stamp(&s.items[i], now)
+	}
+	touch(&s.items[i], now)
+	updated := s.items[i]
+	if !s.a.workflow.isDone(from) && s.a.workflow.isDone(updated.Status) && updated.Recurrence != nil {
+		next := nextOccurrence(updated, s.newID(), s.a.workflow.Initial(), now)
+		s.items = append(s.items, next)
+		s.commit(m.by, []Item{old}, putEntry(updated), putEntry(next))
+	} else {
+		s.commit(m.by, []Item{old}, putEntry(updated))
+	}
+	m.reply <- itemReply{item: updated}
+}
+
+// moveItem gives an item a new parent or list.
+func (s *actorState) moveItem(m moveItemMsg) {
+	i, err := s.live(m.id)
+	switch {
+	case err != nil:
+		m.reply <- itemReply{err: err}
+		return
+	case m.parentID != 0 && !s.exists(m.parentID):
+		m.reply <- itemReply{err: ErrParentNotFound}
+		return
+	case m.parentID != 0 && isAncestor(s.items, m.id, m.parentID):
+		m.reply <- itemReply{err: ErrCycle}
+		return
+	}
+	list := m.list
+	if list == "" {
+		list = s.items[i].List
+		if m.parentID != 0 {
+			list = s.items[s.find(m.parentID)].List
+		}
+	}
+	switch {
+	case !s.hasList(list):
+		m.reply <- itemReply{err: ErrListNotFound}
+		return
+	case m.parentID != 0 && s.items[s.find(m.parentID)].List != list:
+		m.reply <- itemReply{err: &ValidationError{Field: "list", Message: fmt.Sprintf("parent item %d is not in list %q", m.parentID, list)}}
+		return
+	}
+	// Subtasks, including trashed ones, follow the item to its new list.
+	now := time.Now()
+	var prior []Item
+	var entries []JournalEntry
+	for _, id := range append([]int{m.id}, descendants(s.items, m.id)...) {
+		j := s.find(id)
+		if id != m.id && s.items[j].List == list {
+			continue
+		}
+		prior = append(prior, s.items[j])
+		if id == m.id {
+			s.items[j].ParentID = m.parentID
+		}
+		s.items[j].List = list
+		touch(&s.items[j], now)
+		entries = append(entries, putEntry(s.items[j]))
+	}
+	s.commit(m.by, prior, entries...)
+	m.reply <- itemReply{item: s.items[i]}
+}
+
+// deleteItem moves an item and its subtasks to the trash.
+func (s *actorState) deleteItem(m deleteItemMsg) {
+	i, err := s.live(m.id)
+	if err == nil && m.ifVersion != 0 && m.ifVersion != s.items[i].Version {
+		err = fmt.Errorf("%w: item %d is at version %d", ErrVersionMismatch, m.id, s.items[i].Version)
+	}
+	if err == nil {
+		err = mayDelete(s.users, m.by, s.items[i])
+	}
+	if err != nil {
+		m.reply <- idsReply{err: err}
+		return
+	}
+	subtasks := descendants(liveItems(s.items), m.id)
+	if len(subtasks) > 0 && s.a.deletePolicy == DeleteRefuse {
+		m.reply <- idsReply{err: ErrHasSubtasks}
+		return
+	}
+	// Trashed items keep their place, parent and dependencies so
+	// that a restore puts them back exactly as they were.
+	ids := append([]int{m.id}, subtasks...)
+	now := time.Now()
+	var prior []Item
+	var entries []JournalEntry
+	for _, id := range ids {
+		j := s.find(id)
+		prior = append(prior, s.items[j])
+		s.items[j].DeletedAt = now
+		touch(&s.items[j], now)
+		entries = append(entries, putEntry(s.items[j]))
+	}
+	s.commit(m.by, prior, entries...)
+	m.reply <- idsReply{ids: ids}
+}
+
+// restoreItem takes an item out of the trash.
+func (s *actorState) restoreItem(m restoreItemMsg) {
+	i := s.find(m.id)
+	switch {
+	case i < 0:
+		m.reply <- idsReply{err: ErrNotFound}
+		return
+	case s.items[i].DeletedAt.IsZero():
+		m.reply <- idsReply{err: ErrNotTrashed}
+		return
+	case s.items[i].ParentID != 0 && s.find(s.items[i].ParentID) >= 0 && !s.exists(s.items[i].ParentID):
+		m.reply <- idsReply{err: ErrParentTrashed}
+		return
+	}
+	// Bring back the subtasks that were trashed together with it.
+	deletedAt := s.items[i].DeletedAt
+	now := time.Now()
+	var ids []int
+	var prior []Item
+	var entries []JournalEntry
+	for _, id := range append([]int{m.id}, descendants(s.items, m.id)...) {
+		j := s.find(id)
+		if s.items[j].DeletedAt.Equal(deletedAt) {
+			prior = append(prior, s.items[j])
+			s.items[j].DeletedAt = time.Time{}
+			touch(&s.items[j], now)
+			ids = append(ids, id)
+			entries = append(entries, putEntry(s.items[j]))
+		}
+	}
+	s.commit(m.by, prior, entries...)
+	m.reply <- idsReply{ids: ids}
+}
+
+// send hands “msg” to the actor loop. The inbox is unbuffered, so the
+// time this takes is how long the message waited for the loop.
+func (a *ToDoActor) send(msg actorMsg) {
+	if a.metrics == nil {
+		a.inbox <- msg
+		return
+	}
+	start := time.Now()
+	a.inbox <- msg
+	a.metrics.observeInboxWait(time.Since(start))
 }
 
+// GetItems returns the items that are not in the trash.
 func (a *ToDoActor) GetItems() []Item {
 	reply := make(chan []Item)
-	a.inbox <- getItemsMsg{reply}
+	a.send(getItemsMsg{reply: reply})
 	return <-reply
 }
-func (a *ToDoActor) AddItem(description string) Item {
-	reply := make(chan Item)
-	a.inbox <- addItemMsg{description, reply}
+
+// GetAllItems returns every item, including those in the trash.
+func (a *ToDoActor) GetAllItems() []Item {
+	reply := make(chan []Item)
+	a.send(getItemsMsg{withTrash: true, reply: reply})
 	return <-reply
 }
+
+func (a *ToDoActor) GetItem(id int) (Item, bool) {
+	reply := make(chan itemReply)
+	a.send(getItemMsg{id, reply})
+	r := <-reply
+	return r.item, r.err == nil
+}
+
+// CreateItem validates “in” and adds it as a new, not-started item.
+func (a *ToDoActor) CreateItem(ctx context.Context, in ItemInput) (Item, error) {
+	in, err := in.normalize()
+	if err != nil {
+		return Item{}, err
+	}
+	reply := make(chan itemReply)
+	a.send(createItemMsg{originOf(ctx), in, reply})
+	r := <-reply
+	return r.item, r.err
+}
+
+// PatchItem validates “p” and applies it to the item with the given ID,
+// returning the updated item or ErrNotFound.
+func (a *ToDoActor) PatchItem(ctx context.Context, id int, p ItemPatch) (Item, error) {
+	p, err := p.normalize()
+	if err != nil {
+		return Item{}, err
+	}
+	reply := make(chan itemReply)
+	a.send(patchItemMsg{originOf(ctx), id, p, reply})
+	r := <-reply
+	return r.item, r.err
+}
+
+func (a *ToDoActor) AddItem(description string) Item {
+	item, _ := a.CreateItem(context.Background(), ItemInput{Description: description})
+	return item
+}
+
+// UpdateItem changes the description and/or status of an item; empty
+// strings leave the field unchanged.
 func (a *ToDoActor) UpdateItem(id int, description, status string) bool {
-	reply := make(chan bool)
-	a.inbox <- updateItemMsg{id, description, status, reply}
+	var p ItemPatch
+	if description != "" {
+		p.Description = &description
+	}
+	if status != "" {
+		p.Status = &status
+	}
+	_, err := a.PatchItem(context.Background(), id, p)
+	return err == nil
+}
+
+// MoveItem places an item under “parentID”, or at the top level when
+// parentID is 0. Moving an item under itself or one of its subtasks fails
+// with ErrCycle.
+func (a *ToDoActor) MoveItem(ctx context.Context, id, parentID int) (Item, error) {
+	return a.MoveItemToList(ctx, id, "", parentID)
+}
+
+// MoveItemToList is MoveItem into list “list”, taking the item's subtasks
+// along. An empty list keeps the item in its own list, or moves it to the
+// parent's; a parent must be in the target list.
+func (a *ToDoActor) MoveItemToList(ctx context.Context, id int, list string, parentID int) (Item, error) {
+	if list != "" {
+		var err error
+		if list, err = normalizeListName(list); err != nil {
+			return Item{}, err
+		}
+	}
+	reply := make(chan itemReply)
+	a.send(moveItemMsg{originOf(ctx), id, list, parentID, reply})
+	r := <-reply
+	return r.item, r.err
+}
+
+// AddDependency records that item “id” is blocked by item “blocker”. It
+// fails with ErrDependencyCycle if “blocker” already depends on “id”.
+func (a *ToDoActor) AddDependency(ctx context.Context, id, blocker int) (Item, error) {
+	reply := make(chan itemReply)
+	a.send(dependencyMsg{by: originOf(ctx), id: id, blocker: blocker, reply: reply})
+	r := <-reply
+	return r.item, r.err
+}
+
+// RemoveDependency drops “blocker” from the blockers of item “id”.
+func (a *ToDoActor) RemoveDependency(ctx context.Context, id, blocker int) (Item, error) {
+	reply := make(chan itemReply)
+	a.send(dependencyMsg{by: originOf(ctx), id: id, blocker: blocker, remove: true, reply: reply})
+	r := <-reply
+	return r.item, r.err
+}
+
+// Ready returns the items in a todo status whose blockers are all finished,
+// in an order that respects dependencies.
+func (a *ToDoActor) Ready() []Item {
+	reply := make(chan []Item)
+	a.send(readyMsg{reply})
+	return <-reply
+}
+
+// RemoveItem moves an item to the trash, honouring the actor's
+// DeletePolicy for subtasks, and returns the IDs of everything it trashed.
+func (a *ToDoActor) RemoveItem(ctx context.Context, id int) ([]int, error) {
+	return a.RemoveItemIfVersion(ctx, id, 0)
+}
+
+// RemoveItemIfVersion is RemoveItem, failing with ErrVersionMismatch unless
+// the item is at “version”; 0 accepts any version.
+func (a *ToDoActor) RemoveItemIfVersion(ctx context.Context, id, version int) ([]int, error) {
+	reply := make(chan idsReply)
+	a.send(deleteItemMsg{originOf(ctx), id, version, reply})
+	r := <-reply
+	return r.ids, r.err
+}
+
+// RestoreItem takes an item out of the trash, together with the subtasks
+// that were trashed with it, and returns their IDs. It fails with
+// ErrNotTrashed if the item is not in the trash and ErrParentTrashed if its
+// parent still is.
+func (a *ToDoActor) RestoreItem(ctx context.Context, id int) ([]int, error) {
+	reply := make(chan idsReply)
+	a.send(restoreItemMsg{originOf(ctx), id, reply})
+	r := <-reply
+	return r.ids, r.err
+}
+
+// PurgeTrash permanently deletes the items trashed before “before” and
+// returns their IDs.
+func (a *ToDoActor) PurgeTrash(ctx context.Context, before time.Time) []int {
+	reply := make(chan []int)
+	a.send(purgeMsg{originOf(ctx), before, reply})
 	return <-reply
 }
+
 func (a *ToDoActor) DeleteItem(id int) bool {
-	reply := make(chan bool)
-	a.inbox <- deleteItemMsg{id, reply}
+	_, err := a.RemoveItem(context.Background(), id)
+	return err == nil
+}
+
+// History returns the recorded changes to item “id”, oldest first. Items in
+// the trash, and items purged from it, keep their history; ErrNotFound means
+// there never was such an item.
+func (a *ToDoActor) History(ctx context.Context, id int) ([]Change, error) {
+	reply := make(chan historyReply)
+	a.send(historyMsg{id, reply})
+	r := <-reply
+	return r.changes, r.err
+}
+
+// Undo reverts the last “steps” mutations, most recent first, and returns
+// them. It stops at the first step that would overwrite a later change,
+// returning the steps undone so far with ErrUndoConflict, and fails with
+// ErrNothingToUndo when there is nothing to undo. Automatic purges of the
+// trash are not undoable.
+func (a *ToDoActor) Undo(ctx context.Context, steps int) ([]UndoStep, error) {
+	reply := make(chan undoReply)
+	a.send(undoMsg{by: originOf(ctx), steps: steps, reply: reply})
+	r := <-reply
+	return r.steps, r.err
+}
+
+// Redo reapplies the last “steps” undone mutations, like Undo in reverse.
+// Any new mutation clears what there is to redo.
+func (a *ToDoActor) Redo(ctx context.Context, steps int) ([]UndoStep, error) {
+	reply := make(chan undoReply)
+	a.send(undoMsg{by: originOf(ctx), redo: true, steps: steps, reply: reply})
+	r := <-reply
+	return r.steps, r.err
+}
+
+// Snapshot returns everything the actor would persist: all items,
+// including the trash, the history and the undo log.
+func (a *ToDoActor) Snapshot() Snapshot {
+	reply := make(chan Snapshot)
+	a.send(snapshotMsg{reply})
 	return <-reply
 }
+
+// Workflow returns the status workflow the actor enforces. It is fixed when
+// the actor is created and must not be modified.
+func (a *ToDoActor) Workflow() *Workflow {
+	return a.workflow
+}
+
+// Tree returns every item outside the trash, across all lists, in
+// depth-first order with its depth and subtask rollup.
+func (a *ToDoActor) Tree() []ItemView {
+	views, _ := a.ListTree("", false)
+	return views
+}
+
+// FullTree is Tree including the items in the trash.
+func (a *ToDoActor) FullTree() []ItemView {
+	views, _ := a.ListTree("", true)
+	return views
+}
+
+// ListTree is Tree for the items of list “name”, or of every list if name
+// is "", with or without the trash. It fails with ErrListNotFound for an
+// unknown list.
+func (a *ToDoActor) ListTree(name string, withTrash bool) ([]ItemView, error) {
+	page, err := a.Query(Query{List: name, WithTrash: withTrash})
+	return page.Items, err
+}
+
+// Query returns the page of items “q” selects. The items are filtered,
+// sorted and paged by the actor, so only the page is copied out. It fails
+// with a ValidationError for a bad query or cursor and ErrListNotFound for
+// an unknown list.
+func (a *ToDoActor) Query(q Query) (Page, error) {
+	q.List = strings.TrimSpace(q.List)
+	if err := q.validate(); err != nil {
+		return Page{}, err
+	}
+	// ParseQuery cannot tell who is asking, so this is checked here.
+	if q.Mine && q.User == "" {
+		return Page{}, &ValidationError{Field: "mine", Message: "the request does not say who is asking"}
+	}
+	reply := make(chan queryReply)
+	a.send(queryMsg{q, reply})
+	r := <-reply
+	return r.page, r.err
+}
+
+// Search returns the live items whose text matches every word of “query”,
+// best match first: see searchIndex.search. “list” limits the search to one
+// list and “limit”, if not 0, the number of results.
+func (a *ToDoActor) Search(query, list string, limit int) ([]SearchResult, error) {
+	if len(queryTerms(query)) == 0 {
+		return nil, &ValidationError{Field: "q", Message: "the search has no words to look for"}
+	}
+	if limit < 0 {
+		return nil, &ValidationError{Field: "limit", Message: "cannot be negative"}
+	}
+	reply := make(chan searchReply)
+	a.send(searchMsg{query, strings.TrimSpace(list), limit, reply})
+	r := <-reply
+	return r.results, r.err
+}
+
+// Lists returns every list with a count of its items, in creation order.
+func (a *ToDoActor) Lists() []ListSummary {
+	reply := make(chan []ListSummary)
+	a.send(listsMsg{reply})
+	return <-reply
+}
+
+// CreateList adds an empty list. It fails with ErrListExists if the name is taken.
+func (a *ToDoActor) CreateList(ctx context.Context, name string) (List, error) {
+	name, err := normalizeListName(name)
+	if err != nil {
+		return List{}, err
+	}
+	reply := make(chan listReply)
+	a.send(createListMsg{name, reply})
+	r := <-reply
+	return r.list, r.err
+}
+
+// DeleteList removes an empty list. Lists with items, even in the trash,
+// fail with ErrListNotEmpty; move the items elsewhere or purge them first.
+func (a *ToDoActor) DeleteList(ctx context.Context, name string) error {
+	reply := make(chan listReply)
+	a.send(deleteListMsg{strings.TrimSpace(name), reply})
+	return (<-reply).err
+}
@@ -1,9 +1,13 @@
 package store
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestToDoActor_ConcurrentAddAndGet(t *testing.T) {
@@ -79,3 +83,93 @@ func TestToDoActor_ConcurrentUpdateAndDelete(t *testing.T) {
 		}
 	}
 }
+
+func TestToDoActor_CreateAndPatchFields(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor([]Item{})
+	due := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	item, err := actor.CreateItem(ctx, ItemInput{
+		Description: "Plan release",
+		Priority:    "High",
+		DueAt:       due,
+		Tags:        []string{"Release", "release", "q3"},
+	})
+	if err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	if item.Priority != PriorityHigh || !item.DueAt.Equal(due) || !reflect.DeepEqual(item.Tags, []string{"q3", "release"}) {
+		t.Errorf("unexpected item: %+v", item)
+	}
+
+	none := ""
+	noTags := []string{}
+	item, err = actor.PatchItem(ctx, item.ID, ItemPatch{Priority: &none, Tags: &noTags})
+	if err != nil {
+		t.Fatalf("PatchItem failed: %v", err)
+	}
+	if item.Priority != "" || len(item.Tags) != 0 || !item.DueAt.Equal(due) {
+		t.Errorf("unexpected patched item: %+v", item)
+	}
+}
+
+func TestToDoActor_RejectsInvalidFields(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor([]Item{{ID: 1, Description: "x"}})
+	var ve *ValidationError
+
+	if _, err := actor.CreateItem(ctx, ItemInput{Description: "y", Priority: "soonish"}); !errors.As(err, &ve) {
+		t.Errorf("expected ValidationError, got %v", err)
+	}
+	bad := []string{"not ok!"}
+	if _, err := actor.PatchItem(ctx, 1, ItemPatch{Tags: &bad}); !errors.As(err, &ve) {
+		t.Errorf("expected ValidationError, got %v", err)
+	}
+	if _, err := actor.PatchItem(ctx, 2, ItemPatch{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if got := actor.GetItems(); len(got) != 1 {
+		t.Errorf("rejected create must not add items, got %+v", got)
+	}
+}
+
+func TestToDoActor_MoveRejectsCycles(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor([]Item{{ID: 1}, {ID: 2, ParentID: 1}, {ID: 3, ParentID: 2}})
+
+	if _, err := actor.MoveItem(ctx, 1, 3); !errors.Is(err, ErrCycle) {
+		t.Errorf("expected ErrCycle, got %v", err)
+	}
+	if _, err := actor.MoveItem(ctx, 2, 2); !errors.Is(err, ErrCycle) {
+		t.Errorf("expected ErrCycle for self-parent, got %v", err)
+	}
+	if _, err := actor.MoveItem(ctx, 3, 42); !errors.Is(err, ErrParentNotFound) {
+		t.Errorf("expected ErrParentNotFound, got %v", err)
+	}
+	item, err := actor.MoveItem(ctx, 3, 0)
+	if err != nil || item.ParentID != 0 {
+		t.Errorf("expected 3 at top level, got %+v, %v", item, err)
+	}
+}
+
+func TestToDoActor_DeletePolicy(t *testing.T) {
+	ctx := context.Background()
+	initial := []Item{{ID: 1}, {ID: 2, ParentID: 1}, {ID: 3, ParentID: 2}, {ID: 4}}
+
+	refuse := NewToDoActor(append([]Item(nil), initial...))
+	if _, err := refuse.RemoveItem(ctx, 1); !errors.Is(err, ErrHasSubtasks) {
+		t.Errorf("expected ErrHasSubtasks, got %v", err)
+	}
+	if ids, err := refuse.RemoveItem(ctx, 3); err != nil || len(ids) != 1 {
+		t.Errorf("expected leaf delete to succeed, got %v, %v", ids, err)
+	}
+
+	cascade := NewToDoActor(append([]Item(nil), initial...), WithDeletePolicy(DeleteCascade))
+	ids, err := cascade.RemoveItem(ctx, 1)
+	if err != nil || len(ids) != 3 {
+		t.Fatalf("expected 3 deleted IDs, got %v, %v", ids, err)
+	}
+	if got := cascade.GetItems(); len(got) != 1 || got[0].ID != 4 {
+		t.Errorf("unexpected remaining items: %+v", got)
+	}
+}
@@ -1,81 +1,973 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
 type API struct {
-	Actor *ToDoActor
+	Actor      *ToDoActor
+	Dispatcher *Dispatcher // delivers the webhooks; GET /webhooks/dead-letters is empty without one
 }
 
+// CreateRequest is the JSON body of POST /items.
+type CreateRequest struct {
+	Description string   `json:"description"`
+	List        string   `json:"list,omitempty"` // defaults to the parent's list, or the default list
+	Priority    string   `json:"priority,omitempty"`
+	DueAt       string   `json:"due_at,omitempty"` // RFC 3339 or YYYY-MM-DD
+	Tags        []string `json:"tags,omitempty"`
+	ParentID    ItemRef  `json:"parent_id,omitempty"`  // number or UUID
+	Recurrence  string   `json:"recurrence,omitempty"` // e.g. "weekly:mon,thu"; see ParseRecurrence
+	AssigneeID  string   `json:"assignee_id,omitempty"`
+}
+
+// Input converts the request into an ItemInput. ParentID is left for the
+// caller to look up with ToDoActor.Resolve.
+func (req CreateRequest) Input() (ItemInput, error) {
+	due, err := ParseDue(req.DueAt)
+	if err != nil {
+		return ItemInput{}, err
+	}
+	rec, err := ParseRecurrence(req.Recurrence)
+	if err != nil {
+		return ItemInput{}, err
+	}
+	return ItemInput{
+		Description: req.Description,
+		List:        req.List,
+		Priority:    req.Priority,
+		DueAt:       due,
+		Tags:        req.Tags,
+		Recurrence:  rec,
+		AssigneeID:  req.AssigneeID,
+	}, nil
+}
+
+// UpdateRequest is the JSON body of PATCH /items/{id}. Omitted fields, and an
+// empty status, are left unchanged; send an empty priority, due_at, tags,
+// recurrence or assignee_id value to clear it. A description cannot be
+// cleared: an empty one is rejected, as on create.
+type UpdateRequest struct {
+	ID          ItemRef   `json:"id,omitempty"` // number or UUID; taken from the path for PATCH
+	Description *string   `json:"description,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	Priority    *string   `json:"priority,omitempty"`
+	DueAt       *string   `json:"due_at,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+	Recurrence  *string   `json:"recurrence,omitempty"`
+	AssigneeID  *string   `json:"assignee_id,omitempty"`
+	Reopen      bool      `json:"reopen,omitempty"` // move a finished item back to status, or to the initial status
+	Force       bool      `json:"force,omitempty"`  // start or complete even if blockers are unfinished
+}
+
+// Patch converts the request into an ItemPatch.
+func (req UpdateRequest) Patch() (ItemPatch, error) {
+	p := ItemPatch{Description: req.Description, Priority: req.Priority, Tags: req.Tags, AssigneeID: req.AssigneeID, Reopen: req.Reopen, Force: req.Force}
+	if req.Status != "" {
+		p.Status = &req.Status
+	}
+	if req.DueAt != nil {
+		due, err := ParseDue(*req.DueAt)
+		if err != nil {
+			return p, err
+		}
+		p.DueAt = &due
+	}
+	if req.Recurrence != nil {
+		rec, err := ParseRecurrence(*req.Recurrence)
+		if err != nil {
+			return p, err
+		}
+		if rec == nil {
+			rec = &Recurrence{}
+		}
+		p.Recurrence = rec
+	}
+	return p, nil
+}
+
+// ReplaceRequest is the JSON body of PUT /items/{id}. It replaces every
+// editable field: omitted ones are cleared, except status, which is only
+// changed if given and then follows the workflow like PATCH does.
+type ReplaceRequest struct {
+	Description string   `json:"description"`
+	Status      string   `json:"status,omitempty"`
+	Priority    string   `json:"priority,omitempty"`
+	DueAt       string   `json:"due_at,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Recurrence  string   `json:"recurrence,omitempty"`
+	AssigneeID  string   `json:"assignee_id,omitempty"`
+	Reopen      bool     `json:"reopen,omitempty"`
+	Force       bool     `json:"force,omitempty"`
+}
+
+// Patch converts the request into an ItemPatch that sets every field.
+func (req ReplaceRequest) Patch() (ItemPatch, error) {
+	tags := req.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return UpdateRequest{
+		Description: &req.Description,
+		Status:      req.Status,
+		Priority:    &req.Priority,
+		DueAt:       &req.DueAt,
+		Tags:        &tags,
+		Recurrence:  &req.Recurrence,
+		AssigneeID:  &req.AssigneeID,
+		Reopen:      req.Reopen,
+		Force:       req.Force,
+	}.Patch()
+}
+
+// resolve looks up the item “ref” names, reporting an unknown UUID as
+// “missing” so that it fails like an unknown number would.
+func (api *API) resolve(ref ItemRef, missing error) (int, error) {
+	id, err := api.Actor.Resolve(ref)
+	if errors.Is(err, ErrNotFound) {
+		err = missing
+	}
+	return id, err
+}
+
+// writeError maps an actor error onto an HTTP status code.
+func writeError(w http.ResponseWriter, err error) {
+	code, msg := errorStatus(err)
+	http.Error(w, msg, code)
+}
+
+// errorStatus returns the HTTP status code for an actor error and the
+// message to answer with.
+func errorStatus(err error) (int, string) {
+	var ve *ValidationError
+	switch {
+	case errors.Is(err, ErrVersionMismatch):
+		return http.StatusPreconditionFailed, err.Error()
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, "Item not found"
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, ErrTrashed), errors.Is(err, ErrListNotFound), errors.Is(err, ErrWebhookNotFound), errors.Is(err, ErrUserNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.As(err, &ve), errors.Is(err, ErrParentNotFound), errors.Is(err, ErrBlockerNotFound):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, ErrCycle), errors.Is(err, ErrHasSubtasks),
+		errors.Is(err, ErrDependencyCycle), errors.Is(err, ErrBlocked), errors.Is(err, ErrInvalidTransition),
+		errors.Is(err, ErrNotTrashed), errors.Is(err, ErrParentTrashed),
+		errors.Is(err, ErrNothingToUndo), errors.Is(err, ErrNothingToRedo), errors.Is(err, ErrUndoConflict),
+		errors.Is(err, ErrListExists), errors.Is(err, ErrListNotEmpty),
+		errors.Is(err, ErrUserExists), errors.Is(err, ErrLastAdmin):
+		return http.StatusConflict, err.Error()
+	}
+	return http.StatusInternalServerError, "Internal error"
+}
+
+// Create is the deprecated POST /create; it answers 200 where POST /items
+// answers 201.
 func (api *API) Create(w http.ResponseWriter, r *http.Request) {
+	api.create(w, r, http.StatusOK)
+}
+
+// CreateItem adds an item for POST /items or POST /lists/{name}/items and
+// answers 201 with its Location.
+func (api *API) CreateItem(w http.ResponseWriter, r *http.Request) {
+	api.create(w, r, http.StatusCreated)
+}
+
+func (api *API) create(w http.ResponseWriter, r *http.Request, status int) {
 	ctx := r.Context()
 	traceID, _ := ctx.Value(TraceIDKey).(string)
-	var req struct {
-		Description string `json:"description"`
-	}
+	var req CreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		slog.Error("Invalid request body for create", "error", err, "traceID", traceID)
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	item := api.Actor.AddItem(req.Description)
+	// POST /lists/{name}/items creates the item in that list.
+	if name := r.PathValue("name"); name != "" {
+		req.List = name
+	}
+	in, err := req.Input()
+	if err == nil {
+		in.ParentID, err = api.resolve(req.ParentID, ErrParentNotFound)
+	}
+	var item Item
+	if err == nil {
+		item, err = api.Actor.CreateItem(ctx, in)
+	}
+	if err != nil {
+		slog.Error("Failed to create item", "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
 	slog.Info("Created new item", "description", req.Description, "traceID", traceID)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(item)
+	if status == http.StatusCreated {
+		w.Header().Set("Location", "/items/"+strconv.Itoa(item.ID))
+	}
+	writeItem(w, r, status, item)
 }
 
+// Get returns the items of every list, or of one list for
+// GET /lists/{name}/items, filtered, sorted and paged as the query
+// parameters ask (see ParseQuery). The body is the page of items; the
+// X-Total-Count header gives the number of matching items and, if there are
+// more, a Link header points to the next page.
 func (api *API) Get(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	traceID, _ := ctx.Value(TraceIDKey).(string)
-	slog.Info("Get all items", "traceID", traceID)
+	q, err := ParseQuery(r.URL.Query())
+	q.User = UserFromContext(ctx)
+	if name := r.PathValue("name"); name != "" {
+		q.List = name
+	}
+	var page Page
+	if err == nil {
+		page, err = api.Actor.Query(q)
+	}
+	if err != nil {
+		slog.Error("Failed to get items", "query", redactQuery(r.URL), "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Get items", "list", q.List, "query", redactQuery(r.URL), "count", len(page.Items), "total", page.Total, "traceID", traceID)
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.Next != "" {
+		next := q
+		if q.Offset > 0 || r.URL.Query().Has("offset") {
+			next.Offset += len(page.Items)
+		} else {
+			next.Cursor = page.Next
+		}
+		u := url.URL{Path: r.URL.Path, RawQuery: next.Values().Encode()}
+		w.Header().Set("Link", "<"+u.String()+`>; rel="next"`)
+	}
+	writeTagged(w, r, page.Items)
+}
+
+// GetItem returns the item given by the path, GET /items/{id}.
+func (api *API) GetItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	ref := ItemRef(r.PathValue("id"))
+	id, err := api.resolve(ref, ErrNotFound)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	item, ok := api.Actor.GetItem(id)
+	if !ok {
+		writeError(w, ErrNotFound)
+		return
+	}
+	slog.Info("Get item", "id", ref, "traceID", traceID)
+	writeItem(w, r, http.StatusOK, item)
+}
+
+// Lists returns every list with a count of its items.
+func (api *API) Lists(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(api.Actor.GetItems())
+	json.NewEncoder(w).Encode(api.Actor.Lists())
 }
 
-func (api *API) Update(w http.ResponseWriter, r *http.Request) {
+// CreateList adds an empty list named by the body {"name": "work"}.
+func (api *API) CreateList(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	traceID, _ := ctx.Value(TraceIDKey).(string)
 	var req struct {
-		ID          int    `json:"id"`
-		Description string `json:"description"`
-		Status      string `json:"status"`
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Invalid request body for create list", "error", err, "traceID", traceID)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	list, err := api.Actor.CreateList(ctx, req.Name)
+	if err != nil {
+		slog.Error("Failed to create list", "name", req.Name, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Created list", "name", list.Name, "traceID", traceID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(list)
+}
+
+// DeleteList removes the empty list given by the path, DELETE /lists/{name}.
+func (api *API) DeleteList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	name := r.PathValue("name")
+	if err := api.Actor.DeleteList(ctx, name); err != nil {
+		slog.Error("Failed to delete list", "name", name, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Deleted list", "name", name, "traceID", traceID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// WebhookRequest is the JSON body of POST /webhooks.
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"` // event types to send; all of them if empty
+	Secret string   `json:"secret,omitempty"` // generated if empty
+}
+
+// Webhooks returns the registered webhooks, without their secrets.
+func (api *API) Webhooks(w http.ResponseWriter, r *http.Request) {
+	hooks := []Webhook{}
+	for _, h := range api.Actor.Webhooks() {
+		hooks = append(hooks, h.Redacted())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hooks)
+}
+
+// CreateWebhook registers the webhook in the body and answers 201 with it,
+// secret included: the only time the secret is shown.
+func (api *API) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Invalid request body for create webhook", "error", err, "traceID", traceID)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	hook, err := api.Actor.CreateWebhook(ctx, Webhook{URL: req.URL, Events: req.Events, Secret: req.Secret})
+	if err != nil {
+		slog.Error("Failed to create webhook", "url", req.URL, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Created webhook", "id", hook.ID, "url", hook.URL, "events", hook.Events, "traceID", traceID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/webhooks/"+hook.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hook)
+}
+
+// DeleteWebhook removes the webhook given by the path, DELETE /webhooks/{id}.
+func (api *API) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	id := r.PathValue("id")
+	if err := api.Actor.DeleteWebhook(ctx, id); err != nil {
+		slog.Error("Failed to delete webhook", "id", id, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Deleted webhook", "id", id, "traceID", traceID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeadLetters returns the recent webhook deliveries that were given up on.
+func (api *API) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	dead := []DeadLetter{}
+	if api.Dispatcher != nil {
+		dead = append(dead, api.Dispatcher.DeadLetters()...)
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dead)
+}
+
+// UserRequest is the JSON body of POST /users.
+type UserRequest struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Admin bool   `json:"admin,omitempty"`
+}
+
+// Users returns the accounts.
+func (api *API) Users(w http.ResponseWriter, r *http.Request) {
+	users := append([]User{}, api.Actor.Users()...)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// CreateUser adds the account in the body and answers 201 with it.
+func (api *API) CreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	var req UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Invalid request body for create user", "error", err, "traceID", traceID)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	user, err := api.Actor.CreateUser(ctx, User{ID: req.ID, Name: req.Name, Admin: req.Admin})
+	if err != nil {
+		slog.Error("Failed to create user", "id", req.ID, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Created user", "id", user.ID, "admin", user.Admin, "traceID", traceID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/users/"+user.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+// DeleteUser removes the account given by the path, DELETE /users/{id}.
+func (api *API) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	id := r.PathValue("id")
+	if err := api.Actor.DeleteUser(ctx, id); err != nil {
+		slog.Error("Failed to delete user", "id", id, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Deleted user", "id", id, "traceID", traceID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Update is the deprecated POST /update, which names the item in the body
+// and answers with every item.
+func (api *API) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	var req UpdateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		slog.Error("Invalid request body for update", "error", err, "traceID", traceID)
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	found := api.Actor.UpdateItem(req.ID, req.Description, req.Status)
-	if !found {
-		slog.Error("Item not found for update", "id", req.ID, "traceID", traceID)
-		http.Error(w, "Item not found", http.StatusNotFound)
+	patch, err := req.Patch()
+	if err == nil {
+		patch.IfVersion, err = ifMatchVersion(r)
+	}
+	if err == nil {
+		_, err = api.patch(ctx, req.ID, patch)
+	}
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	slog.Info("Updated item", "id", req.ID, "traceID", traceID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(api.Actor.GetItems())
 }
 
+// PatchItem changes the fields given in the body of PATCH /items/{id} and
+// answers with the updated item.
+func (api *API) PatchItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	var req UpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Invalid request body for patch", "error", err, "traceID", traceID)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	patch, err := req.Patch()
+	if err == nil {
+		patch.IfVersion, err = ifMatchVersion(r)
+	}
+	var item Item
+	if err == nil {
+		item, err = api.patch(ctx, ItemRef(r.PathValue("id")), patch)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeItem(w, r, http.StatusOK, item)
+}
+
+// ReplaceItem replaces the editable fields of the item for PUT /items/{id}
+// and answers with the updated item.
+func (api *API) ReplaceItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	var req ReplaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Invalid request body for replace", "error", err, "traceID", traceID)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	patch, err := req.Patch()
+	if err == nil {
+		patch.IfVersion, err = ifMatchVersion(r)
+	}
+	var item Item
+	if err == nil {
+		item, err = api.patch(ctx, ItemRef(r.PathValue("id")), patch)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeItem(w, r, http.StatusOK, item)
+}
+
+func (api *API) patch(ctx context.Context, ref ItemRef, patch ItemPatch) (Item, error) {
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	id, err := api.resolve(ref, ErrNotFound)
+	var item Item
+	if err == nil {
+		item, err = api.Actor.PatchItem(ctx, id, patch)
+	}
+	if err != nil {
+		slog.Error("Failed to update item", "id", ref, "error", err, "traceID", traceID)
+		return item, err
+	}
+	slog.Info("Updated item", "id", ref, "traceID", traceID)
+	return item, nil
+}
+
+// Delete is the deprecated POST /delete, which names the item in the body.
 func (api *API) Delete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	traceID, _ := ctx.Value(TraceIDKey).(string)
 	var req struct {
-		ID int `json:"id"`
+		ID ItemRef `json:"id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		slog.Error("Invalid request body for delete", "error", err, "traceID", traceID)
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	found := api.Actor.DeleteItem(req.ID)
-	if !found {
-		slog.Error("Item not found for delete", "id", req.ID, "traceID", traceID)
-		http.Error(w, "Item not found", http.StatusNotFound)
+	api.remove(w, r, req.ID)
+}
+
+// DeleteItem moves the item given by the path to the trash,
+// DELETE /items/{id}.
+func (api *API) DeleteItem(w http.ResponseWriter, r *http.Request) {
+	api.remove(w, r, ItemRef(r.PathValue("id")))
+}
+
+func (api *API) remove(w http.ResponseWriter, r *http.Request, ref ItemRef) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	id, err := api.resolve(ref, ErrNotFound)
+	var version int
+	if err == nil {
+		version, err = ifMatchVersion(r)
+	}
+	var ids []int
+	if err == nil {
+		ids, err = api.Actor.RemoveItemIfVersion(ctx, id, version)
+	}
+	if err != nil {
+		slog.Error("Failed to delete item", "id", ref, "error", err, "traceID", traceID)
+		writeError(w, err)
 		return
 	}
-	slog.Info("Deleted item", "id", req.ID, "traceID", traceID)
+	slog.Info("Moved item to the trash", "id", ref, "trashed", ids, "traceID", traceID)
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Restore is the deprecated POST /restore, which names the item in the body.
+func (api *API) Restore(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	var req struct {
+		ID ItemRef `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Invalid request body for restore", "error", err, "traceID", traceID)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	api.restore(w, r, req.ID)
+}
+
+// RestoreItem takes the item given by the path, and the subtasks deleted
+// with it, out of the trash, POST /items/{id}/restore.
+func (api *API) RestoreItem(w http.ResponseWriter, r *http.Request) {
+	api.restore(w, r, ItemRef(r.PathValue("id")))
+}
+
+func (api *API) restore(w http.ResponseWriter, r *http.Request, ref ItemRef) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	id, err := api.resolve(ref, ErrNotFound)
+	var ids []int
+	if err == nil {
+		ids, err = api.Actor.RestoreItem(ctx, id)
+	}
+	if err != nil {
+		slog.Error("Failed to restore item", "id", ref, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Restored item", "id", ref, "restored", ids, "traceID", traceID)
+	item, _ := api.Actor.GetItem(id)
+	writeItem(w, r, http.StatusOK, item)
+}
+
+// UndoRequest is the JSON body of POST /undo and POST /redo.
+type UndoRequest struct {
+	Steps int `json:"steps,omitempty"` // how many mutations to undo or redo; defaults to 1
+}
+
+// Undo reverts the last mutations and returns them, most recent first.
+// Fewer steps than asked for are returned if an older one conflicts with a
+// later change; 409 means not even one could be undone.
+func (api *API) Undo(w http.ResponseWriter, r *http.Request) {
+	api.undo(w, r, false)
+}
+
+// Redo reapplies the last undone mutations, like Undo.
+func (api *API) Redo(w http.ResponseWriter, r *http.Request) {
+	api.undo(w, r, true)
+}
+
+func (api *API) undo(w http.ResponseWriter, r *http.Request, redo bool) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	verb := "undo"
+	if redo {
+		verb = "redo"
+	}
+	var req UndoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Invalid request body for "+verb, "error", err, "traceID", traceID)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	steps := max(req.Steps, 1)
+	var done []UndoStep
+	var err error
+	if redo {
+		done, err = api.Actor.Redo(ctx, steps)
+	} else {
+		done, err = api.Actor.Undo(ctx, steps)
+	}
+	if err != nil && len(done) == 0 {
+		slog.Error("Failed to "+verb, "steps", steps, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	if err != nil {
+		slog.Warn("Stopped "+verb+" early", "steps", steps, "done", len(done), "error", err, "traceID", traceID)
+	}
+	slog.Info("Applied "+verb, "steps", len(done), "traceID", traceID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(done)
+}
+
+// MoveRequest is the JSON body of POST /items/{id}/move, and of the
+// deprecated POST /move, which names the item by “id” rather than the path.
+// A zero parent_id moves the item to the top level. A list moves it, with
+// its subtasks, to that list; without one it stays in its list, or follows
+// the parent to its list.
+type MoveRequest struct {
+	ID       ItemRef `json:"id,omitempty"` // number or UUID
+	ParentID ItemRef `json:"parent_id"`    // number or UUID
+	List     string  `json:"list,omitempty"`
+}
+
+// Move is the deprecated POST /move.
+func (api *API) Move(w http.ResponseWriter, r *http.Request) {
+	api.move(w, r, "")
+}
+
+// MoveItem moves the item given by the path, POST /items/{id}/move.
+func (api *API) MoveItem(w http.ResponseWriter, r *http.Request) {
+	api.move(w, r, ItemRef(r.PathValue("id")))
+}
+
+// move moves the item “ref”, or the one the body names if it is zero.
+func (api *API) move(w http.ResponseWriter, r *http.Request, ref ItemRef) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	var req MoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Invalid request body for move", "error", err, "traceID", traceID)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if !ref.IsZero() {
+		req.ID = ref
+	}
+	id, err := api.resolve(req.ID, ErrNotFound)
+	var parentID int
+	if err == nil {
+		parentID, err = api.resolve(req.ParentID, ErrParentNotFound)
+	}
+	var item Item
+	if err == nil {
+		item, err = api.Actor.MoveItemToList(ctx, id, req.List, parentID)
+	}
+	if err != nil {
+		slog.Error("Failed to move item", "id", req.ID, "parent_id", req.ParentID, "list", req.List, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Moved item", "id", req.ID, "parent_id", req.ParentID, "list", item.List, "traceID", traceID)
+	writeItem(w, r, http.StatusOK, item)
+}
+
+// DependencyRequest is the JSON body of the deprecated POST /block and
+// POST /unblock: item “id” is (or stops being) blocked by item “blocker_id”.
+type DependencyRequest struct {
+	ID        ItemRef `json:"id"`         // number or UUID
+	BlockerID ItemRef `json:"blocker_id"` // number or UUID
+}
+
+// Block is the deprecated POST /block.
+func (api *API) Block(w http.ResponseWriter, r *http.Request) {
+	api.dependencyBody(w, r, false)
+}
+
+// Unblock is the deprecated POST /unblock.
+func (api *API) Unblock(w http.ResponseWriter, r *http.Request) {
+	api.dependencyBody(w, r, true)
+}
+
+// AddBlocker makes the item given by the path blocked by the blocker given
+// by it, PUT /items/{id}/blockers/{blocker}.
+func (api *API) AddBlocker(w http.ResponseWriter, r *http.Request) {
+	api.dependency(w, r, DependencyRequest{ItemRef(r.PathValue("id")), ItemRef(r.PathValue("blocker"))}, false)
+}
+
+// RemoveBlocker is AddBlocker undone, DELETE /items/{id}/blockers/{blocker}.
+func (api *API) RemoveBlocker(w http.ResponseWriter, r *http.Request) {
+	api.dependency(w, r, DependencyRequest{ItemRef(r.PathValue("id")), ItemRef(r.PathValue("blocker"))}, true)
+}
+
+func (api *API) dependencyBody(w http.ResponseWriter, r *http.Request, remove bool) {
+	traceID, _ := r.Context().Value(TraceIDKey).(string)
+	var req DependencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Invalid request body for dependency", "error", err, "traceID", traceID)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	api.dependency(w, r, req, remove)
+}
+
+func (api *API) dependency(w http.ResponseWriter, r *http.Request, req DependencyRequest, remove bool) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	id, err := api.resolve(req.ID, ErrNotFound)
+	var blocker int
+	if err == nil {
+		blocker, err = api.resolve(req.BlockerID, ErrBlockerNotFound)
+	}
+	var item Item
+	switch {
+	case err != nil:
+	case remove:
+		item, err = api.Actor.RemoveDependency(ctx, id, blocker)
+	default:
+		item, err = api.Actor.AddDependency(ctx, id, blocker)
+	}
+	if err != nil {
+		slog.Error("Failed to change dependency", "id", req.ID, "blocker_id", req.BlockerID, "remove", remove, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Changed dependency", "id", req.ID, "blocker_id", req.BlockerID, "remove", remove, "traceID", traceID)
+	writeItem(w, r, http.StatusOK, item)
+}
+
+// BatchRequest is the JSON body of POST /batch.
+type BatchRequest struct {
+	Ops []BatchOpRequest `json:"ops"`
+}
+
+// BatchOpRequest is one operation of a batch: "op" says which, and the
+// other fields are those of the request it stands for. "create" takes the
+// fields of CreateRequest, "update" those of UpdateRequest, "move" parent_id
+// and list, "block" and "unblock" blocker_id. Every op but "create" names
+// its item by "id", and "update" and "delete" may send the "if_version" the
+// item must be at, like If-Match.
+type BatchOpRequest struct {
+	Op string `json:"op"`
+	UpdateRequest
+	List      string  `json:"list,omitempty"`
+	ParentID  ItemRef `json:"parent_id,omitempty"`
+	BlockerID ItemRef `json:"blocker_id,omitempty"`
+	IfVersion int     `json:"if_version,omitempty"`
+}
+
+// BatchResponse is the answer to POST /batch: what became of every
+// operation, in order, and why the batch failed if it did.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// batchOp converts “req” into a BatchOp, looking up the items it names.
+func (api *API) batchOp(req BatchOpRequest) (BatchOp, error) {
+	op := BatchOp{Op: req.Op, List: req.List, Version: req.IfVersion}
+	var err error
+	if req.Op != OpCreate {
+		if op.ID, err = api.resolve(req.ID, ErrNotFound); err != nil {
+			return op, err
+		}
+	}
+	switch req.Op {
+	case OpCreate:
+		create := CreateRequest{List: req.List}
+		if req.Description != nil {
+			create.Description = *req.Description
+		}
+		if req.Priority != nil {
+			create.Priority = *req.Priority
+		}
+		if req.DueAt != nil {
+			create.DueAt = *req.DueAt
+		}
+		if req.Tags != nil {
+			create.Tags = *req.Tags
+		}
+		if req.Recurrence != nil {
+			create.Recurrence = *req.Recurrence
+		}
+		if req.AssigneeID != nil {
+			create.AssigneeID = *req.AssigneeID
+		}
+		if op.Input, err = create.Input(); err == nil {
+			op.Input.ParentID, err = api.resolve(req.ParentID, ErrParentNotFound)
+		}
+	case OpUpdate:
+		op.Patch, err = req.UpdateRequest.Patch()
+	case OpMove:
+		op.ParentID, err = api.resolve(req.ParentID, ErrParentNotFound)
+	case OpBlock, OpUnblock:
+		op.BlockerID, err = api.resolve(req.BlockerID, ErrBlockerNotFound)
+	}
+	return op, err
+}
+
+// Batch applies the operations of a BatchRequest in order, all or nothing.
+// It answers 200 if every one was applied; otherwise nothing was, and the
+// status is the one the failed operation would have had on its own. Either
+// way the body is a BatchResponse.
+func (api *API) Batch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Invalid request body for batch", "error", err, "traceID", traceID)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	ops := make([]BatchOp, len(req.Ops))
+	failed := -1
+	var err error
+	for i, o := range req.Ops {
+		var opErr error
+		if ops[i], opErr = api.batchOp(o); opErr != nil && failed < 0 {
+			failed, err = i, opErr
+		}
+	}
+	var results []BatchResult
+	if failed >= 0 {
+		results, err = batchFailure(ops, failed, err, BatchSkipped)
+	} else {
+		results, err = api.Actor.Batch(ctx, ops)
+	}
+	resp := BatchResponse{Results: results}
+	status := http.StatusOK
+	if err != nil {
+		slog.Error("Failed to apply batch", "ops", len(ops), "error", err, "traceID", traceID)
+		status, _ = errorStatus(err)
+		resp.Error = err.Error()
+	} else {
+		slog.Info("Applied batch", "ops", len(ops), "traceID", traceID)
+	}
+	if resp.Results == nil {
+		resp.Results = []BatchResult{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (api *API) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	slog.Info("Get ready items", "traceID", traceID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.Actor.Ready())
+}
+
+func (api *API) Status(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	status := api.Actor.FlushStatus()
+	slog.Info("Get persistence status", "dirty", status.Dirty, "last_error", status.LastError, "traceID", traceID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// Workflow returns the statuses items may have and the allowed transitions.
+func (api *API) Workflow(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.Actor.Workflow())
+}
+
+// Search returns the items matching ?q=, best match first, each with its
+// score and the words that matched. ?list= limits it to one list and
+// ?limit= the number of results.
+func (api *API) Search(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	v := r.URL.Query()
+	query := v.Get("q")
+	if query == "" {
+		http.Error(w, "Missing q", http.StatusBadRequest)
+		return
+	}
+	limit, err := parseQueryInt("limit", v.Get("limit"))
+	var results []SearchResult
+	if err == nil {
+		results, err = api.Actor.Search(query, v.Get("list"), limit)
+	}
+	if err != nil {
+		slog.Error("Failed to search", "q", query, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Searched items", "q", query, "count", len(results), "traceID", traceID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// History is the deprecated GET /history, which names the item by ?id=.
+func (api *API) History(w http.ResponseWriter, r *http.Request) {
+	ref := ItemRef(r.URL.Query().Get("id"))
+	if ref.IsZero() {
+		http.Error(w, "Missing id", http.StatusBadRequest)
+		return
+	}
+	api.history(w, r, ref)
+}
+
+// ItemHistory returns the recorded changes to the item given by the path,
+// oldest first, GET /items/{id}/history.
+func (api *API) ItemHistory(w http.ResponseWriter, r *http.Request) {
+	api.history(w, r, ItemRef(r.PathValue("id")))
+}
+
+func (api *API) history(w http.ResponseWriter, r *http.Request, ref ItemRef) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	id, err := api.resolve(ref, ErrNotFound)
+	var changes []Change
+	if err == nil {
+		changes, err = api.Actor.History(ctx, id)
+	}
+	if err != nil {
+		slog.Error("Failed to get history", "id", id, "error", err, "traceID", traceID)
+		writeError(w, err)
+		return
+	}
+	slog.Info("Get item history", "id", id, "count", len(changes), "traceID", traceID)
+	if changes == nil {
+		changes = []Change{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
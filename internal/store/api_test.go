@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func testCtx() context.Context {
@@ -93,3 +95,324 @@ func TestAPI_Delete(t *testing.T) {
 		t.Errorf("expected item to be deleted")
 	}
 }
+
+func TestAPI_Status(t *testing.T) {
+	p := &recordingPersist{}
+	p.setFail(errors.New("read-only file system"))
+	actor := NewToDoActor([]Item{}, WithWriteBehind(p.persist, WriteBehindConfig{Interval: time.Hour}))
+	api := &API{Actor: actor}
+	actor.AddItem("unsaved")
+	actor.Flush(testCtx())
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil).WithContext(testCtx())
+	w := httptest.NewRecorder()
+
+	api.Status(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var got FlushStatus
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if !got.Enabled || !got.Dirty || got.LastError != "read-only file system" {
+		t.Errorf("unexpected status: %+v", got)
+	}
+}
+
+func TestAPI_CreateWithFields(t *testing.T) {
+	actor := NewToDoActor([]Item{})
+	api := &API{Actor: actor}
+
+	body := bytes.NewBufferString(`{"description":"Ship it","priority":"urgent","due_at":"2025-07-01","tags":["Release"]}`)
+	req := httptest.NewRequest(http.MethodPost, "/create", body).WithContext(testCtx())
+	w := httptest.NewRecorder()
+
+	api.Create(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var item Item
+	if err := json.NewDecoder(w.Body).Decode(&item); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if item.Priority != PriorityUrgent || item.DueAt.IsZero() || len(item.Tags) != 1 || item.Tags[0] != "release" {
+		t.Errorf("unexpected item: %+v", item)
+	}
+}
+
+func TestAPI_CreateRejectsInvalidPriority(t *testing.T) {
+	api := &API{Actor: NewToDoActor([]Item{})}
+
+	body := bytes.NewBufferString(`{"description":"x","priority":"someday"}`)
+	req := httptest.NewRequest(http.MethodPost, "/create", body).WithContext(testCtx())
+	w := httptest.NewRecorder()
+
+	api.Create(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", w.Code)
+	}
+}
+
+func TestAPI_UpdateClearsFields(t *testing.T) {
+	actor := NewToDoActor([]Item{{ID: 1, Description: "Tagged", Priority: PriorityLow, Tags: []string{"a"}}})
+	api := &API{Actor: actor}
+
+	body := bytes.NewBufferString(`{"id":1,"priority":"","tags":[]}`)
+	req := httptest.NewRequest(http.MethodPost, "/update", body).WithContext(testCtx())
+	w := httptest.NewRecorder()
+
+	api.Update(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	it, _ := actor.GetItem(1)
+	if it.Priority != "" || len(it.Tags) != 0 || it.Description != "Tagged" {
+		t.Errorf("unexpected item: %+v", it)
+	}
+}
+
+func TestAPI_MoveConflict(t *testing.T) {
+	actor := NewToDoActor([]Item{{ID: 1}, {ID: 2, ParentID: 1}})
+	api := &API{Actor: actor}
+
+	body := bytes.NewBufferString(`{"id":1,"parent_id":2}`)
+	req := httptest.NewRequest(http.MethodPost, "/move", body).WithContext(testCtx())
+	w := httptest.NewRecorder()
+
+	api.Move(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", w.Code)
+	}
+}
+
+func TestAPI_GetIncludesRollup(t *testing.T) {
+	actor := NewToDoActor([]Item{{ID: 1}, {ID: 2, ParentID: 1, Status: StatusCompleted}, {ID: 3, ParentID: 1}})
+	api := &API{Actor: actor}
+
+	req := httptest.NewRequest(http.MethodGet, "/get", nil).WithContext(testCtx())
+	w := httptest.NewRecorder()
+
+	api.Get(w, req)
+
+	var got []ItemView
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(got) != 3 || got[0].Progress == nil || got[0].Progress.Done != 1 || got[0].Progress.Total != 2 || got[1].Depth != 1 {
+		t.Errorf("unexpected views: %+v", got)
+	}
+}
+
+func TestAPI_BlockAndReady(t *testing.T) {
+	actor := NewToDoActor([]Item{{ID: 1, Status: StatusNotStarted}, {ID: 2, Status: StatusNotStarted}})
+	api := &API{Actor: actor}
+
+	body := bytes.NewBufferString(`{"id":2,"blocker_id":1}`)
+	req := httptest.NewRequest(http.MethodPost, "/block", body).WithContext(testCtx())
+	w := httptest.NewRecorder()
+	api.Block(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	body = bytes.NewBufferString(`{"id":2,"status":"completed"}`)
+	req = httptest.NewRequest(http.MethodPost, "/update", body).WithContext(testCtx())
+	w = httptest.NewRecorder()
+	api.Update(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 for blocked item, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/ready", nil).WithContext(testCtx())
+	w = httptest.NewRecorder()
+	api.Ready(w, req)
+	var ready []Item
+	if err := json.NewDecoder(w.Body).Decode(&ready); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(ready) != 1 || ready[0].ID != 1 {
+		t.Errorf("unexpected ready items: %+v", ready)
+	}
+}
+
+func TestAPI_UpdateStatusErrors(t *testing.T) {
+	actor := NewToDoActor([]Item{{ID: 1, Description: "Task", Status: StatusCompleted}})
+	api := &API{Actor: actor}
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"id":1,"status":"banana"}`, http.StatusUnprocessableEntity},
+		{`{"id":1,"status":"not started"}`, http.StatusConflict},
+		{`{"id":1,"reopen":true}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/update", bytes.NewBufferString(tt.body)).WithContext(testCtx())
+		w := httptest.NewRecorder()
+		api.Update(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.body, tt.want, w.Code, w.Body)
+		}
+	}
+}
+
+func TestAPI_RestoreAndIncludeTrashed(t *testing.T) {
+	actor := NewToDoActor([]Item{{ID: 1, Description: "Task"}})
+	api := &API{Actor: actor}
+	actor.DeleteItem(1)
+
+	get := func(url string) []Item {
+		w := httptest.NewRecorder()
+		api.Get(w, httptest.NewRequest(http.MethodGet, url, nil).WithContext(testCtx()))
+		var items []Item
+		json.NewDecoder(w.Body).Decode(&items)
+		return items
+	}
+	if items := get("/get"); len(items) != 0 {
+		t.Errorf("expected /get to hide trashed items, got %+v", items)
+	}
+	if items := get("/get?include_trashed=true"); len(items) != 1 || items[0].DeletedAt.IsZero() {
+		t.Errorf("expected include_trashed to show the trashed item, got %+v", items)
+	}
+
+	for _, want := range []int{http.StatusOK, http.StatusConflict} {
+		req := httptest.NewRequest(http.MethodPost, "/restore", bytes.NewBufferString(`{"id":1}`)).WithContext(testCtx())
+		w := httptest.NewRecorder()
+		api.Restore(w, req)
+		if w.Code != want {
+			t.Errorf("expected status %d, got %d: %s", want, w.Code, w.Body)
+		}
+	}
+	if items := get("/get"); len(items) != 1 {
+		t.Errorf("expected the restored item in /get, got %+v", items)
+	}
+}
+
+func TestAPI_History(t *testing.T) {
+	actor := NewToDoActor(nil)
+	api := &API{Actor: actor}
+	handler := IdentityMiddleware(http.HandlerFunc(api.Update))
+
+	actor.AddItem("Task")
+	req := httptest.NewRequest(http.MethodPost, "/update", bytes.NewBufferString(`{"id":1,"status":"started"}`)).WithContext(testCtx())
+	req.Header.Set(IdentityHeader, "bob")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	api.History(w, httptest.NewRequest(http.MethodGet, "/history?id=1", nil).WithContext(testCtx()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var changes []Change
+	if err := json.NewDecoder(w.Body).Decode(&changes); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(changes) != 2 || changes[1].Field != "status" || changes[1].Actor != "bob" || changes[1].TraceID != "test-trace-id" {
+		t.Errorf("unexpected history: %+v", changes)
+	}
+
+	w = httptest.NewRecorder()
+	api.History(w, httptest.NewRequest(http.MethodGet, "/history?id=9", nil).WithContext(testCtx()))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown item, got %d", w.Code)
+	}
+}
+
+func TestAPI_UndoRedo(t *testing.T) {
+	actor := NewToDoActor(nil)
+	api := &API{Actor: actor}
+	actor.AddItem("Task")
+
+	post := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/undo", bytes.NewBufferString(body)).WithContext(testCtx()))
+		return w
+	}
+	w := post(api.Undo, `{}`)
+	var done []UndoStep
+	if err := json.NewDecoder(w.Body).Decode(&done); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 and the undone steps, got %d: %v", w.Code, err)
+	}
+	if len(done) != 1 || len(actor.GetItems()) != 0 {
+		t.Errorf("expected the add to be undone, got %+v", done)
+	}
+	if w := post(api.Undo, `{"steps":1}`); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 with nothing to undo, got %d", w.Code)
+	}
+	if w := post(api.Redo, `{"steps":1}`); w.Code != http.StatusOK || len(actor.GetItems()) != 1 {
+		t.Errorf("expected the add to be redone, got %d", w.Code)
+	}
+}
+
+func TestAPI_LookupByUUID(t *testing.T) {
+	actor := NewToDoActor(nil)
+	api := &API{Actor: actor}
+	item := actor.AddItem("Task")
+
+	body := bytes.NewBufferString(`{"id":"` + item.UUID + `","status":"started"}`)
+	w := httptest.NewRecorder()
+	api.Update(w, httptest.NewRequest(http.MethodPost, "/update", body).WithContext(testCtx()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+	}
+	if got, _ := actor.GetItem(item.ID); got.Status != StatusStarted {
+		t.Errorf("expected the item found by UUID to be updated, got %+v", got)
+	}
+
+	w = httptest.NewRecorder()
+	api.History(w, httptest.NewRequest(http.MethodGet, "/history?id="+item.UUID, nil).WithContext(testCtx()))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for history by UUID, got %d", w.Code)
+	}
+
+	body = bytes.NewBufferString(`{"id":"0b5e7b4c-5f0e-4d7c-9d0a-6a3b1f2e4c5d"}`)
+	w = httptest.NewRecorder()
+	api.Delete(w, httptest.NewRequest(http.MethodPost, "/delete", body).WithContext(testCtx()))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown UUID, got %d", w.Code)
+	}
+}
+
+func TestAPI_ListRoutes(t *testing.T) {
+	actor := NewToDoActor(nil)
+	api := &API{Actor: actor}
+	mux := http.NewServeMux()
+	api.Routes(mux)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(body)).WithContext(testCtx()))
+		return w
+	}
+
+	if w := serve(http.MethodPost, "/lists", `{"name":"work"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve(http.MethodPost, "/lists/work/items", `{"description":"Report"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body)
+	}
+	w := serve(http.MethodGet, "/lists/work/items", "")
+	var items []Item
+	if err := json.NewDecoder(w.Body).Decode(&items); err != nil || len(items) != 1 || items[0].List != "work" {
+		t.Errorf("expected the report in the work list, got %+v (%v)", items, err)
+	}
+	if w := serve(http.MethodGet, "/lists/home/items", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown list, got %d", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/lists/work", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a list with items, got %d", w.Code)
+	}
+
+	if w = serve(http.MethodPost, "/move", `{"id":1,"parent_id":0,"list":"default"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 moving between lists, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve(http.MethodDelete, "/lists/work", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204 for an empty list, got %d", w.Code)
+	}
+}
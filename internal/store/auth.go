@@ -0,0 +1,352 @@
+package store
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes of an API key or token.
+const (
+	ScopeRead      = "read"       // GET and HEAD requests
+	ScopeReadWrite = "read-write" // every request
+)
+
+// PrincipalKey is the context key for the Principal of an authenticated
+// request.
+const PrincipalKey ctxKey = "principal"
+
+// tokenPrefix starts every bearer token, naming its format.
+const tokenPrefix = "v1."
+
+// authRecheck is how often an Authenticator looks for changes to its file.
+const authRecheck = time.Second
+
+// Authentication errors.
+var (
+	ErrUnauthenticated = errors.New("missing or invalid credentials")
+	ErrTokenExpired    = errors.New("token has expired")
+	ErrTokenRevoked    = errors.New("token has been revoked")
+)
+
+// AuthConfig is the file that API keys and tokens are checked against:
+//
+//	{"signing_key": "...",
+//	 "api_keys": [{"name": "ci", "key": "...", "scope": "read"}],
+//	 "tokens": [...], "revoked": ["<token id>", ...]}
+//
+// Tokens are signed with the signing key, so any holder of the file can
+// mint them; keep it private. Tokens lists those minted, for reference;
+// a token is valid whether or not it is listed, until its ID is revoked.
+type AuthConfig struct {
+	SigningKey string        `json:"signing_key,omitempty"`
+	APIKeys    []APIKey      `json:"api_keys,omitempty"`
+	Tokens     []TokenClaims `json:"tokens,omitempty"`
+	Revoked    []string      `json:"revoked,omitempty"`
+}
+
+// APIKey is a static credential, sent as is.
+type APIKey struct {
+	Name  string `json:"name"`
+	Key   string `json:"key"`
+	Scope string `json:"scope"`
+}
+
+// TokenClaims is what a bearer token says about its holder.
+type TokenClaims struct {
+	ID        string `json:"jti"`
+	Subject   string `json:"sub"`
+	Scope     string `json:"scope"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp,omitempty"` // Unix seconds; 0 never expires
+}
+
+// Principal is who an authenticated request comes from.
+type Principal struct {
+	Name    string // the API key name or token subject
+	Scope   string
+	TokenID string // "" for an API key
+}
+
+// Allows reports whether the principal may make a request with “method”.
+func (p Principal) Allows(method string) bool {
+	switch p.Scope {
+	case ScopeReadWrite:
+		return true
+	case ScopeRead:
+		return method == http.MethodGet || method == http.MethodHead
+	}
+	return false
+}
+
+func validScope(scope string) error {
+	if scope != ScopeRead && scope != ScopeReadWrite {
+		return &ValidationError{Field: "scope", Message: fmt.Sprintf("%q is not %s or %s", scope, ScopeRead, ScopeReadWrite)}
+	}
+	return nil
+}
+
+// LoadAuthConfig reads an AuthConfig from “path”.
+func LoadAuthConfig(path string) (*AuthConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c AuthConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse auth config %s: %w", path, err)
+	}
+	for _, k := range c.APIKeys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("auth config %s: API key %q has no key", path, k.Name)
+		}
+		if err := validScope(k.Scope); err != nil {
+			return nil, fmt.Errorf("auth config %s: API key %q: %w", path, k.Name, err)
+		}
+	}
+	return &c, nil
+}
+
+// Save writes the config to “path”, readable by its owner only. Like
+// SaveSnapshot it goes through a temporary file, so a crash leaves the old
+// one intact.
+func (c *AuthConfig) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// MintToken signs a new token for “subject” with “scope”, valid for “ttl”
+// (forever if 0), generating the signing key if there is none yet. The
+// token is added to Tokens; save the config to keep it.
+func (c *AuthConfig) MintToken(subject, scope string, ttl time.Duration) (string, TokenClaims, error) {
+	if strings.TrimSpace(subject) == "" {
+		return "", TokenClaims{}, &ValidationError{Field: "subject", Message: "a token needs a subject"}
+	}
+	if err := validScope(scope); err != nil {
+		return "", TokenClaims{}, err
+	}
+	if c.SigningKey == "" {
+		key := make([]byte, 32)
+		rand.Read(key)
+		c.SigningKey = hex.EncodeToString(key)
+	}
+	now := time.Now()
+	claims := TokenClaims{ID: uuid.NewString(), Subject: subject, Scope: scope, IssuedAt: now.Unix()}
+	if ttl > 0 {
+		claims.ExpiresAt = now.Add(ttl).Unix()
+	}
+	payload, _ := json.Marshal(claims)
+	body := tokenPrefix + base64.RawURLEncoding.EncodeToString(payload)
+	c.Tokens = append(c.Tokens, claims)
+	return body + "." + c.sign(body), claims, nil
+}
+
+// Revoke makes the token “id” invalid from now on.
+func (c *AuthConfig) Revoke(id string) error {
+	if slices.Contains(c.Revoked, id) {
+		return nil
+	}
+	if !slices.ContainsFunc(c.Tokens, func(t TokenClaims) bool { return t.ID == id }) {
+		return fmt.Errorf("no token %q was minted with this file", id)
+	}
+	c.Revoked = append(c.Revoked, id)
+	return nil
+}
+
+func (c *AuthConfig) sign(body string) string {
+	mac := hmac.New(sha256.New, []byte(c.SigningKey))
+	mac.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Authenticate returns the principal “credential” stands for: an API key
+// or a bearer token minted with the config.
+func (c *AuthConfig) Authenticate(credential string, now time.Time) (Principal, error) {
+	if strings.HasPrefix(credential, tokenPrefix) && c.SigningKey != "" {
+		return c.verifyToken(credential, now)
+	}
+	// Compare digests so that the time taken says nothing about the length.
+	sum := sha256.Sum256([]byte(credential))
+	for _, k := range c.APIKeys {
+		want := sha256.Sum256([]byte(k.Key))
+		if hmac.Equal(sum[:], want[:]) {
+			return Principal{Name: k.Name, Scope: k.Scope}, nil
+		}
+	}
+	return Principal{}, ErrUnauthenticated
+}
+
+func (c *AuthConfig) verifyToken(token string, now time.Time) (Principal, error) {
+	i := strings.LastIndexByte(token, '.')
+	body, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(c.sign(body))) {
+		return Principal{}, ErrUnauthenticated
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(body, tokenPrefix))
+	var claims TokenClaims
+	if err == nil {
+		err = json.Unmarshal(payload, &claims)
+	}
+	if err != nil || validScope(claims.Scope) != nil {
+		return Principal{}, ErrUnauthenticated
+	}
+	if claims.ExpiresAt != 0 && now.Unix() >= claims.ExpiresAt {
+		return Principal{}, ErrTokenExpired
+	}
+	if slices.Contains(c.Revoked, claims.ID) {
+		return Principal{}, ErrTokenRevoked
+	}
+	return Principal{Name: claims.Subject, Scope: claims.Scope, TokenID: claims.ID}, nil
+}
+
+// Authenticator checks requests against an auth config file, picking up
+// changes to it, such as a revoked token, within a second.
+type Authenticator struct {
+	path string
+
+	mu      sync.Mutex
+	config  *AuthConfig
+	modTime time.Time
+	checked time.Time
+}
+
+// NewAuthenticator loads the auth config at “path”.
+func NewAuthenticator(path string) (*Authenticator, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := LoadAuthConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{path: path, config: config, modTime: info.ModTime(), checked: time.Now()}, nil
+}
+
+// current returns the config, reloading the file if it changed. A file
+// that no longer loads keeps the previous config in use.
+func (a *Authenticator) current(now time.Time) *AuthConfig {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Sub(a.checked) < authRecheck {
+		return a.config
+	}
+	a.checked = now
+	info, err := os.Stat(a.path)
+	if err != nil || info.ModTime().Equal(a.modTime) {
+		return a.config
+	}
+	config, err := LoadAuthConfig(a.path)
+	if err != nil {
+		slog.Error("Failed to reload the auth config, keeping the previous one", "file", a.path, "error", err)
+		return a.config
+	}
+	slog.Info("Reloaded the auth config", "file", a.path)
+	a.config, a.modTime = config, info.ModTime()
+	return a.config
+}
+
+// queryTokenPaths are the pages a browser opens itself, with no way to set
+// a header, so they alone may carry the credential in the query.
+var queryTokenPaths = []string{"/events", "/list"}
+
+// credential returns what the request authenticates with: a bearer token
+// or API key in the Authorization header, an X-API-Key header, or, for
+// browsers getting /events or /list, an access_token query parameter.
+// Anywhere else a token in the query would only end up in logs and
+// browser history, so it is ignored.
+func credential(r *http.Request) string {
+	if scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(value)
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if r.Method == http.MethodGet && slices.Contains(queryTokenPaths, r.URL.Path) {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
+
+// redactQuery returns the query of “u” for logging, with any access_token
+// blanked out.
+func redactQuery(u *url.URL) string {
+	q := u.Query()
+	if !q.Has("access_token") {
+		return u.RawQuery
+	}
+	q.Set("access_token", "REDACTED")
+	return q.Encode()
+}
+
+// AuthMiddleware lets through only requests with a valid API key or token,
+// answering 401 to the others, and 403 when a read-only credential asks to
+// change something. The principal is stored under PrincipalKey, and its name
+// replaces the IdentityKey recorded in the history and sets the UserKey the
+// request acts as.
+func AuthMiddleware(auth *Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		traceID, _ := ctx.Value(TraceIDKey).(string)
+		cred := credential(r)
+		if cred == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="todoapp"`)
+			http.Error(w, ErrUnauthenticated.Error(), http.StatusUnauthorized)
+			return
+		}
+		now := time.Now()
+		p, err := auth.current(now).Authenticate(cred, now)
+		if err != nil {
+			slog.Warn("Rejected credentials", "method", r.Method, "path", r.URL.Path, "error", err, "traceID", traceID)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="todoapp", error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !p.Allows(r.Method) {
+			slog.Warn("Insufficient scope", "principal", p.Name, "scope", p.Scope, "method", r.Method, "path", r.URL.Path, "traceID", traceID)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="todoapp", error="insufficient_scope", scope="`+ScopeReadWrite+`"`)
+			http.Error(w, fmt.Sprintf("%s needs the %s scope", r.Method, ScopeReadWrite), http.StatusForbidden)
+			return
+		}
+		ctx = context.WithValue(ctx, PrincipalKey, p)
+		ctx = context.WithValue(ctx, IdentityKey, p.Name)
+		ctx = context.WithValue(ctx, UserKey, userOf(p.Name))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,214 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthConfig_Tokens(t *testing.T) {
+	c := &AuthConfig{APIKeys: []APIKey{{Name: "ci", Key: "ci-key", Scope: ScopeRead}}}
+	token, claims, err := c.MintToken("alice", ScopeReadWrite, time.Hour)
+	if err != nil {
+		t.Fatalf("MintToken failed: %v", err)
+	}
+	if c.SigningKey == "" || len(c.Tokens) != 1 || !strings.HasPrefix(token, tokenPrefix) {
+		t.Fatalf("expected a signing key and the token recorded, got %+v", c)
+	}
+	now := time.Now()
+	if p, err := c.Authenticate(token, now); err != nil || p.Name != "alice" || p.Scope != ScopeReadWrite || p.TokenID != claims.ID {
+		t.Errorf("expected alice's token to authenticate, got %+v: %v", p, err)
+	}
+	if p, err := c.Authenticate("ci-key", now); err != nil || p.Name != "ci" || p.TokenID != "" {
+		t.Errorf("expected the API key to authenticate, got %+v: %v", p, err)
+	}
+
+	// A token claiming another subject under alice's signature.
+	payload, _ := json.Marshal(TokenClaims{ID: claims.ID, Subject: "mallory", Scope: ScopeReadWrite, IssuedAt: claims.IssuedAt})
+	forged := tokenPrefix + base64.RawURLEncoding.EncodeToString(payload) + token[strings.LastIndexByte(token, '.'):]
+
+	tests := []struct {
+		name string
+		cred string
+		at   time.Time
+		err  error
+	}{
+		{"unknown key", "nope", now, ErrUnauthenticated},
+		{"tampered", token[:len(token)-2] + "xx", now, ErrUnauthenticated},
+		{"changed claims", forged, now, ErrUnauthenticated},
+		{"expired", token, now.Add(2 * time.Hour), ErrTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Authenticate(tt.cred, tt.at); !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+
+	other := &AuthConfig{SigningKey: "another key"}
+	if _, err := other.Authenticate(token, now); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected a token signed with another key to fail, got %v", err)
+	}
+	if err := c.Revoke(claims.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, err := c.Authenticate(token, now); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected a revoked token to fail, got %v", err)
+	}
+	if err := c.Revoke("no-such-token"); err == nil {
+		t.Error("expected revoking an unknown token to fail")
+	}
+	var ve *ValidationError
+	if _, _, err := c.MintToken("bob", "admin", 0); !errors.As(err, &ve) {
+		t.Errorf("expected an unknown scope to be invalid, got %v", err)
+	}
+}
+
+func TestLoadAuthConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	c := &AuthConfig{APIKeys: []APIKey{{Name: "bot", Key: "k", Scope: ScopeRead}}}
+	c.MintToken("alice", ScopeRead, 0)
+	if err := c.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("expected the file private to its owner, got %v", info.Mode())
+	}
+	loaded, err := LoadAuthConfig(path)
+	if err != nil || loaded.SigningKey != c.SigningKey || len(loaded.Tokens) != 1 || loaded.APIKeys[0].Name != "bot" {
+		t.Fatalf("expected the config back, got %+v: %v", loaded, err)
+	}
+	os.WriteFile(path, []byte(`{"api_keys":[{"name":"bot","key":"k","scope":"all"}]}`), 0600)
+	if _, err := LoadAuthConfig(path); err == nil {
+		t.Error("expected an API key with an unknown scope to be refused")
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	c := &AuthConfig{APIKeys: []APIKey{{Name: "dashboard", Key: "read-key", Scope: ScopeRead}}}
+	writer, claims, _ := c.MintToken("alice", ScopeReadWrite, 0)
+	c.Save(path)
+	auth, err := NewAuthenticator(path)
+	if err != nil {
+		t.Fatalf("NewAuthenticator failed: %v", err)
+	}
+	actor := NewToDoActor(nil)
+	mux := http.NewServeMux()
+	(&API{Actor: actor}).Routes(mux)
+	handler := TraceIDMiddleware(IdentityMiddleware(AuthMiddleware(auth, mux)))
+
+	serve := func(method, path, header, value string) *httptest.ResponseRecorder {
+		var body *strings.Reader
+		if method == http.MethodPost {
+			body = strings.NewReader(`{"description":"Buy milk"}`)
+		} else {
+			body = strings.NewReader("")
+		}
+		req := httptest.NewRequest(method, path, body)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	tests := []struct {
+		name          string
+		method, path  string
+		header, value string
+		code          int
+	}{
+		{"no credentials", http.MethodGet, "/items", "", "", http.StatusUnauthorized},
+		{"wrong key", http.MethodGet, "/items", "X-API-Key", "guess", http.StatusUnauthorized},
+		{"not bearer", http.MethodGet, "/items", "Authorization", "Basic " + writer, http.StatusUnauthorized},
+		{"read key reads", http.MethodGet, "/items", "X-API-Key", "read-key", http.StatusOK},
+		{"read key writes", http.MethodPost, "/items", "Authorization", "Bearer read-key", http.StatusForbidden},
+		{"token writes", http.MethodPost, "/items", "Authorization", "Bearer " + writer, http.StatusCreated},
+		{"token in the query", http.MethodGet, "/items?access_token=" + writer, "", "", http.StatusUnauthorized},
+		{"token in a write's query", http.MethodPost, "/items?access_token=" + writer, "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.method, tt.path, tt.header, tt.value)
+			if w.Code != tt.code {
+				t.Errorf("expected %d, got %d: %s", tt.code, w.Code, w.Body)
+			}
+			if tt.code == http.StatusUnauthorized && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+				t.Errorf("expected a Bearer challenge, got %v", w.Header())
+			}
+		})
+	}
+	// Changes are recorded as made by the token's subject.
+	if changes, _ := actor.History(testCtx(), 1); len(changes) == 0 || changes[0].Actor != "alice" {
+		t.Errorf("expected alice in the history, got %+v", changes)
+	}
+
+	// A token revoked with the CLI is refused once the file is reread.
+	c.Revoke(claims.ID)
+	c.Save(path)
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(path, future, future)
+	auth.mu.Lock()
+	auth.checked = time.Time{}
+	auth.mu.Unlock()
+	if w := serve(http.MethodGet, "/items", "Authorization", "Bearer "+writer); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the revoked token to be refused, got %d", w.Code)
+	}
+}
+
+func TestCredential_QueryOnlyForBrowserPages(t *testing.T) {
+	tests := []struct {
+		method, target string
+		want           string
+	}{
+		{http.MethodGet, "/events?access_token=t", "t"},
+		{http.MethodGet, "/list?q=milk&access_token=t", "t"},
+		{http.MethodGet, "/items?access_token=t", ""},
+		{http.MethodPost, "/events?access_token=t", ""},
+		{http.MethodDelete, "/items/1?access_token=t", ""},
+	}
+	for _, tt := range tests {
+		if got := credential(httptest.NewRequest(tt.method, tt.target, nil)); got != tt.want {
+			t.Errorf("%s %s: expected credential %q, got %q", tt.method, tt.target, tt.want, got)
+		}
+	}
+	u, _ := url.Parse("/items?status=open&access_token=secret")
+	if got := redactQuery(u); strings.Contains(got, "secret") || !strings.Contains(got, "status=open") {
+		t.Errorf("expected the token redacted and the rest kept, got %q", got)
+	}
+}
+
+func TestClient_Token(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	c := &AuthConfig{}
+	token, _, _ := c.MintToken("alice", ScopeRead, 0)
+	c.Save(path)
+	auth, _ := NewAuthenticator(path)
+	mux := http.NewServeMux()
+	(&API{Actor: NewToDoActor(nil)}).Routes(mux)
+	srv := httptest.NewServer(AuthMiddleware(auth, mux))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	var se *StatusError
+	if _, err := client.GetItems(testCtx()); !errors.As(err, &se) || se.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %v", err)
+	}
+	client.Token = token
+	if _, err := client.GetItems(testCtx()); err != nil {
+		t.Errorf("expected the token to be accepted, got %v", err)
+	}
+	if _, err := client.AddItem(testCtx(), CreateRequest{Description: "Buy milk"}); !errors.As(err, &se) || se.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a read-only token, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// BackendOptions configures a storage backend. What Path means is up to the
+// backend: a file name for "json", ignored for "memory". Backends ignore
+// options that do not apply to them.
+type BackendOptions struct {
+	Path         string
+	Journal      bool // append changes to a journal instead of rewriting the file
+	CompactEvery int  // journal entries between snapshots; 0 means DefaultCompactEvery
+}
+
+// BackendFactory opens a Storage configured by “opts”.
+type BackendFactory func(opts BackendOptions) (Storage, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendFactory{
+		"json": func(opts BackendOptions) (Storage, error) {
+			s := NewJSONFileStorage(opts.Path)
+			s.Journal = opts.Journal
+			if opts.CompactEvery > 0 {
+				s.CompactEvery = opts.CompactEvery
+			}
+			return s, nil
+		},
+		"memory": func(opts BackendOptions) (Storage, error) {
+			return NewMemoryStorage(nil), nil
+		},
+	}
+)
+
+// RegisterBackend makes a storage backend available to OpenStorage under “name”.
+// Registering the same name twice replaces the earlier factory.
+func RegisterBackend(name string, factory BackendFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = factory
+}
+
+// OpenStorage returns a Storage from the backend registered under “name”.
+func OpenStorage(name string, opts BackendOptions) (Storage, error) {
+	backendsMu.RLock()
+	factory, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q (available: %v)", name, Backends())
+	}
+	return factory(opts)
+}
+
+// Backends lists the registered backend names in sorted order.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenStorage_KnownBackends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	s, err := OpenStorage("json", BackendOptions{Path: path, Journal: true, CompactEvery: 5})
+	if err != nil {
+		t.Fatalf("OpenStorage(json) failed: %v", err)
+	}
+	fs, ok := s.(*JSONFileStorage)
+	if !ok || fs.Path != path || !fs.Journal || fs.CompactEvery != 5 {
+		t.Errorf("expected journaled JSONFileStorage for %s, got %#v", path, s)
+	}
+	if _, err := OpenStorage("memory", BackendOptions{}); err != nil {
+		t.Fatalf("OpenStorage(memory) failed: %v", err)
+	}
+}
+
+func TestOpenStorage_UnknownBackend(t *testing.T) {
+	if _, err := OpenStorage("no-such-backend", BackendOptions{Path: "x"}); err == nil {
+		t.Fatal("expected error for unknown backend, got nil")
+	}
+}
+
+func TestRegisterBackend(t *testing.T) {
+	fake := NewMemoryStorage([]Item{{ID: 7, Description: "seeded"}})
+	RegisterBackend("test-fake", func(opts BackendOptions) (Storage, error) { return fake, nil })
+
+	s, err := OpenStorage("test-fake", BackendOptions{})
+	if err != nil {
+		t.Fatalf("OpenStorage failed: %v", err)
+	}
+	snap, err := s.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(snap.Items) != 1 || snap.Items[0].ID != 7 {
+		t.Errorf("unexpected items: %+v", snap.Items)
+	}
+}
+
+func TestMemoryStorage_IsolatedCopies(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage(nil)
+	items := []Item{{ID: 1, Description: "original"}}
+	if err := s.Save(ctx, Snapshot{Items: items}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	items[0].Description = "mutated by caller"
+	if loaded, _ := s.Load(ctx); loaded.Items[0].Description != "original" {
+		t.Errorf("storage shares memory with caller: %+v", loaded)
+	}
+
+	if err := s.Put(ctx, Item{ID: 2, Description: "second"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Delete(ctx, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	loaded, _ := s.Load(ctx)
+	if len(loaded.Items) != 1 || loaded.Items[0].ID != 2 {
+		t.Errorf("unexpected items: %+v", loaded)
+	}
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Operations a batch can hold.
+const (
+	OpCreate  = "create"
+	OpUpdate  = "update"
+	OpDelete  = "delete"
+	OpRestore = "restore"
+	OpMove    = "move"
+	OpBlock   = "block"
+	OpUnblock = "unblock"
+)
+
+var batchOps = []string{OpCreate, OpUpdate, OpDelete, OpRestore, OpMove, OpBlock, OpUnblock}
+
+// MaxBatchOps is the most operations one batch may hold.
+const MaxBatchOps = 1000
+
+// BatchOp is one operation of ToDoActor.Batch. Op says which of the other
+// fields it uses.
+type BatchOp struct {
+	Op        string
+	ID        int       // the item changed, for every Op but OpCreate
+	Input     ItemInput // OpCreate
+	Patch     ItemPatch // OpUpdate
+	Version   int       // OpUpdate and OpDelete: fail unless the item is at this version; 0 for any
+	ParentID  int       // OpMove
+	List      string    // OpMove
+	BlockerID int       // OpBlock and OpUnblock
+}
+
+// Outcomes of a batch operation.
+const (
+	BatchOK         = "ok"
+	BatchFailed     = "failed"      // the operation that failed the batch
+	BatchRolledBack = "rolled back" // succeeded, then undone with the rest of the batch
+	BatchSkipped    = "skipped"     // not applied because another operation failed
+)
+
+// BatchResult is what one operation of a batch did.
+type BatchResult struct {
+	Op     string `json:"op"`
+	Status string `json:"status"`          // one of the Batch outcomes
+	Item   *Item  `json:"item,omitempty"`  // the item as the operation left it
+	IDs    []int  `json:"ids,omitempty"`   // OpDelete and OpRestore: the item and the subtasks that went with it
+	Error  string `json:"error,omitempty"` // why it failed
+}
+
+// BatchError reports the operation that failed a batch.
+type BatchError struct {
+	Index int // of the operation, from 0
+	Op    string
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d (%s) failed: %v", e.Index+1, e.Op, e.Err)
+}
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// batchMsg runs “ops” in order as a single mutation.
+type batchMsg struct {
+	by    origin
+	ops   []BatchOp
+	reply chan batchReply
+}
+type batchReply struct {
+	results []BatchResult
+	err     error
+}
+
+// Batch applies “ops” in order, all or nothing. Each operation sees the
+// changes of the ones before it. If every one succeeds they are committed
+// together, as one step to undo; if one fails, none of them is applied and
+// the error is a *BatchError that wraps the reason. The results say what
+// happened to each operation either way.
+func (a *ToDoActor) Batch(ctx context.Context, ops []BatchOp) ([]BatchResult, error) {
+	switch {
+	case len(ops) == 0:
+		return nil, &ValidationError{Field: "ops", Message: "the batch is empty"}
+	case len(ops) > MaxBatchOps:
+		return nil, &ValidationError{Field: "ops", Message: fmt.Sprintf("a batch holds at most %d operations", MaxBatchOps)}
+	}
+	ops = slices.Clone(ops)
+	for i := range ops {
+		if err := ops[i].normalize(); err != nil {
+			return batchFailure(ops, i, err, BatchSkipped)
+		}
+	}
+	reply := make(chan batchReply)
+	a.send(batchMsg{originOf(ctx), ops, reply})
+	r := <-reply
+	return r.results, r.err
+}
+
+// batch runs every operation of a batch as if it had been sent on its own,
+// but holds their writes back. If one fails, the state goes back to how it
+// was before the batch; nothing was reported yet.
+func (s *actorState) batch(m batchMsg) {
+	saved := s.checkpoint()
+	s.tx = newTxn()
+	results := make([]BatchResult, 0, len(m.ops))
+	var err error
+	for _, op := range m.ops {
+		var res BatchResult
+		if res, err = op.run(m.by, s.handle); err != nil {
+			break
+		}
+		results = append(results, res)
+	}
+	done := s.tx
+	if err != nil {
+		*s = saved
+		var r batchReply
+		r.results, r.err = batchFailure(m.ops, len(results), err, BatchRolledBack)
+		m.reply <- r
+		return
+	}
+	s.tx = nil
+	if len(done.entries) > 0 {
+		s.commit(m.by, done.before, done.entries...)
+	}
+	m.reply <- batchReply{results: results}
+}
+
+// normalize validates “op” as the method it stands for would.
+func (op *BatchOp) normalize() error {
+	var err error
+	switch op.Op {
+	case OpCreate:
+		op.Input, err = op.Input.normalize()
+	case OpUpdate:
+		op.Patch, err = op.Patch.normalize()
+		if err == nil && op.Patch.Empty() {
+			err = &ValidationError{Field: "update", Message: "nothing to change"}
+		}
+		if op.Version != 0 {
+			op.Patch.IfVersion = op.Version
+		}
+	case OpMove:
+		if op.List != "" {
+			op.List, err = normalizeListName(op.List)
+		}
+	case OpDelete, OpRestore, OpBlock, OpUnblock:
+	default:
+		err = &ValidationError{Field: "op", Message: fmt.Sprintf("%q is not one of %v", op.Op, batchOps)}
+	}
+	return err
+}
+
+// run hands “op” to “handle”, the actor's message handler, as the message
+// its method would send, and returns the reply.
+func (op BatchOp) run(by origin, handle func(actorMsg)) (BatchResult, error) {
+	res := BatchResult{Op: op.Op, Status: BatchOK}
+	items := make(chan itemReply, 1)
+	ids := make(chan idsReply, 1)
+	switch op.Op {
+	case OpCreate:
+		handle(createItemMsg{by, op.Input, items})
+	case OpUpdate:
+		handle(patchItemMsg{by, op.ID, op.Patch, items})
+	case OpMove:
+		handle(moveItemMsg{by, op.ID, op.List, op.ParentID, items})
+	case OpBlock, OpUnblock:
+		handle(dependencyMsg{by: by, id: op.ID, blocker: op.BlockerID, remove: op.Op == OpUnblock, reply: items})
+	case OpDelete:
+		handle(deleteItemMsg{by, op.ID, op.Version, ids})
+	case OpRestore:
+		handle(restoreItemMsg{by, op.ID, ids})
+	}
+	select {
+	case r := <-items:
+		res.Item = &r.item
+		return res, r.err
+	case r := <-ids:
+		res.IDs = r.ids
+		return res, r.err
+	}
+}
+
+// batchFailure returns the results and error of a batch whose operation
+// “failed” failed with “err”; “earlier” is the status of the operations
+// before it: BatchRolledBack if they ran, BatchSkipped if not.
+func batchFailure(ops []BatchOp, failed int, err error, earlier string) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		switch {
+		case i < failed:
+			results[i] = BatchResult{Op: op.Op, Status: earlier}
+		case i == failed:
+			results[i] = BatchResult{Op: op.Op, Status: BatchFailed, Error: err.Error()}
+		default:
+			results[i] = BatchResult{Op: op.Op, Status: BatchSkipped}
+		}
+	}
+	return results, &BatchError{Index: failed, Op: ops[failed].Op, Err: err}
+}
+
+// txn collects the writes of a batch so that the actor can commit them as
+// one mutation. It keeps the state every item had before the batch and
+// only the last write to it.
+type txn struct {
+	seen    map[int]bool
+	before  []Item
+	entries []JournalEntry
+}
+
+func newTxn() *txn {
+	return &txn{seen: map[int]bool{}}
+}
+
+// add takes the writes of one operation, where “before” is what it changed.
+func (t *txn) add(before []Item, entries []JournalEntry) {
+	for _, it := range before {
+		if !t.seen[it.ID] {
+			t.seen[it.ID] = true
+			t.before = append(t.before, it)
+		}
+	}
+	for _, e := range entries {
+		id := entryID(e)
+		// An item first written by the batch did not exist before it.
+		t.seen[id] = true
+		if i := slices.IndexFunc(t.entries, func(w JournalEntry) bool { return entryID(w) == id }); i >= 0 {
+			t.entries[i] = e
+		} else {
+			t.entries = append(t.entries, e)
+		}
+	}
+}
+
+// entryID returns the item a put or delete entry writes.
+func entryID(e JournalEntry) int {
+	if e.Item != nil {
+		return e.Item.ID
+	}
+	return e.ID
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestToDoActor_Batch(t *testing.T) {
+	ctx := testCtx()
+	var reported []JournalEntry
+	actor := NewToDoActor(nil, WithChangeListener(func(e JournalEntry) { reported = append(reported, e) }))
+	a, _ := actor.CreateItem(ctx, ItemInput{Description: "Buy milk"})
+	b, _ := actor.CreateItem(ctx, ItemInput{Description: "Buy bread"})
+	reported = nil
+
+	done, started := StatusCompleted, StatusStarted
+	results, err := actor.Batch(ctx, []BatchOp{
+		{Op: OpUpdate, ID: a.ID, Patch: ItemPatch{Status: &started}},
+		{Op: OpUpdate, ID: a.ID, Patch: ItemPatch{Status: &done}},
+		{Op: OpCreate, Input: ItemInput{Description: "Buy eggs"}},
+		{Op: OpDelete, ID: b.ID},
+	})
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if len(results) != 4 || results[1].Item.Status != StatusCompleted || results[2].Item.ID != 3 || len(results[3].IDs) != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	for _, r := range results {
+		if r.Status != BatchOK {
+			t.Errorf("expected every operation to be ok, got %+v", r)
+		}
+	}
+
+	// Item 1 was written twice but is reported once, as it ended up.
+	var puts []int
+	for _, e := range reported {
+		if e.Op == JournalPut {
+			puts = append(puts, e.Item.ID)
+		}
+	}
+	if len(puts) != 3 {
+		t.Errorf("expected one write per item, got %v", puts)
+	}
+
+	// The whole batch is one step to undo.
+	steps, err := actor.Undo(ctx, 1)
+	if err != nil || len(steps) != 1 || len(steps[0].Items) != 3 {
+		t.Fatalf("expected a single step for the batch, got %+v: %v", steps, err)
+	}
+	items := actor.GetItems()
+	if len(items) != 2 || items[0].Status != StatusNotStarted || items[1].ID != b.ID {
+		t.Errorf("expected undo to revert the whole batch, got %+v", items)
+	}
+}
+
+func TestToDoActor_BatchRollsBack(t *testing.T) {
+	ctx := testCtx()
+	var reported []JournalEntry
+	actor := NewToDoActor(nil, WithChangeListener(func(e JournalEntry) { reported = append(reported, e) }))
+	a, _ := actor.CreateItem(ctx, ItemInput{Description: "Buy milk"})
+	reported = nil
+	before := actor.Snapshot()
+
+	desc := "Buy oat milk"
+	results, err := actor.Batch(ctx, []BatchOp{
+		{Op: OpUpdate, ID: a.ID, Patch: ItemPatch{Description: &desc}},
+		{Op: OpCreate, Input: ItemInput{Description: "Buy eggs"}},
+		{Op: OpDelete, ID: 42},
+		{Op: OpRestore, ID: a.ID},
+	})
+	var be *BatchError
+	if !errors.As(err, &be) || be.Index != 2 || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected operation 2 to fail with ErrNotFound, got %v", err)
+	}
+	want := []string{BatchRolledBack, BatchRolledBack, BatchFailed, BatchSkipped}
+	for i, r := range results {
+		if r.Status != want[i] || r.Item != nil {
+			t.Errorf("result %d: expected %s without an item, got %+v", i, want[i], r)
+		}
+	}
+
+	if len(reported) != 0 {
+		t.Errorf("expected nothing reported for a failed batch, got %+v", reported)
+	}
+	after := actor.Snapshot()
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("expected the store untouched, got %+v", after)
+	}
+	// The ID the rolled back create took is handed out again.
+	if item, _ := actor.CreateItem(ctx, ItemInput{Description: "Buy eggs"}); item.ID != 2 {
+		t.Errorf("expected ID 2 for the next item, got %d", item.ID)
+	}
+	if results := actor.GetItems(); results[0].Description != "Buy milk" {
+		t.Errorf("expected the update rolled back, got %+v", results[0])
+	}
+}
+
+func TestToDoActor_BatchValidation(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	var ve *ValidationError
+	if _, err := actor.Batch(ctx, nil); !errors.As(err, &ve) {
+		t.Errorf("expected an empty batch to be invalid, got %v", err)
+	}
+	results, err := actor.Batch(ctx, []BatchOp{
+		{Op: OpCreate, Input: ItemInput{Description: "Fine"}},
+		{Op: "archive", ID: 1},
+	})
+	if !errors.As(err, &ve) || len(results) != 2 || results[0].Status != BatchSkipped || results[1].Status != BatchFailed {
+		t.Errorf("expected an unknown op to fail the batch, got %+v: %v", results, err)
+	}
+	if _, err := actor.Batch(ctx, []BatchOp{{Op: OpUpdate, ID: 1}}); !errors.As(err, &ve) {
+		t.Errorf("expected an empty update to be invalid, got %v", err)
+	}
+	if items := actor.GetAllItems(); len(items) != 0 {
+		t.Errorf("expected invalid batches to change nothing, got %+v", items)
+	}
+}
+
+func TestAPI_Batch(t *testing.T) {
+	actor := NewToDoActor(nil)
+	serve := newTestMux(actor)
+	serve(http.MethodPost, "/items", `{"description":"Buy milk"}`)
+	serve(http.MethodPost, "/items", `{"description":"Buy bread"}`)
+
+	w := serve(http.MethodPost, "/batch", `{"ops":[
+		{"op":"update","id":1,"status":"completed","if_version":1},
+		{"op":"create","description":"Buy eggs","priority":"high","tags":["shop"]},
+		{"op":"block","id":3,"blocker_id":2},
+		{"op":"delete","id":"2"}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp BatchResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Results) != 4 || resp.Results[1].Item.Priority != PriorityHigh || resp.Results[2].Item.BlockedBy[0] != 2 || resp.Error != "" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"stale version", `{"ops":[{"op":"update","id":1,"description":"x","if_version":1}]}`, http.StatusPreconditionFailed},
+		{"unknown item", `{"ops":[{"op":"restore","id":2},{"op":"delete","id":"6f1c1a5e-8a4d-4a37-9a7e-3c1f0d2b9e11"}]}`, http.StatusNotFound},
+		{"conflict", `{"ops":[{"op":"restore","id":1}]}`, http.StatusConflict},
+		{"invalid", `{"ops":[{"op":"create","description":"Buy tea","priority":"whenever"}]}`, http.StatusUnprocessableEntity},
+		{"empty", `{"ops":[]}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(http.MethodPost, "/batch", tt.body)
+			var resp BatchResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if w.Code != tt.code || resp.Error == "" {
+				t.Errorf("expected %d with an error, got %d: %+v", tt.code, w.Code, resp)
+			}
+		})
+	}
+	// The restore before the unknown item was rolled back.
+	if _, ok := actor.GetItem(2); ok {
+		t.Errorf("expected item 2 to stay in the trash")
+	}
+}
+
+func TestAPI_BatchCreateAssignee(t *testing.T) {
+	actor := NewToDoActor(nil)
+	actor.CreateUser(as("alice"), User{ID: "alice"})
+	actor.CreateUser(as("alice"), User{ID: "bob"})
+	serve := newTestMux(actor)
+
+	w := serve(http.MethodPost, "/batch", `{"ops":[{"op":"create","description":"Plan","assignee_id":"bob"}]}`)
+	var resp BatchResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || len(resp.Results) != 1 || resp.Results[0].Item.AssigneeID != "bob" {
+		t.Fatalf("expected the created item assigned to bob, got %d: %+v", w.Code, resp)
+	}
+	if w := serve(http.MethodPost, "/batch", `{"ops":[{"op":"create","description":"Plan","assignee_id":"zed"}]}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an unknown assignee, got %d: %s", w.Code, w.Body)
+	}
+	if n := len(actor.GetItems()); n != 1 {
+		t.Errorf("expected only the first batch applied, got %d items", n)
+	}
+}
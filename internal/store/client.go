@@ -0,0 +1,282 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Client talks to a running API server. The CLI uses it to forward commands
+// to a server that owns the store file instead of editing the file directly.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Token      string // API key or bearer token sent with every request, if set
+}
+
+// NewClient returns a Client for the server at “baseURL” (e.g. "http://localhost:8080").
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// StatusError is returned when the server answers with an unexpected status code.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server returned %d %s: %s", e.Code, http.StatusText(e.Code), e.Message)
+}
+
+func (c *Client) AddItem(ctx context.Context, req CreateRequest) (Item, error) {
+	var item Item
+	err := c.do(ctx, http.MethodPost, "/items", req, &item)
+	return item, err
+}
+
+// GetItem returns the item “id” names.
+func (c *Client) GetItem(ctx context.Context, id ItemRef) (Item, error) {
+	var item Item
+	err := c.do(ctx, http.MethodGet, itemPath(id), nil, &item)
+	return item, err
+}
+
+func (c *Client) UpdateItem(ctx context.Context, req UpdateRequest) error {
+	return c.do(ctx, http.MethodPatch, itemPath(req.ID), req, nil)
+}
+
+func (c *Client) DeleteItem(ctx context.Context, id ItemRef) error {
+	return c.do(ctx, http.MethodDelete, itemPath(id), nil, nil)
+}
+
+func itemPath(id ItemRef) string {
+	return "/items/" + url.PathEscape(string(id))
+}
+
+func (c *Client) RestoreItem(ctx context.Context, id ItemRef) (Item, error) {
+	var item Item
+	err := c.do(ctx, http.MethodPost, itemPath(id)+"/restore", nil, &item)
+	return item, err
+}
+
+func (c *Client) MoveItem(ctx context.Context, req MoveRequest) (Item, error) {
+	var item Item
+	id := req.ID
+	req.ID = ""
+	err := c.do(ctx, http.MethodPost, itemPath(id)+"/move", req, &item)
+	return item, err
+}
+
+func (c *Client) Block(ctx context.Context, id, blockerID ItemRef) error {
+	return c.do(ctx, http.MethodPut, blockerPath(id, blockerID), nil, nil)
+}
+
+func (c *Client) Unblock(ctx context.Context, id, blockerID ItemRef) error {
+	return c.do(ctx, http.MethodDelete, blockerPath(id, blockerID), nil, nil)
+}
+
+func blockerPath(id, blockerID ItemRef) string {
+	return itemPath(id) + "/blockers/" + url.PathEscape(string(blockerID))
+}
+
+func (c *Client) Ready(ctx context.Context) ([]Item, error) {
+	var items []Item
+	err := c.do(ctx, http.MethodGet, "/ready", nil, &items)
+	return items, err
+}
+
+func (c *Client) GetItems(ctx context.Context) ([]Item, error) {
+	var items []Item
+	err := c.do(ctx, http.MethodGet, "/items", nil, &items)
+	return items, err
+}
+
+// GetAllItems is GetItems including the items in the trash.
+func (c *Client) GetAllItems(ctx context.Context) ([]Item, error) {
+	var items []Item
+	err := c.do(ctx, http.MethodGet, "/items?include_trashed=true", nil, &items)
+	return items, err
+}
+
+func (c *Client) History(ctx context.Context, id ItemRef) ([]Change, error) {
+	var changes []Change
+	err := c.do(ctx, http.MethodGet, itemPath(id)+"/history", nil, &changes)
+	return changes, err
+}
+
+// QueryItems returns the page of items “q” selects. Page.Next is set from
+// the server's Link header when there are more pages.
+func (c *Client) QueryItems(ctx context.Context, q Query) (Page, error) {
+	var page Page
+	header, err := c.send(ctx, http.MethodGet, "/items?"+q.Values().Encode(), nil, &page.Items)
+	if err != nil {
+		return page, err
+	}
+	page.Total, _ = strconv.Atoi(header.Get("X-Total-Count"))
+	if link, _, ok := strings.Cut(header.Get("Link"), ">"); ok && strings.HasPrefix(link, "<") {
+		if next, err := url.Parse(link[1:]); err == nil {
+			page.Next = next.Query().Get("cursor")
+		}
+	}
+	return page, nil
+}
+
+// Search returns the items matching “query”, best match first.
+func (c *Client) Search(ctx context.Context, query, list string, limit int) ([]SearchResult, error) {
+	v := url.Values{"q": {query}}
+	if list != "" {
+		v.Set("list", list)
+	}
+	if limit > 0 {
+		v.Set("limit", strconv.Itoa(limit))
+	}
+	var results []SearchResult
+	err := c.do(ctx, http.MethodGet, "/search?"+v.Encode(), nil, &results)
+	return results, err
+}
+
+// Lists returns every list with a count of its items.
+func (c *Client) Lists(ctx context.Context) ([]ListSummary, error) {
+	var lists []ListSummary
+	err := c.do(ctx, http.MethodGet, "/lists", nil, &lists)
+	return lists, err
+}
+
+func (c *Client) CreateList(ctx context.Context, name string) (List, error) {
+	var list List
+	err := c.do(ctx, http.MethodPost, "/lists", map[string]any{"name": name}, &list)
+	return list, err
+}
+
+func (c *Client) DeleteList(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/lists/"+url.PathEscape(name), nil, nil)
+}
+
+// Webhooks returns the registered webhooks, without their secrets.
+func (c *Client) Webhooks(ctx context.Context) ([]Webhook, error) {
+	var hooks []Webhook
+	err := c.do(ctx, http.MethodGet, "/webhooks", nil, &hooks)
+	return hooks, err
+}
+
+// AddWebhook registers a webhook and returns it with its secret.
+func (c *Client) AddWebhook(ctx context.Context, req WebhookRequest) (Webhook, error) {
+	var hook Webhook
+	err := c.do(ctx, http.MethodPost, "/webhooks", req, &hook)
+	return hook, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/webhooks/"+url.PathEscape(id), nil, nil)
+}
+
+// Users returns the accounts.
+func (c *Client) Users(ctx context.Context) ([]User, error) {
+	var users []User
+	err := c.do(ctx, http.MethodGet, "/users", nil, &users)
+	return users, err
+}
+
+// AddUser creates an account.
+func (c *Client) AddUser(ctx context.Context, req UserRequest) (User, error) {
+	var user User
+	err := c.do(ctx, http.MethodPost, "/users", req, &user)
+	return user, err
+}
+
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(id), nil, nil)
+}
+
+// DeadLetters returns the webhook deliveries the server gave up on.
+func (c *Client) DeadLetters(ctx context.Context) ([]DeadLetter, error) {
+	var dead []DeadLetter
+	err := c.do(ctx, http.MethodGet, "/webhooks/dead-letters", nil, &dead)
+	return dead, err
+}
+
+// ListItems returns the items of list “name”.
+func (c *Client) ListItems(ctx context.Context, name string) ([]Item, error) {
+	var items []Item
+	err := c.do(ctx, http.MethodGet, "/lists/"+url.PathEscape(name)+"/items", nil, &items)
+	return items, err
+}
+
+// Undo reverts the last “steps” mutations and returns them, most recent first.
+func (c *Client) Undo(ctx context.Context, steps int) ([]UndoStep, error) {
+	var done []UndoStep
+	err := c.do(ctx, http.MethodPost, "/undo", UndoRequest{Steps: steps}, &done)
+	return done, err
+}
+
+// Redo reapplies the last “steps” undone mutations.
+func (c *Client) Redo(ctx context.Context, steps int) ([]UndoStep, error) {
+	var done []UndoStep
+	err := c.do(ctx, http.MethodPost, "/redo", UndoRequest{Steps: steps}, &done)
+	return done, err
+}
+
+// Batch sends “ops” to POST /batch to be applied all or nothing. If the
+// batch fails, the results the server sent back are returned with the
+// *StatusError.
+func (c *Client) Batch(ctx context.Context, ops []BatchOpRequest) ([]BatchResult, error) {
+	var resp BatchResponse
+	err := c.do(ctx, http.MethodPost, "/batch", BatchRequest{Ops: ops}, &resp)
+	var se *StatusError
+	if errors.As(err, &se) && json.Unmarshal([]byte(se.Message), &resp) == nil && resp.Error != "" {
+		se.Message = resp.Error
+	}
+	return resp.Results, err
+}
+
+// do sends “body” as JSON and decodes the response into “out” (if non-nil).
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	_, err := c.send(ctx, method, path, body, out)
+	return err
+}
+
+// send is do, also returning the response headers.
+func (c *Client) send(ctx context.Context, method, path string, body, out any) (http.Header, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if identity, _ := ctx.Value(IdentityKey).(string); identity != "" {
+		req.Header.Set(IdentityHeader, identity)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return resp.Header, &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return resp.Header, nil
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestServer(t *testing.T, actor *ToDoActor) *httptest.Server {
+	t.Helper()
+	api := &API{Actor: actor}
+	mux := http.NewServeMux()
+	api.Routes(mux)
+	srv := httptest.NewServer(TraceIDMiddleware(mux))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor([]Item{})
+	client := NewClient(newTestServer(t, actor).URL)
+
+	item, err := client.AddItem(ctx, CreateRequest{Description: "Proxied", Tags: []string{"Remote"}})
+	if err != nil {
+		t.Fatalf("AddItem failed: %v", err)
+	}
+	if err := client.UpdateItem(ctx, UpdateRequest{ID: RefID(item.ID), Status: StatusCompleted}); err != nil {
+		t.Fatalf("UpdateItem failed: %v", err)
+	}
+	items, err := client.GetItems(ctx)
+	if err != nil {
+		t.Fatalf("GetItems failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Status != StatusCompleted || len(items[0].Tags) != 1 || items[0].Tags[0] != "remote" {
+		t.Errorf("unexpected items: %+v", items)
+	}
+	if err := client.DeleteItem(ctx, RefID(item.ID)); err != nil {
+		t.Fatalf("DeleteItem failed: %v", err)
+	}
+	if got := actor.GetItems(); len(got) != 0 {
+		t.Errorf("expected item to be deleted, got %+v", got)
+	}
+}
+
+func TestClient_ReportsStatusErrors(t *testing.T) {
+	client := NewClient(newTestServer(t, NewToDoActor([]Item{})).URL)
+
+	err := client.DeleteItem(context.Background(), RefID(99))
+	var se *StatusError
+	if !errors.As(err, &se) || se.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 StatusError, got %v", err)
+	}
+}
+
+func TestClient_QueryItems(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor([]Item{})
+	for _, d := range []string{"Walk dog", "Buy milk", "Milk cow"} {
+		actor.CreateItem(testCtx(), ItemInput{Description: d})
+	}
+	client := NewClient(newTestServer(t, actor).URL)
+
+	q := Query{Text: "milk", Sort: SortDescription, Limit: 1}
+	page, err := client.QueryItems(ctx, q)
+	if err != nil {
+		t.Fatalf("QueryItems failed: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != 2 || page.Total != 2 || page.Next == "" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+	q.Cursor = page.Next
+	if page, err = client.QueryItems(ctx, q); err != nil || len(page.Items) != 1 || page.Items[0].ID != 3 || page.Next != "" {
+		t.Errorf("unexpected last page %+v: %v", page, err)
+	}
+}
+
+func TestClient_Batch(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor([]Item{})
+	client := NewClient(newTestServer(t, actor).URL)
+	a, _ := actor.CreateItem(ctx, ItemInput{Description: "One"})
+	b, _ := actor.CreateItem(ctx, ItemInput{Description: "Two"})
+
+	ops := []BatchOpRequest{{Op: OpUpdate}, {Op: OpUpdate}}
+	ops[0].ID, ops[0].Status = RefID(a.ID), StatusCompleted
+	ops[1].ID, ops[1].Status = ItemRef(b.UUID), StatusCompleted
+	results, err := client.Batch(ctx, ops)
+	if err != nil || len(results) != 2 || results[1].Item.Status != StatusCompleted {
+		t.Fatalf("expected both items completed, got %+v: %v", results, err)
+	}
+
+	ops = []BatchOpRequest{{Op: OpDelete}, {Op: OpDelete}}
+	ops[0].ID, ops[1].ID = RefID(a.ID), RefID(a.ID)
+	results, err = client.Batch(ctx, ops)
+	var se *StatusError
+	if !errors.As(err, &se) || se.Code != http.StatusNotFound || len(results) != 2 || results[0].Status != BatchRolledBack {
+		t.Fatalf("expected the second delete to fail the batch, got %+v: %v", results, err)
+	}
+	if _, ok := actor.GetItem(a.ID); !ok {
+		t.Errorf("expected the first delete rolled back")
+	}
+}
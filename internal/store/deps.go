@@ -0,0 +1,155 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// BlockedError is returned when an item cannot start or complete because
+// other items it is blocked by are unfinished. It matches ErrBlocked.
+type BlockedError struct {
+	ID       int
+	Blockers []int // unfinished blockers
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("item %d is blocked by unfinished items %v", e.ID, e.Blockers)
+}
+
+func (e *BlockedError) Is(target error) bool { return target == ErrBlocked }
+
+// unfinishedBlockers returns the blockers of “it” that exist and are not in
+// a done status of “w”.
+func unfinishedBlockers(items []Item, it Item, w *Workflow) []int {
+	var out []int
+	for _, b := range it.BlockedBy {
+		for _, other := range items {
+			if other.ID == b && !w.isDone(other.Status) {
+				out = append(out, b)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// blocksTransitively reports whether “from” is blocked, directly or through
+// other items, by “to”. Adding the edge to→from would then close a cycle.
+func blocksTransitively(items []Item, from, to int) bool {
+	blockedBy := make(map[int][]int, len(items))
+	for _, it := range items {
+		blockedBy[it.ID] = it.BlockedBy
+	}
+	seen := map[int]bool{}
+	stack := []int{from}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if cur == to {
+			return true
+		}
+		if seen[cur] {
+			continue
+		}
+		seen[cur] = true
+		stack = append(stack, blockedBy[cur]...)
+	}
+	return false
+}
+
+// readyItems returns the items in a todo status whose blockers are all
+// finished, in topological order of the dependency graph (ties broken by ID).
+func readyItems(items []Item, w *Workflow) []Item {
+	byID := make(map[int]Item, len(items))
+	for _, it := range items {
+		byID[it.ID] = it
+	}
+	// Kahn's algorithm over edges blocker -> dependent.
+	indegree := make(map[int]int, len(items))
+	dependents := make(map[int][]int, len(items))
+	for _, it := range items {
+		indegree[it.ID] += 0
+		for _, b := range it.BlockedBy {
+			if _, ok := byID[b]; ok {
+				indegree[it.ID]++
+				dependents[b] = append(dependents[b], it.ID)
+			}
+		}
+	}
+	var queue []int
+	for id, d := range indegree {
+		if d == 0 {
+			queue = append(queue, id)
+		}
+	}
+	var out []Item
+	for len(queue) > 0 {
+		sort.Ints(queue)
+		id := queue[0]
+		queue = queue[1:]
+		it := byID[id]
+		if w.Category(it.Status) == CategoryTodo && len(unfinishedBlockers(items, it, w)) == 0 {
+			out = append(out, it)
+		}
+		for _, d := range dependents[id] {
+			if indegree[d]--; indegree[d] == 0 {
+				queue = append(queue, d)
+			}
+		}
+	}
+	return out
+}
+
+// addBlocker returns “ids” with “id” added, sorted and without duplicates.
+func addBlocker(ids []int, id int) []int {
+	for _, b := range ids {
+		if b == id {
+			return ids
+		}
+	}
+	out := append(append([]int(nil), ids...), id)
+	sort.Ints(out)
+	return out
+}
+
+// dropBlocker returns “ids” without “id”, or nil if nothing is left.
+func dropBlocker(ids []int, id int) []int {
+	var out []int
+	for _, b := range ids {
+		if b != id {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// setDependency adds or removes a blocker of an item.
+func (s *actorState) setDependency(m dependencyMsg) {
+	i, err := s.live(m.id)
+	if err != nil {
+		m.reply <- itemReply{err: err}
+		return
+	}
+	old := s.items[i]
+	switch {
+	case m.remove:
+		s.items[i].BlockedBy = dropBlocker(s.items[i].BlockedBy, m.blocker)
+	case !s.exists(m.blocker):
+		m.reply <- itemReply{err: ErrBlockerNotFound}
+		return
+	case blocksTransitively(s.items, m.blocker, m.id):
+		m.reply <- itemReply{err: ErrDependencyCycle}
+		return
+	default:
+		s.items[i].BlockedBy = addBlocker(s.items[i].BlockedBy, m.blocker)
+	}
+	touch(&s.items[i], time.Now())
+	s.commit(m.by, []Item{old}, putEntry(s.items[i]))
+	m.reply <- itemReply{item: s.items[i]}
+}
+
+// ready replies with the items that nothing unfinished blocks.
+func (s *actorState) ready(m readyMsg) {
+	m.reply <- readyItems(liveItems(s.items), s.a.workflow)
+}
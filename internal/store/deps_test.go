@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadyItems_TopologicalOrder(t *testing.T) {
+	items := []Item{
+		{ID: 1, Status: StatusCompleted},
+		{ID: 2, Status: StatusNotStarted, BlockedBy: []int{1}},
+		{ID: 3, Status: StatusNotStarted, BlockedBy: []int{2}},
+		{ID: 4, Status: StatusNotStarted},
+		{ID: 5, Status: StatusStarted},
+		{ID: 6, Status: StatusNotStarted, BlockedBy: []int{99}}, // missing blocker does not block
+	}
+	ready := readyItems(items, DefaultWorkflow())
+	var ids []int
+	for _, it := range ready {
+		ids = append(ids, it.ID)
+	}
+	want := []int{2, 4, 6}
+	if len(ids) != len(want) {
+		t.Fatalf("expected %v, got %v", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, ids)
+		}
+	}
+}
+
+func TestToDoActor_DependencyCycles(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor([]Item{{ID: 1}, {ID: 2}, {ID: 3}})
+
+	if _, err := actor.AddDependency(ctx, 2, 1); err != nil {
+		t.Fatalf("AddDependency failed: %v", err)
+	}
+	if _, err := actor.AddDependency(ctx, 3, 2); err != nil {
+		t.Fatalf("AddDependency failed: %v", err)
+	}
+	if _, err := actor.AddDependency(ctx, 1, 3); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("expected ErrDependencyCycle, got %v", err)
+	}
+	if _, err := actor.AddDependency(ctx, 1, 1); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("expected ErrDependencyCycle for self-dependency, got %v", err)
+	}
+	if _, err := actor.AddDependency(ctx, 1, 42); !errors.Is(err, ErrBlockerNotFound) {
+		t.Errorf("expected ErrBlockerNotFound, got %v", err)
+	}
+	item, err := actor.RemoveDependency(ctx, 3, 2)
+	if err != nil || len(item.BlockedBy) != 0 {
+		t.Errorf("expected dependency removed, got %+v, %v", item, err)
+	}
+}
+
+func TestToDoActor_BlockedStatusChange(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor([]Item{
+		{ID: 1, Status: StatusNotStarted},
+		{ID: 2, Status: StatusNotStarted, BlockedBy: []int{1}},
+	})
+	started := StatusStarted
+
+	_, err := actor.PatchItem(ctx, 2, ItemPatch{Status: &started})
+	var be *BlockedError
+	if !errors.As(err, &be) || len(be.Blockers) != 1 || be.Blockers[0] != 1 {
+		t.Fatalf("expected BlockedError naming 1, got %v", err)
+	}
+	if _, err := actor.PatchItem(ctx, 2, ItemPatch{Status: &started, Force: true}); err != nil {
+		t.Errorf("expected forced update to succeed, got %v", err)
+	}
+
+	// A trashed blocker no longer blocks; purging it removes the edge.
+	actor.DeleteItem(1)
+	completed := StatusCompleted
+	if _, err := actor.PatchItem(ctx, 2, ItemPatch{Status: &completed}); err != nil {
+		t.Errorf("expected a trashed blocker not to block, got %v", err)
+	}
+	if it, _ := actor.GetItem(2); len(it.BlockedBy) != 1 {
+		t.Errorf("expected the edge to survive while the blocker is in the trash, got %+v", it)
+	}
+	actor.PurgeTrash(ctx, time.Now().Add(time.Second))
+	if it, _ := actor.GetItem(2); len(it.BlockedBy) != 0 {
+		t.Errorf("expected blocker to be dropped, got %+v", it)
+	}
+}
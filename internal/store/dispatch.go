@@ -0,0 +1,415 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers of a webhook delivery.
+const (
+	SignatureHeader = "X-Todo-Signature-256" // "sha256=" and the hex HMAC-SHA256 of the body
+	EventHeader     = "X-Todo-Event"         // the event type
+	DeliveryHeader  = "X-Todo-Delivery"      // a UUID, the same for every attempt
+)
+
+// maxDeadLetters is how many dead letters a Dispatcher keeps in memory.
+const maxDeadLetters = 1000
+
+// WebhookConfig tunes a Dispatcher. Zero fields take the defaults.
+type WebhookConfig struct {
+	Client      *http.Client  // default: a client with a 10s timeout
+	MaxAttempts int           // tries per delivery, default 6
+	Backoff     time.Duration // wait before the first retry, doubled after each, default 1s
+	MaxBackoff  time.Duration // longest wait between tries, default 5m
+	QueueSize   int           // deliveries waiting per webhook before more are dead-lettered, default 1000
+	DeadLetters string        // file the failed deliveries are appended to, none if ""
+
+	// AllowPrivate lets the default client deliver to loopback, private and
+	// link-local addresses, which it otherwise refuses so that a webhook
+	// cannot be used to reach the server's own network.
+	AllowPrivate bool
+}
+
+// DeadLetter is a delivery that was given up on.
+type DeadLetter struct {
+	WebhookID  string    `json:"webhook_id"`
+	URL        string    `json:"url"`
+	DeliveryID string    `json:"delivery_id"`
+	Event      Event     `json:"event"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error"`
+	At         time.Time `json:"at"`
+}
+
+// Dispatcher posts the item events of an actor to its webhooks. Each
+// delivery is a POST of the Event as JSON, signed with the webhook's secret
+// (see SignPayload). A delivery that fails with a network error, a timeout,
+// 408, 429 or a 5xx is retried with exponential backoff; one that is
+// refused with any other status, or still fails after the last attempt, is
+// recorded as a dead letter. Each webhook has its own queue, worked off one
+// delivery at a time, so a slow receiver holds up only its own events; when
+// its queue is full, further deliveries to it are dead-lettered. Deliveries
+// to different webhooks run concurrently, so a receiver that cares about
+// order should go by the event ID or time.
+type Dispatcher struct {
+	actor *ToDoActor
+	cfg   WebhookConfig
+
+	ctx    context.Context // the requests, cancelled if Close runs out of time
+	cancel context.CancelFunc
+	stop   chan struct{}  // closed by Close
+	done   chan struct{}  // closed when run returns
+	wg     sync.WaitGroup // running workers
+
+	queues map[string]chan queued // by webhook ID, only touched by run
+
+	mu   sync.Mutex
+	sub  *Subscription
+	dead []DeadLetter
+}
+
+// StartDispatcher starts delivering the events of “actor” to its webhooks,
+// after loading the dead letters already in cfg.DeadLetters. Call Close to
+// stop it.
+func StartDispatcher(actor *ToDoActor, cfg WebhookConfig) *Dispatcher {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second, Transport: webhookTransport(cfg.AllowPrivate)}
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 6
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{actor: actor, cfg: cfg, ctx: ctx, cancel: cancel, stop: make(chan struct{}), done: make(chan struct{}), queues: map[string]chan queued{}}
+	if err := d.loadDeadLetters(); err != nil {
+		slog.Error("Failed to load the webhook dead letters", "file", cfg.DeadLetters, "error", err)
+	}
+	d.sub = actor.Subscribe("")
+	go d.run()
+	return d
+}
+
+// run hands the events to dispatch, together with any others already
+// waiting. If the subscription ends early, because the dispatcher fell
+// behind or the subscriptions were closed, it resumes from the last event it
+// handled. When it returns, the workers are left to finish their queues.
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	defer d.closeQueues(nil)
+	d.mu.Lock()
+	sub := d.sub
+	d.mu.Unlock()
+	last := sub.LastID
+	for {
+		if len(sub.Replay) > 0 {
+			d.dispatch(sub.Replay)
+			last = sub.Replay[len(sub.Replay)-1].ID
+		}
+		for ev := range sub.C {
+			batch := []Event{ev}
+		more:
+			for {
+				select {
+				case ev, ok := <-sub.C:
+					if !ok {
+						break more
+					}
+					batch = append(batch, ev)
+				default:
+					break more
+				}
+			}
+			d.dispatch(batch)
+			last = batch[len(batch)-1].ID
+		}
+		d.mu.Lock()
+		select {
+		case <-d.stop:
+			d.mu.Unlock()
+			return
+		default:
+		}
+		sub = d.actor.Subscribe(last)
+		d.sub = sub
+		d.mu.Unlock()
+		if sub.Missed {
+			slog.Warn("Webhook events were lost", "last_event_id", last)
+		}
+	}
+}
+
+// queued is a delivery waiting in the queue of a webhook.
+type queued struct {
+	hook  Webhook
+	event Event
+	id    string
+}
+
+// dispatch queues every event of “batch” for each webhook that wants it,
+// starting a worker for a webhook the first time it gets one and stopping
+// those of webhooks that were removed. A delivery that does not fit in its
+// queue is dead-lettered at once.
+func (d *Dispatcher) dispatch(batch []Event) {
+	hooks := d.actor.Webhooks()
+	d.closeQueues(hooks)
+	for _, ev := range batch {
+		for _, h := range hooks {
+			if !h.wants(ev.Type) {
+				continue
+			}
+			q, ok := d.queues[h.ID]
+			if !ok {
+				q = make(chan queued, d.cfg.QueueSize)
+				d.queues[h.ID] = q
+				d.wg.Add(1)
+				go d.work(q)
+			}
+			dl := queued{hook: h, event: ev, id: uuid.NewString()}
+			select {
+			case q <- dl:
+			default:
+				d.deadLetter(h, ev, dl.id, 0, fmt.Errorf("%w (%d deliveries waiting)", errQueueFull, d.cfg.QueueSize))
+			}
+		}
+	}
+}
+
+// errQueueFull is the error of a delivery that found its webhook's queue full.
+var errQueueFull = errors.New("webhook queue full")
+
+// closeQueues closes the queues of the webhooks not in “keep”, so that their
+// workers stop once they have worked them off.
+func (d *Dispatcher) closeQueues(keep []Webhook) {
+	for id, q := range d.queues {
+		if !slices.ContainsFunc(keep, func(h Webhook) bool { return h.ID == id }) {
+			close(q)
+			delete(d.queues, id)
+		}
+	}
+}
+
+// work makes the deliveries of a queue in order, until it is closed.
+func (d *Dispatcher) work(q <-chan queued) {
+	defer d.wg.Done()
+	for dl := range q {
+		d.deliver(dl.hook, dl.event, dl.id)
+	}
+}
+
+// deliver posts “ev” to “h” until it succeeds, fails for good or the
+// dispatcher is closed.
+func (d *Dispatcher) deliver(h Webhook, ev Event, id string) {
+	body, _ := json.Marshal(ev)
+	wait := d.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(h, ev.Type, id, body)
+		if err == nil {
+			slog.Info("Delivered webhook", "webhook_id", h.ID, "event_id", ev.ID, "delivery_id", id, "attempt", attempt)
+			return
+		}
+		if !retry || attempt >= d.cfg.MaxAttempts {
+			d.deadLetter(h, ev, id, attempt, err)
+			return
+		}
+		slog.Warn("Webhook delivery failed, retrying", "webhook_id", h.ID, "delivery_id", id, "attempt", attempt, "retry_in", wait, "error", err)
+		select {
+		case <-time.After(wait):
+		case <-d.stop:
+			d.deadLetter(h, ev, id, attempt, fmt.Errorf("%w (dispatcher closed before the next attempt)", err))
+			return
+		}
+		wait = min(2*wait, d.cfg.MaxBackoff)
+	}
+}
+
+// post makes one attempt at a delivery and reports whether a failure is
+// worth retrying.
+func (d *Dispatcher) post(h Webhook, typ, id string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todoapp-webhook")
+	req.Header.Set(EventHeader, typ)
+	req.Header.Set(DeliveryHeader, id)
+	req.Header.Set(SignatureHeader, SignPayload(h.Secret, body))
+	resp, err := d.cfg.Client.Do(req)
+	if err != nil {
+		return !errors.Is(err, ErrPrivateAddress), err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("receiver answered %s", resp.Status)
+}
+
+// webhookTransport is the transport of the default delivery client. It
+// ignores any proxy from the environment and, unless “allowPrivate” is set,
+// checks every address a webhook host resolves to just before dialing it, so
+// that a name pointed at an internal address later is refused too.
+func webhookTransport(allowPrivate bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+// refusePrivate is a net.Dialer Control func that fails with
+// ErrPrivateAddress for loopback, private, link-local and unspecified
+// addresses.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+	}
+	return nil
+}
+
+// deadLetter records a delivery that was given up on.
+func (d *Dispatcher) deadLetter(h Webhook, ev Event, id string, attempts int, err error) {
+	dl := DeadLetter{WebhookID: h.ID, URL: h.URL, DeliveryID: id, Event: ev, Attempts: attempts, Error: err.Error(), At: time.Now()}
+	slog.Error("Webhook delivery failed for good", "webhook_id", h.ID, "delivery_id", id, "event_id", ev.ID, "attempts", attempts, "error", err)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dead = append(d.dead, dl)
+	if len(d.dead) > maxDeadLetters {
+		d.dead = append([]DeadLetter(nil), d.dead[len(d.dead)-maxDeadLetters:]...)
+	}
+	if d.cfg.DeadLetters == "" {
+		return
+	}
+	line, _ := json.Marshal(dl)
+	f, err := os.OpenFile(d.cfg.DeadLetters, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		slog.Error("Failed to write a webhook dead letter", "file", d.cfg.DeadLetters, "error", err)
+	}
+}
+
+// loadDeadLetters reads the most recent dead letters of the file.
+func (d *Dispatcher) loadDeadLetters() error {
+	if d.cfg.DeadLetters == "" {
+		return nil
+	}
+	f, err := os.Open(d.cfg.DeadLetters)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lines := bufio.NewScanner(f)
+	lines.Buffer(nil, 1<<20)
+	for lines.Scan() {
+		var dl DeadLetter
+		if strings.TrimSpace(lines.Text()) == "" {
+			continue
+		}
+		if err := json.Unmarshal(lines.Bytes(), &dl); err != nil {
+			return err
+		}
+		d.dead = append(d.dead, dl)
+	}
+	if len(d.dead) > maxDeadLetters {
+		d.dead = d.dead[len(d.dead)-maxDeadLetters:]
+	}
+	return lines.Err()
+}
+
+// DeadLetters returns the recent deliveries that were given up on, oldest
+// first.
+func (d *Dispatcher) DeadLetters() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DeadLetter(nil), d.dead...)
+}
+
+// Close stops the dispatcher. Deliveries in flight or queued get until “ctx”
+// is done to finish; those waiting for a retry are recorded as dead letters.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	close(d.stop)
+	d.sub.Close()
+	d.mu.Unlock()
+	<-d.done
+
+	finished := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// SignPayload returns the signature of a webhook delivery of “body”: the
+// value of the X-Todo-Signature-256 header.
+func SignPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether “signature” is the signature of “body”
+// with “secret”, comparing in constant time. Receivers use it to check that
+// a delivery comes from the server.
+func VerifySignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(SignPayload(secret, body)))
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// delivery is a webhook request as a receiver saw it.
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is an httptest webhook receiver that answers each delivery with
+// the next status of “statuses”, repeating the last one.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	got      []delivery
+	statuses []int
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rc := &receiver{statuses: statuses}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		rc.got = append(rc.got, delivery{r.Header.Clone(), body})
+		status := http.StatusOK
+		if n := len(rc.got); len(rc.statuses) > 0 {
+			status = rc.statuses[min(n, len(rc.statuses))-1]
+		}
+		rc.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) deliveries() []delivery {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]delivery(nil), rc.got...)
+}
+
+func TestDispatcher_Delivers(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	all, deletes := newReceiver(t), newReceiver(t)
+	allHook, _ := actor.CreateWebhook(ctx, Webhook{URL: all.URL})
+	actor.CreateWebhook(ctx, Webhook{URL: deletes.URL, Events: []string{EventDeleted}})
+	d := StartDispatcher(actor, WebhookConfig{AllowPrivate: true})
+	defer d.Close(context.Background())
+
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "Buy milk"})
+	actor.RemoveItem(ctx, item.ID)
+	waitFor(t, "two deliveries", func() bool { return len(all.deliveries()) == 2 })
+	waitFor(t, "the delete", func() bool { return len(deletes.deliveries()) == 1 })
+
+	types := map[string]bool{}
+	for _, got := range all.deliveries() {
+		var ev Event
+		if err := json.Unmarshal(got.body, &ev); err != nil || ev.Item.ID != item.ID {
+			t.Errorf("expected an event for item %d, got %s: %v", item.ID, got.body, err)
+		}
+		if !VerifySignature(allHook.Secret, got.body, got.header.Get(SignatureHeader)) {
+			t.Errorf("signature %q does not match the body", got.header.Get(SignatureHeader))
+		}
+		if got.header.Get(EventHeader) != ev.Type || got.header.Get(DeliveryHeader) == "" || got.header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected headers %v", got.header)
+		}
+		types[ev.Type] = true
+	}
+	if !types[EventCreated] || !types[EventDeleted] {
+		t.Errorf("expected a created and a deleted event, got %v", types)
+	}
+	if got := deletes.deliveries()[0]; got.header.Get(EventHeader) != EventDeleted {
+		t.Errorf("expected only the delete for the filtered webhook, got %v", got.header)
+	}
+	if VerifySignature("wrong", all.deliveries()[0].body, all.deliveries()[0].header.Get(SignatureHeader)) {
+		t.Error("expected another secret not to verify")
+	}
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	rc := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	actor.CreateWebhook(ctx, Webhook{URL: rc.URL})
+	d := StartDispatcher(actor, WebhookConfig{AllowPrivate: true, Backoff: 20 * time.Millisecond})
+	defer d.Close(context.Background())
+
+	start := time.Now()
+	actor.CreateItem(ctx, ItemInput{Description: "Buy milk"})
+	waitFor(t, "three attempts", func() bool { return len(rc.deliveries()) == 3 })
+	// The waits double: 20ms, then 40ms.
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("expected exponential backoff between attempts, all three took %v", elapsed)
+	}
+	got := rc.deliveries()
+	if id := got[0].header.Get(DeliveryHeader); id != got[2].header.Get(DeliveryHeader) {
+		t.Errorf("expected every attempt to carry delivery ID %s", id)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(rc.deliveries()); n != 3 || len(d.DeadLetters()) != 0 {
+		t.Errorf("expected no attempt after the success, got %d and dead letters %+v", n, d.DeadLetters())
+	}
+}
+
+func TestDispatcher_DeadLetters(t *testing.T) {
+	ctx := testCtx()
+	path := filepath.Join(t.TempDir(), "todos.json.dead-letters")
+	actor := NewToDoActor(nil)
+	failing, refusing := newReceiver(t, http.StatusInternalServerError), newReceiver(t, http.StatusGone)
+	failingHook, _ := actor.CreateWebhook(ctx, Webhook{URL: failing.URL})
+	actor.CreateWebhook(ctx, Webhook{URL: refusing.URL})
+	d := StartDispatcher(actor, WebhookConfig{AllowPrivate: true, MaxAttempts: 3, Backoff: time.Millisecond, DeadLetters: path})
+
+	actor.CreateItem(ctx, ItemInput{Description: "Buy milk"})
+	waitFor(t, "two dead letters", func() bool { return len(d.DeadLetters()) == 2 })
+	for _, dl := range d.DeadLetters() {
+		want := 1 // a refusal is not retried
+		if dl.WebhookID == failingHook.ID {
+			want = 3
+		}
+		if dl.Attempts != want || dl.Event.Type != EventCreated || dl.Error == "" {
+			t.Errorf("expected %d attempts at the created event, got %+v", want, dl)
+		}
+	}
+	if len(failing.deliveries()) != 3 || len(refusing.deliveries()) != 1 {
+		t.Errorf("expected 3 and 1 attempts, got %d and %d", len(failing.deliveries()), len(refusing.deliveries()))
+	}
+
+	d.Close(context.Background())
+
+	// The dead letters are kept in the file across restarts.
+	reloaded := StartDispatcher(actor, WebhookConfig{AllowPrivate: true, DeadLetters: path})
+	defer reloaded.Close(context.Background())
+	if dead := reloaded.DeadLetters(); len(dead) != 2 || dead[0].DeliveryID != d.DeadLetters()[0].DeliveryID {
+		t.Errorf("expected the dead letters from the file, got %+v", dead)
+	}
+}
+
+func TestDispatcher_CloseDuringRetry(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	rc := newReceiver(t, http.StatusBadGateway)
+	actor.CreateWebhook(ctx, Webhook{URL: rc.URL})
+	d := StartDispatcher(actor, WebhookConfig{AllowPrivate: true, Backoff: time.Hour})
+
+	actor.CreateItem(ctx, ItemInput{Description: "Buy milk"})
+	waitFor(t, "the first attempt", func() bool { return len(rc.deliveries()) == 1 })
+	closed := make(chan error)
+	go func() { closed <- d.Close(context.Background()) }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close waited for the retry")
+	}
+	// The pending retry is dead-lettered rather than lost.
+	if dead := d.DeadLetters(); len(dead) != 1 || dead[0].Attempts != 1 {
+		t.Errorf("expected the pending delivery dead-lettered, got %+v", dead)
+	}
+}
+
+func TestDispatcher_QueueOverflow(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	slow, other := newReceiver(t, http.StatusBadGateway), newReceiver(t)
+	actor.CreateWebhook(ctx, Webhook{URL: slow.URL})
+	actor.CreateWebhook(ctx, Webhook{URL: other.URL})
+	d := StartDispatcher(actor, WebhookConfig{AllowPrivate: true, Backoff: time.Hour, QueueSize: 1})
+
+	// The first delivery to the slow receiver waits for its retry, the
+	// second waits in the queue and the third does not fit.
+	actor.CreateItem(ctx, ItemInput{Description: "Buy milk"})
+	waitFor(t, "the first attempt", func() bool { return len(slow.deliveries()) == 1 })
+	actor.CreateItem(ctx, ItemInput{Description: "Buy eggs"})
+	actor.CreateItem(ctx, ItemInput{Description: "Buy bread"})
+	waitFor(t, "a dead letter", func() bool { return len(d.DeadLetters()) == 1 })
+	if dl := d.DeadLetters()[0]; dl.Attempts != 0 || !strings.Contains(dl.Error, "queue full") {
+		t.Errorf("expected the overflow dead-lettered unattempted, got %+v", dl)
+	}
+	// The other webhook is not held up.
+	waitFor(t, "the other deliveries", func() bool { return len(other.deliveries()) == 3 })
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if dead := d.DeadLetters(); len(dead) != 3 {
+		t.Errorf("expected the waiting and queued deliveries dead-lettered too, got %+v", dead)
+	}
+}
+
+func TestDispatcher_RefusesPrivateAddresses(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	rc := newReceiver(t) // listens on 127.0.0.1
+	actor.CreateWebhook(ctx, Webhook{URL: rc.URL})
+	d := StartDispatcher(actor, WebhookConfig{Backoff: time.Millisecond})
+	defer d.Close(context.Background())
+
+	actor.CreateItem(ctx, ItemInput{Description: "Buy milk"})
+	waitFor(t, "a dead letter", func() bool { return len(d.DeadLetters()) == 1 })
+	if dl := d.DeadLetters()[0]; dl.Attempts != 1 || !strings.Contains(dl.Error, ErrPrivateAddress.Error()) {
+		t.Errorf("expected one refused attempt, got %+v", dl)
+	}
+	if n := len(rc.deliveries()); n != 0 {
+		t.Errorf("expected nothing to reach the receiver, got %d deliveries", n)
+	}
+}
+
+func TestRefusePrivate(t *testing.T) {
+	for addr, refused := range map[string]bool{
+		"127.0.0.1:80":         true,
+		"[::1]:80":             true,
+		"10.1.2.3:443":         true,
+		"192.168.0.10:80":      true,
+		"172.16.5.4:80":        true,
+		"169.254.169.254:80":   true,
+		"[fe80::1]:80":         true,
+		"[fd00::1]:80":         true,
+		"0.0.0.0:80":           true,
+		"[::ffff:10.0.0.1]:80": true,
+		"93.184.215.14:443":    false,
+		"[2606:4700::1]:443":   false,
+	} {
+		err := refusePrivate("tcp", addr, nil)
+		if got := errors.Is(err, ErrPrivateAddress); got != refused {
+			t.Errorf("refusePrivate(%s) = %v, want refused %v", addr, err, refused)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound is returned when no item has the requested ID.
+	ErrNotFound = errors.New("item not found")
+	// ErrParentNotFound is returned when an item is placed under a parent that does not exist.
+	ErrParentNotFound = errors.New("parent item not found")
+	// ErrCycle is returned when moving an item would make it its own ancestor.
+	ErrCycle = errors.New("item cannot be moved under itself or its own subtask")
+	// ErrDependencyCycle is returned when a new dependency would make an item block itself.
+	ErrDependencyCycle = errors.New("dependency would create a cycle")
+	// ErrBlockerNotFound is returned when a dependency names an item that does not exist.
+	ErrBlockerNotFound = errors.New("blocking item not found")
+	// ErrBlocked is matched by *BlockedError.
+	ErrBlocked = errors.New("item is blocked")
+	// ErrInvalidTransition is matched by *TransitionError.
+	ErrInvalidTransition = errors.New("status transition not allowed")
+	// ErrTrashed is returned when an item is in the trash and has to be restored first.
+	ErrTrashed = errors.New("item is in the trash")
+	// ErrNotTrashed is returned when restoring an item that is not in the trash.
+	ErrNotTrashed = errors.New("item is not in the trash")
+	// ErrParentTrashed is returned when restoring an item whose parent is still in the trash.
+	ErrParentTrashed = errors.New("parent item is in the trash; restore it first")
+	// ErrHasSubtasks is returned when deleting an item with subtasks under DeleteRefuse.
+	ErrHasSubtasks = errors.New("item has subtasks")
+	// ErrNothingToUndo is returned when the undo log is empty.
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// ErrNothingToRedo is returned when there is no undone change to redo.
+	ErrNothingToRedo = errors.New("nothing to redo")
+	// ErrListNotFound is returned when no list has the requested name.
+	ErrListNotFound = errors.New("list not found")
+	// ErrListExists is returned when creating a list whose name is taken.
+	ErrListExists = errors.New("list already exists")
+	// ErrListNotEmpty is returned when deleting a list that still has items, including trashed ones.
+	ErrListNotEmpty = errors.New("list still has items")
+	// ErrUndoConflict is returned when an undo or redo would overwrite a later change.
+	ErrUndoConflict = errors.New("items changed since; cannot undo or redo")
+	// ErrVersionMismatch is returned when a conditional change names a Version the item is no longer at.
+	ErrVersionMismatch = errors.New("item was changed by someone else")
+	// ErrWebhookNotFound is returned when no webhook has the requested ID.
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrPrivateAddress is returned when a webhook delivery would reach a loopback, private or link-local address.
+	ErrPrivateAddress = errors.New("webhook address is loopback, private or link-local")
+	// ErrForbidden is returned when the user asking may not make a change, such as deleting someone else's item.
+	ErrForbidden = errors.New("not allowed")
+	// ErrUserNotFound is returned when no account has the requested ID.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned when creating an account whose ID is taken.
+	ErrUserExists = errors.New("user already exists")
+	// ErrLastAdmin is returned when removing the only admin account.
+	ErrLastAdmin = errors.New("cannot remove the last admin")
+)
+
+// ValidationError reports a field value the store refuses to accept.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
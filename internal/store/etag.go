@@ -0,0 +1,80 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag returns the entity tag of an item, its quoted Version.
+func ETag(it Item) string {
+	return `"` + strconv.Itoa(it.Version) + `"`
+}
+
+// ifMatchVersion reads the If-Match header of a change: the Version the
+// item must still be at, or 0 if the header is absent or "*". Only a single
+// strong ETag can be given; one this server never issued matches nothing.
+func ifMatchVersion(r *http.Request) (int, error) {
+	h := strings.TrimSpace(r.Header.Get("If-Match"))
+	if h == "" || h == "*" {
+		return 0, nil
+	}
+	if strings.Contains(h, ",") {
+		return 0, &ValidationError{Field: "If-Match", Message: "give a single ETag"}
+	}
+	v, err := strconv.Atoi(strings.Trim(h, `"`))
+	if err != nil || v <= 0 || !strings.HasPrefix(h, `"`) {
+		return 0, fmt.Errorf("%w: %s is not an ETag of this server", ErrVersionMismatch, h)
+	}
+	return v, nil
+}
+
+// noneMatch reports whether the If-None-Match header of “r” names “etag”,
+// comparing weakly as RFC 9110 asks.
+func noneMatch(r *http.Request, etag string) bool {
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeItem answers with “it” and its ETag, or with 304 Not Modified if the
+// client already has this version.
+func writeItem(w http.ResponseWriter, r *http.Request, status int, it Item) {
+	etag := ETag(it)
+	w.Header().Set("ETag", etag)
+	if r.Method == http.MethodGet && noneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(it)
+}
+
+// writeTagged answers with the JSON of “v” and an ETag computed from it, or
+// with 304 Not Modified if the client already has the same body.
+func writeTagged(w http.ResponseWriter, r *http.Request, v any) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		writeError(w, err)
+		return
+	}
+	h := fnv.New64a()
+	h.Write(body.Bytes())
+	etag := fmt.Sprintf(`"%x"`, h.Sum64())
+	w.Header().Set("ETag", etag)
+	if noneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body.Bytes())
+}
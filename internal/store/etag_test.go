@@ -0,0 +1,88 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestToDoActor_Versions(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "Draft"})
+	if item.Version != 1 {
+		t.Fatalf("expected a new item at version 1, got %d", item.Version)
+	}
+	desc := "Final"
+	if item, _ = actor.PatchItem(ctx, item.ID, ItemPatch{Description: &desc}); item.Version != 2 {
+		t.Errorf("expected version 2 after a change, got %d", item.Version)
+	}
+	if _, err := actor.PatchItem(ctx, item.ID, ItemPatch{Description: &desc, IfVersion: 1}); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch for a stale version, got %v", err)
+	}
+	if _, err := actor.RemoveItemIfVersion(ctx, item.ID, 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch for a stale delete, got %v", err)
+	}
+
+	// Undoing goes back to the old description but not to the old version.
+	actor.Undo(ctx, 1)
+	if got, _ := actor.GetItem(item.ID); got.Description != "Draft" || got.Version != 3 {
+		t.Errorf("expected the draft at version 3, got %+v", got)
+	}
+	if changes, _ := actor.History(ctx, item.ID); len(changes) != 3 {
+		t.Errorf("expected the version to stay out of the history, got %+v", changes)
+	}
+}
+
+func TestAPI_ETags(t *testing.T) {
+	mux := http.NewServeMux()
+	(&API{Actor: NewToDoActor(nil)}).Routes(mux)
+	// do serves a request with the given header names and values.
+	do := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body)).WithContext(testCtx())
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/items", `{"description":"Task"}`); w.Header().Get("ETag") != `"1"` {
+		t.Fatalf("expected ETag \"1\" on create, got %q", w.Header().Get("ETag"))
+	}
+	if w := do(http.MethodGet, "/items/1", "", "If-None-Match", `"1"`); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected 304 for a current item, got %d", w.Code)
+	}
+	list := do(http.MethodGet, "/items", "")
+	listTag := list.Header().Get("ETag")
+	if w := do(http.MethodGet, "/items", "", "If-None-Match", listTag); listTag == "" || w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for an unchanged list, got %d (ETag %q)", w.Code, listTag)
+	}
+
+	w := do(http.MethodPatch, "/items/1", `{"status":"started"}`, "If-Match", `"1"`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected the matching PATCH to succeed with ETag \"2\", got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+	// The other tab still has version 1.
+	if w := do(http.MethodPatch, "/items/1", `{"description":"Mine"}`, "If-Match", `"1"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for a stale PATCH, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/update", `{"id":1,"description":"Mine"}`, "If-Match", `"1"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for a stale /update, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/items/1", "", "If-Match", `"1"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for a stale DELETE, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/items/1", "", "If-None-Match", `"1"`); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for an outdated copy, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/items", "", "If-None-Match", listTag); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a changed list, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/items/1", "", "If-Match", `"2"`); w.Code != http.StatusNoContent {
+		t.Errorf("expected the matching DELETE to succeed, got %d", w.Code)
+	}
+}
@@ -0,0 +1,230 @@
+package store
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	EventCreated = "created" // a new item
+	EventUpdated = "updated" // any other change, including a restore from the trash
+	EventDeleted = "deleted" // moved to the trash, or purged from it
+)
+
+// DefaultEventBuffer is how many recent events are kept for subscribers
+// that reconnect, unless WithEventBuffer says otherwise.
+const DefaultEventBuffer = 1024
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// it is dropped.
+const subscriberBuffer = 256
+
+// Event reports a change to one item, with the item as the change left it.
+// A purged item is reported as it last was.
+type Event struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Item    Item      `json:"item"`
+	At      time.Time `json:"at"`
+	TraceID string    `json:"trace_id,omitempty"`
+	Actor   string    `json:"actor,omitempty"`
+}
+
+// WithEventBuffer sets how many recent events the actor keeps so that
+// subscribers can resume where they left off; see ToDoActor.Subscribe.
+func WithEventBuffer(n int) ActorOption {
+	return func(a *ToDoActor) {
+		a.eventBuffer = n
+	}
+}
+
+// itemEvents returns the events for the writes of one mutation, where
+// “before” holds the previous version of every written item that existed.
+func itemEvents(by origin, before []Item, entries []JournalEntry, at time.Time) []Event {
+	prior := make(map[int]Item, len(before))
+	for _, it := range before {
+		prior[it.ID] = it
+	}
+	var events []Event
+	for _, e := range entries {
+		ev := Event{At: at, TraceID: by.traceID, Actor: by.actor}
+		switch e.Op {
+		case JournalPut:
+			old, existed := prior[e.Item.ID]
+			ev.Item = *e.Item
+			switch {
+			case !existed:
+				ev.Type = EventCreated
+			case old.DeletedAt.IsZero() && !ev.Item.DeletedAt.IsZero():
+				ev.Type = EventDeleted
+			default:
+				ev.Type = EventUpdated
+			}
+		case JournalDelete:
+			ev.Type = EventDeleted
+			ev.Item = Item{ID: e.ID}
+			if old, ok := prior[e.ID]; ok {
+				ev.Item = old
+			}
+		default:
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// Subscription receives the events published after it started, see
+// ToDoActor.Subscribe.
+type Subscription struct {
+	// C delivers the events in order. It is closed when the subscription
+	// ends: by Close, by CloseSubscriptions, or because the subscriber fell
+	// too far behind, in which case it can subscribe again from the last
+	// event it handled.
+	C <-chan Event
+	// Replay holds the events the subscriber missed since the event it
+	// resumed from, to be handled before those on C.
+	Replay []Event
+	// Missed is set if events after the one it resumed from are no longer
+	// kept, or the ID is unknown: the subscriber should reload everything.
+	Missed bool
+	// LastID is the ID of the last event published before the
+	// subscription started, "" if there was none.
+	LastID string
+
+	ch  chan Event
+	log *eventLog
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+	s.log.drop(s)
+}
+
+// eventLog numbers the events of an actor, keeps the most recent ones and
+// hands them to the subscribers. Event IDs are “epoch-seq”, where the
+// epoch tells one run of the process from another, so that an ID from
+// before a restart is not mistaken for a recent one.
+type eventLog struct {
+	mu     sync.Mutex
+	epoch  string
+	seq    int64
+	size   int
+	recent []Event // the last “size” events, oldest first, possibly more
+	subs   map[*Subscription]bool
+}
+
+func newEventLog(size int) *eventLog {
+	return &eventLog{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:  size,
+		subs:  map[*Subscription]bool{},
+	}
+}
+
+func (l *eventLog) id(seq int64) string {
+	return l.epoch + "-" + strconv.FormatInt(seq, 10)
+}
+
+// publish numbers “events” and sends them to every subscriber. It never
+// waits: a subscriber whose channel is full is dropped instead.
+func (l *eventLog) publish(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, ev := range events {
+		l.seq++
+		ev.ID = l.id(l.seq)
+		if l.size > 0 {
+			l.recent = append(l.recent, ev)
+			// Trim only once the slice has doubled, so that keeping the
+			// window costs a copy every “size” events rather than every one.
+			if len(l.recent) >= 2*l.size {
+				l.recent = append([]Event(nil), l.recent[len(l.recent)-l.size:]...)
+			}
+		}
+		for s := range l.subs {
+			select {
+			case s.ch <- ev:
+			default:
+				slog.Warn("Dropped an event subscriber that fell behind", "last_event_id", ev.ID)
+				l.drop(s)
+			}
+		}
+	}
+}
+
+// drop ends subscription “s”; l.mu must be held.
+func (l *eventLog) drop(s *Subscription) {
+	if l.subs[s] {
+		delete(l.subs, s)
+		close(s.ch)
+	}
+}
+
+func (l *eventLog) subscribe(lastEventID string) *Subscription {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ch := make(chan Event, subscriberBuffer)
+	s := &Subscription{C: ch, ch: ch, log: l}
+	if l.seq > 0 {
+		s.LastID = l.id(l.seq)
+	}
+	if lastEventID != "" {
+		s.Replay, s.Missed = l.since(lastEventID)
+	}
+	l.subs[s] = true
+	return s
+}
+
+// since returns the kept events after the one with ID “id”, and whether
+// some of them are no longer kept.
+func (l *eventLog) since(id string) ([]Event, bool) {
+	epoch, n, _ := strings.Cut(id, "-")
+	seq, err := strconv.ParseInt(n, 10, 64)
+	if epoch != l.epoch || err != nil || seq < 0 || seq > l.seq {
+		return nil, true
+	}
+	if seq == l.seq {
+		return nil, false
+	}
+	kept := l.recent[max(len(l.recent)-l.size, 0):]
+	first := l.seq - int64(len(kept)) + 1 // the sequence number of kept[0]
+	if seq+1 < first {
+		return nil, true
+	}
+	return append([]Event(nil), kept[seq+1-first:]...), false
+}
+
+// closeAll ends every subscription.
+func (l *eventLog) closeAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for s := range l.subs {
+		l.drop(s)
+	}
+}
+
+// Subscribe starts a feed of the events the actor publishes after each
+// change to an item. With a “lastEventID”, the subscription resumes after
+// that event: the events since then that are still kept come first, in
+// Replay. Publishing never waits for a subscriber; one that falls more
+// than a few hundred events behind has its channel closed and must
+// subscribe again. Call Close when done.
+func (a *ToDoActor) Subscribe(lastEventID string) *Subscription {
+	return a.events.subscribe(lastEventID)
+}
+
+// CloseSubscriptions ends every subscription, so that streaming clients
+// disconnect, for instance before the server shuts down.
+func (a *ToDoActor) CloseSubscriptions() {
+	a.events.closeAll()
+}
@@ -0,0 +1,225 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nextEvent returns the next event of “sub”, failing if none comes.
+func nextEvent(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case ev, ok := <-sub.C:
+		if !ok {
+			t.Fatal("subscription ended")
+		}
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("no event published")
+	}
+	return Event{}
+}
+
+func TestToDoActor_Subscribe(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	sub := actor.Subscribe("")
+	defer sub.Close()
+
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "Buy milk"})
+	done := StatusCompleted
+	actor.PatchItem(ctx, item.ID, ItemPatch{Status: &done})
+	actor.RemoveItem(ctx, item.ID)
+	actor.RestoreItem(ctx, item.ID)
+	actor.Undo(ctx, 1)
+	actor.PurgeTrash(ctx, time.Now().Add(time.Hour))
+
+	// Undoing the restore puts the item back in the trash.
+	want := []string{EventCreated, EventUpdated, EventDeleted, EventUpdated, EventDeleted, EventDeleted}
+	var prev string
+	for i, typ := range want {
+		ev := nextEvent(t, sub)
+		if ev.Type != typ || ev.Item.ID != item.ID || ev.ID == prev || ev.TraceID == "" {
+			t.Errorf("event %d: expected %s of item %d, got %+v", i, typ, item.ID, ev)
+		}
+		prev = ev.ID
+	}
+}
+
+func TestToDoActor_SubscribeResume(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil, WithEventBuffer(3))
+	sub := actor.Subscribe("")
+	var ids []string
+	for i := 0; i < 5; i++ {
+		actor.CreateItem(ctx, ItemInput{Description: "Task"})
+		ids = append(ids, nextEvent(t, sub).ID)
+	}
+	sub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Error("expected Close to end the subscription")
+	}
+	sub.Close()
+
+	tests := []struct {
+		name   string
+		last   string
+		replay int
+		missed bool
+	}{
+		{"still kept", ids[2], 2, false},
+		{"up to date", ids[4], 0, false},
+		{"no longer kept", ids[0], 0, true},
+		{"another run", "abc-2", 0, true},
+		{"not an ID", "latest", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := actor.Subscribe(tt.last)
+			defer sub.Close()
+			if len(sub.Replay) != tt.replay || sub.Missed != tt.missed || sub.LastID != ids[4] {
+				t.Errorf("expected %d replayed, missed %v, got %+v", tt.replay, tt.missed, sub)
+			}
+			if tt.replay > 0 && sub.Replay[tt.replay-1].ID != ids[4] {
+				t.Errorf("expected the replay to end with the last event, got %+v", sub.Replay)
+			}
+		})
+	}
+}
+
+func TestToDoActor_SlowSubscriber(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	slow := actor.Subscribe("")
+	defer slow.Close()
+
+	finished := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer+10; i++ {
+			actor.CreateItem(ctx, ItemInput{Description: "Task"})
+		}
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a subscriber that does not read blocked the actor")
+	}
+	n, last := 0, ""
+	for ev := range slow.C {
+		n, last = n+1, ev.ID
+	}
+	if n != subscriberBuffer {
+		t.Errorf("expected the events up to the full buffer before the drop, got %d", n)
+	}
+	// Subscribing again from the last event received catches up.
+	again := actor.Subscribe(last)
+	defer again.Close()
+	if again.Missed || len(again.Replay) != 10 {
+		t.Errorf("expected the 10 dropped events replayed, got %d (missed %v)", len(again.Replay), again.Missed)
+	}
+}
+
+func TestAPI_Events(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	srv := newTestServer(t, actor)
+
+	// stream opens /events and returns its lines.
+	stream := func(ctx context.Context, lastEventID string) *bufio.Scanner {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /events failed: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "text/event-stream" {
+			t.Fatalf("expected an event stream, got %d %q", resp.StatusCode, ct)
+		}
+		return bufio.NewScanner(resp.Body)
+	}
+	// read returns the fields of the next event on “lines”.
+	read := func(lines *bufio.Scanner) map[string]string {
+		t.Helper()
+		fields := map[string]string{}
+		for lines.Scan() {
+			line := lines.Text()
+			if line == "" && len(fields) > 0 {
+				return fields
+			}
+			if name, value, ok := strings.Cut(line, ": "); ok && name != "" {
+				fields[name] = value
+			}
+		}
+		t.Fatalf("stream ended: %v", lines.Err())
+		return nil
+	}
+
+	streamCtx, cancel := context.WithCancel(context.Background())
+	lines := stream(streamCtx, "")
+	actor.CreateItem(ctx, ItemInput{Description: "Buy milk"})
+	first := read(lines)
+	var ev Event
+	json.Unmarshal([]byte(first["data"]), &ev)
+	if first["event"] != EventCreated || ev.ID != first["id"] || ev.Item.Description != "Buy milk" {
+		t.Fatalf("expected a created event, got %v", first)
+	}
+	cancel()
+
+	// Changes made while disconnected are replayed on reconnect.
+	actor.RemoveItem(ctx, ev.Item.ID)
+	actor.RestoreItem(ctx, ev.Item.ID)
+	lines = stream(context.Background(), first["id"])
+	if got := read(lines)["event"]; got != EventDeleted {
+		t.Errorf("expected the delete replayed first, got %q", got)
+	}
+	if got := read(lines)["event"]; got != EventUpdated {
+		t.Errorf("expected the restore replayed next, got %q", got)
+	}
+
+	// An ID the server no longer knows asks the client to start over.
+	if got := read(stream(context.Background(), "old-1"))["event"]; got != "reset" {
+		t.Errorf("expected a reset for an unknown ID, got %q", got)
+	}
+
+	// The server ends the streams when it shuts down.
+	actor.CloseSubscriptions()
+	for lines.Scan() {
+	}
+}
+
+func TestItemEvents_Batch(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "Buy milk"})
+	sub := actor.Subscribe("")
+	defer sub.Close()
+
+	started, desc := StatusStarted, "Buy oat milk"
+	actor.Batch(ctx, []BatchOp{
+		{Op: OpUpdate, ID: item.ID, Patch: ItemPatch{Status: &started}},
+		{Op: OpUpdate, ID: item.ID, Patch: ItemPatch{Description: &desc}},
+		{Op: OpCreate, Input: ItemInput{Description: "Buy eggs"}},
+	})
+	// A batch is published once it is committed, one event per item.
+	if ev := nextEvent(t, sub); ev.Type != EventUpdated || ev.Item.Description != desc || ev.Item.Status != started {
+		t.Errorf("expected the final state of item 1, got %+v", ev)
+	}
+	if ev := nextEvent(t, sub); ev.Type != EventCreated {
+		t.Errorf("expected item 2 created, got %+v", ev)
+	}
+	select {
+	case ev := <-sub.C:
+		t.Errorf("expected no more events, got %+v", ev)
+	default:
+	}
+}
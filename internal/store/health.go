@@ -0,0 +1,42 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// Health answers a process supervisor's probes. The server is live as
+// long as it answers at all; it is ready once its store has loaded
+// (SetActor) and for as long as the last write to storage succeeded.
+type Health struct {
+	actor atomic.Pointer[ToDoActor]
+}
+
+// SetActor marks the store loaded, with “a” serving it.
+func (h *Health) SetActor(a *ToDoActor) {
+	h.actor.Store(a)
+}
+
+// Healthz is the liveness probe, GET /healthz.
+func (h *Health) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// Readyz is the readiness probe, GET /readyz. It answers 503 while the
+// store is loading and after a failed write to storage, until a later
+// write succeeds.
+func (h *Health) Readyz(w http.ResponseWriter, r *http.Request) {
+	a := h.actor.Load()
+	if a == nil {
+		http.Error(w, "not ready: loading the store", http.StatusServiceUnavailable)
+		return
+	}
+	if status := a.FlushStatus(); status.LastError != "" {
+		http.Error(w, "not ready: the last write to storage failed: "+status.LastError, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	h := &Health{}
+	probe := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+	if w := probe(h.Healthz); w.Code != http.StatusOK {
+		t.Errorf("expected the liveness probe to pass while loading, got %d", w.Code)
+	}
+	if w := probe(h.Readyz); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "loading") {
+		t.Errorf("expected 503 while loading, got %d %s", w.Code, w.Body)
+	}
+
+	p := &recordingPersist{}
+	actor := NewToDoActor(nil, WithWriteBehind(p.persist, WriteBehindConfig{Interval: time.Hour}))
+	defer actor.Close(context.Background())
+	h.SetActor(actor)
+	if w := probe(h.Readyz); w.Code != http.StatusOK {
+		t.Errorf("expected ready once loaded, got %d %s", w.Code, w.Body)
+	}
+
+	p.setFail(errors.New("disk full"))
+	actor.AddItem("one")
+	actor.Flush(testCtx())
+	if w := probe(h.Readyz); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "disk full") {
+		t.Errorf("expected 503 after a failed write, got %d %s", w.Code, w.Body)
+	}
+	if w := probe(h.Healthz); w.Code != http.StatusOK {
+		t.Errorf("expected the liveness probe to pass, got %d", w.Code)
+	}
+	p.setFail(nil)
+	actor.Flush(testCtx())
+	if w := probe(h.Readyz); w.Code != http.StatusOK {
+		t.Errorf("expected ready again after a successful write, got %d %s", w.Code, w.Body)
+	}
+}
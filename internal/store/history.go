@@ -0,0 +1,144 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Pseudo-fields for history records about a whole item rather than one of its fields.
+const (
+	FieldCreated = "created" // New holds the description
+	FieldPurged  = "purged"  // the item was removed from the trash for good
+)
+
+// Change is one record of an item's history: a field moving from Old to New.
+// Values are kept as the JSON they have in the item, so they read the same
+// as in the API and survive later changes to the Item type.
+type Change struct {
+	ItemID  int             `json:"item_id"`
+	At      time.Time       `json:"at"`
+	TraceID string          `json:"trace_id,omitempty"`
+	Actor   string          `json:"actor,omitempty"` // who asked for the change, see IdentityKey
+	Field   string          `json:"field"`           // JSON name of the field, or FieldCreated/FieldPurged
+	Old     json.RawMessage `json:"old,omitempty"`   // absent when the field was unset
+	New     json.RawMessage `json:"new,omitempty"`   // absent when the field was cleared
+}
+
+// WithHistory seeds the actor with the history loaded from storage.
+func WithHistory(history []Change) ActorOption {
+	return func(a *ToDoActor) {
+		a.history = history
+	}
+}
+
+// origin says who asked for a change; the actor copies it into the history.
+type origin struct {
+	traceID string
+	actor   string // recorded in the history
+	user    string // whose permissions apply, see UserKey; SystemIdentity for the store itself
+}
+
+func originOf(ctx context.Context) origin {
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	actor, _ := ctx.Value(IdentityKey).(string)
+	return origin{traceID: traceID, actor: actor, user: UserFromContext(ctx)}
+}
+
+// untracked are the fields the actor maintains itself; recording them would
+// only repeat what the other fields of the same change already say.
+var untracked = []string{"id", "created_at", "updated_at", "started_at", "completed_at", "version"}
+
+// diffItems returns a record for every field that differs between “before”
+// and “after”, in alphabetical order.
+func diffItems(before, after Item, by origin, at time.Time) []Change {
+	old, cur := fieldsOf(before), fieldsOf(after)
+	var names []string
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range cur {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	var out []Change
+	for _, name := range names {
+		if slices.Contains(untracked, name) || bytes.Equal(old[name], cur[name]) {
+			continue
+		}
+		out = append(out, Change{
+			ItemID:  after.ID,
+			At:      at,
+			TraceID: by.traceID,
+			Actor:   by.actor,
+			Field:   name,
+			Old:     old[name],
+			New:     cur[name],
+		})
+	}
+	return out
+}
+
+// fieldsOf returns the JSON encoding of each field of “it” that is set.
+func fieldsOf(it Item) map[string]json.RawMessage {
+	data, err := json.Marshal(it)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// recordChanges returns the history records for the writes of one mutation.
+// “before” holds the previous version of every written item that existed.
+func recordChanges(by origin, before []Item, entries []JournalEntry, at time.Time) []Change {
+	prior := make(map[int]Item, len(before))
+	for _, it := range before {
+		prior[it.ID] = it
+	}
+	var out []Change
+	for _, e := range entries {
+		switch e.Op {
+		case JournalPut:
+			if old, ok := prior[e.Item.ID]; ok {
+				out = append(out, diffItems(old, *e.Item, by, at)...)
+				continue
+			}
+			desc, _ := json.Marshal(e.Item.Description)
+			out = append(out, Change{ItemID: e.Item.ID, At: at, TraceID: by.traceID, Actor: by.actor, Field: FieldCreated, New: desc})
+		case JournalDelete:
+			out = append(out, Change{ItemID: e.ID, At: at, TraceID: by.traceID, Actor: by.actor, Field: FieldPurged})
+		}
+	}
+	return out
+}
+
+func historyEntry(c Change) JournalEntry {
+	return JournalEntry{Op: JournalHistory, Change: &c}
+}
+
+// historyOf returns the records about item “id”, oldest first.
+func historyOf(history []Change, id int) []Change {
+	var out []Change
+	for _, c := range history {
+		if c.ItemID == id {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// itemHistory replies with the changes to an item, oldest first.
+func (s *actorState) itemHistory(m historyMsg) {
+	changes := historyOf(s.history, m.id)
+	if len(changes) == 0 && s.find(m.id) < 0 {
+		m.reply <- historyReply{err: ErrNotFound}
+		return
+	}
+	m.reply <- historyReply{changes: changes}
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// itemWrites keeps only the item puts and deletes of what a change listener saw.
+func itemWrites(entries []JournalEntry) []JournalEntry {
+	var out []JournalEntry
+	for _, e := range entries {
+		if e.Op == JournalPut || e.Op == JournalDelete {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestToDoActor_RecordsHistory(t *testing.T) {
+	ctx := context.WithValue(testCtx(), IdentityKey, "cli:alice")
+	actor := NewToDoActor(nil)
+
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "Draft"})
+	desc, status := "Final", StatusStarted
+	if _, err := actor.PatchItem(ctx, item.ID, ItemPatch{Description: &desc, Status: &status}); err != nil {
+		t.Fatalf("PatchItem failed: %v", err)
+	}
+	actor.RemoveItem(ctx, item.ID)
+
+	changes, err := actor.History(ctx, item.ID)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	var fields []string
+	for _, c := range changes {
+		fields = append(fields, c.Field)
+		if c.Actor != "cli:alice" || c.TraceID != "test-trace-id" || c.At.IsZero() {
+			t.Errorf("expected actor, trace ID and time on %+v", c)
+		}
+	}
+	want := []string{FieldCreated, "description", "status", "deleted_at"}
+	if len(fields) != len(want) {
+		t.Fatalf("expected fields %v, got %v", want, fields)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Fatalf("expected fields %v, got %v", want, fields)
+		}
+	}
+	if string(changes[1].Old) != `"Draft"` || string(changes[1].New) != `"Final"` {
+		t.Errorf("expected old and new description, got %s → %s", changes[1].Old, changes[1].New)
+	}
+
+	if _, err := actor.History(ctx, 42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown item, got %v", err)
+	}
+}
+
+func TestSnapshot_HistoryRoundTrip(t *testing.T) {
+	ctx := testCtx()
+	path := filepath.Join(t.TempDir(), "todos.json")
+	s := NewJSONFileStorage(path)
+	s.Journal = true
+
+	var changes []JournalEntry
+	actor := NewToDoActor(nil, WithChangeListener(func(e JournalEntry) { changes = append(changes, e) }))
+	actor.CreateItem(ctx, ItemInput{Description: "Journaled"})
+	if err := s.Apply(ctx, changes...); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	snap, err := s.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(snap.Items) != 1 || len(snap.History) != 1 || snap.History[0].Field != FieldCreated {
+		t.Fatalf("expected the item and its history from the journal, got %+v", snap)
+	}
+	if err := s.Save(ctx, snap); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	reloaded := NewToDoActor(snap.Items, WithHistory(snap.History))
+	got, _ := reloaded.History(ctx, 1)
+	var desc string
+	if len(got) != 1 || json.Unmarshal(got[0].New, &desc) != nil || desc != "Journaled" {
+		t.Errorf("expected history to survive a reload, got %+v", got)
+	}
+}
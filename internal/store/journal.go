@@ -0,0 +1,278 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Journal operations.
+const (
+	JournalPut           = "put"
+	JournalDelete        = "delete"
+	JournalHistory       = "history"
+	JournalStep          = "step"           // a new undoable step; clears the redo stack
+	JournalUndo          = "undo"           // moves the last undo step, as undone, to the redo stack
+	JournalRedo          = "redo"           // moves the last redo step, as redone, back to the undo stack
+	JournalList          = "list"           // creates a list
+	JournalListDelete    = "delete-list"    // removes a list
+	JournalWebhook       = "webhook"        // registers a webhook
+	JournalWebhookDelete = "delete-webhook" // removes a webhook
+	JournalUser          = "user"           // adds or changes a user account
+	JournalUserDelete    = "delete-user"    // removes a user account
+)
+
+// JournalEntry is one line of the append-only journal kept next to a JSON
+// store, and the unit in which the actor reports its writes.
+type JournalEntry struct {
+	Op      string    `json:"op"`                // one of the Journal constants
+	Item    *Item     `json:"item,omitempty"`    // the full item, for puts
+	ID      int       `json:"id,omitempty"`      // the removed ID, for deletes
+	Change  *Change   `json:"change,omitempty"`  // the appended record, for history
+	Step    *UndoStep `json:"step,omitempty"`    // the recorded step, for step; as undone or redone, for undo and redo
+	List    *List     `json:"list,omitempty"`    // the list, for list and delete-list
+	Webhook *Webhook  `json:"webhook,omitempty"` // the webhook, for webhook and delete-webhook
+	User    *User     `json:"user,omitempty"`    // the account, for user and delete-user
+}
+
+// apply returns “snap” with the entry applied to it.
+func (e JournalEntry) apply(snap Snapshot) Snapshot {
+	switch e.Op {
+	case JournalPut:
+		snap.Items = putItem(snap.Items, *e.Item)
+		snap.LastID = max(snap.LastID, e.Item.ID)
+	case JournalDelete:
+		snap.Items = removeItem(snap.Items, e.ID)
+	case JournalHistory:
+		snap.History = append(snap.History, *e.Change)
+	case JournalStep:
+		snap.Undo = trimSteps(append(snap.Undo, *e.Step))
+		snap.Redo = nil
+	case JournalUndo:
+		if n := len(snap.Undo); n > 0 {
+			snap.Undo, snap.Redo = moveStep(snap.Undo, snap.Redo, e.stepOr(snap.Undo[n-1]), stateBefore, stateAfter)
+		}
+	case JournalRedo:
+		if n := len(snap.Redo); n > 0 {
+			snap.Redo, snap.Undo = moveStep(snap.Redo, snap.Undo, e.stepOr(snap.Redo[n-1]), stateAfter, stateBefore)
+		}
+	case JournalList:
+		snap.Lists = putList(snap.Lists, *e.List)
+	case JournalListDelete:
+		snap.Lists = removeList(snap.Lists, e.List.Name)
+	case JournalWebhook:
+		snap.Webhooks = putWebhook(snap.Webhooks, *e.Webhook)
+	case JournalWebhookDelete:
+		snap.Webhooks = removeWebhook(snap.Webhooks, e.Webhook.ID)
+	case JournalUser:
+		snap.Users = putUser(snap.Users, *e.User)
+	case JournalUserDelete:
+		snap.Users = removeUser(snap.Users, e.User.ID)
+	}
+	return snap
+}
+
+// stepOr returns the step an undo or redo entry carries, or “moved” for
+// entries written before they carried one.
+func (e JournalEntry) stepOr(moved UndoStep) UndoStep {
+	if e.Step != nil {
+		return *e.Step
+	}
+	return moved
+}
+
+func (e JournalEntry) validate() error {
+	switch e.Op {
+	case JournalPut:
+		if e.Item == nil {
+			return errors.New("put entry without item")
+		}
+	case JournalDelete:
+	case JournalHistory:
+		if e.Change == nil {
+			return errors.New("history entry without change")
+		}
+	case JournalStep:
+		if e.Step == nil {
+			return errors.New("step entry without step")
+		}
+	case JournalUndo, JournalRedo:
+	case JournalList, JournalListDelete:
+		if e.List == nil {
+			return errors.New("list entry without list")
+		}
+	case JournalWebhook, JournalWebhookDelete:
+		if e.Webhook == nil {
+			return errors.New("webhook entry without webhook")
+		}
+	case JournalUser, JournalUserDelete:
+		if e.User == nil {
+			return errors.New("user entry without user")
+		}
+	default:
+		return fmt.Errorf("unknown journal op %q", e.Op)
+	}
+	return nil
+}
+
+// journalPath returns the journal file that belongs to the snapshot “filename”.
+func journalPath(filename string) string {
+	return filename + ".journal"
+}
+
+// AppendJournal appends entries to the journal of “filename” and fsyncs it, so
+// each call is durable once it returns. A torn final line, as left by a crash
+// mid-append, is cut off first so that the entries start on a clean line;
+// callers hold the store's lock, as for any other write.
+func AppendJournal(ctx context.Context, filename string, entries ...JournalEntry) error {
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(journalPath(filename), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		slog.Error("Failed to open journal",
+			"file", journalPath(filename),
+			"error", err,
+			"traceID", traceID,
+		)
+		return err
+	}
+	if err := cutTornTail(ctx, f); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		slog.Error("Failed to append to journal",
+			"file", journalPath(filename),
+			"error", err,
+			"traceID", traceID,
+		)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// replayJournal applies the journal of “filename” (if any) on top of “snap”.
+// A torn final line, as left by a crash mid-append, is skipped; corruption
+// anywhere else is an error. It returns the resulting snapshot and the length
+// of the valid prefix of the journal. Loading only reads: the next append
+// cuts the torn line off, under the lock (see AppendJournal).
+func replayJournal(ctx context.Context, filename string, snap Snapshot) (Snapshot, int64, error) {
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	f, err := os.Open(journalPath(filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return snap, 0, nil
+		}
+		return Snapshot{}, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	applied := 0
+	var valid int64 // offset just past the last complete line
+	for lineNo := 1; ; lineNo++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				slog.Warn("Skipping incomplete journal entry",
+					"file", journalPath(filename),
+					"line", lineNo,
+					"traceID", traceID,
+				)
+			}
+			break
+		}
+		if err != nil {
+			return Snapshot{}, 0, err
+		}
+		valid += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e JournalEntry
+		err = json.Unmarshal(line, &e)
+		if err == nil {
+			err = e.validate()
+		}
+		if err != nil {
+			slog.Error("Corrupt journal entry",
+				"file", journalPath(filename),
+				"line", lineNo,
+				"error", err,
+				"traceID", traceID,
+			)
+			return Snapshot{}, 0, fmt.Errorf("journal %s line %d: %w", journalPath(filename), lineNo, err)
+		}
+		snap = e.apply(snap)
+		applied++
+	}
+	if applied > 0 {
+		slog.Info("Replayed journal",
+			"file", journalPath(filename),
+			"entries", applied,
+			"traceID", traceID,
+		)
+	}
+	return snap, valid, nil
+}
+
+// cutTornTail truncates the journal “f” to just past its last complete line.
+func cutTornTail(ctx context.Context, f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	end := size
+	buf := make([]byte, 4096)
+	for end > 0 {
+		n := min(int64(len(buf)), end)
+		if _, err := f.ReadAt(buf[:n], end-n); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			end += int64(i) + 1 - n
+			break
+		}
+		end -= n
+	}
+	if end == size {
+		return nil
+	}
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	slog.Warn("Discarding incomplete journal entry",
+		"file", f.Name(),
+		"bytes", size-end,
+		"traceID", traceID,
+	)
+	return f.Truncate(end)
+}
+
+// countJournal returns how many complete entries the journal of “filename” holds.
+func countJournal(filename string) (int, error) {
+	data, err := os.ReadFile(journalPath(filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return bytes.Count(data, []byte{'\n'}), nil
+}
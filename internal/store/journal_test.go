@@ -0,0 +1,143 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func journalCtx() context.Context {
+	return context.WithValue(context.Background(), TraceIDKey, "test-trace-id")
+}
+
+func TestJournal_ReplayOnLoad(t *testing.T) {
+	ctx := journalCtx()
+	path := filepath.Join(t.TempDir(), "todos.json")
+	if err := SaveItems(ctx, path, []Item{{ID: 1, Description: "snapshot"}}); err != nil {
+		t.Fatalf("SaveItems failed: %v", err)
+	}
+
+	s := NewJSONFileStorage(path)
+	s.Journal = true
+	if err := s.Put(ctx, Item{ID: 2, Description: "journaled"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Put(ctx, Item{ID: 1, Description: "edited"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Delete(ctx, 2); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	items, err := LoadItems(ctx, path)
+	if err != nil {
+		t.Fatalf("LoadItems failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Description != "edited" {
+		t.Errorf("unexpected items after replay: %+v", items)
+	}
+}
+
+func TestJournal_TornTailIsDiscarded(t *testing.T) {
+	ctx := journalCtx()
+	path := filepath.Join(t.TempDir(), "todos.json")
+	if err := AppendJournal(ctx, path, JournalEntry{Op: JournalPut, Item: &Item{ID: 1, Description: "ok"}}); err != nil {
+		t.Fatalf("AppendJournal failed: %v", err)
+	}
+	f, err := os.OpenFile(journalPath(path), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	info, _ := f.Stat()
+	valid := info.Size()
+	f.WriteString(`{"op":"put","item":{"id":2,"desc`)
+	f.Close()
+	torn, _ := os.ReadFile(journalPath(path))
+
+	items, err := LoadItems(ctx, path)
+	if err != nil {
+		t.Fatalf("LoadItems failed: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != 1 {
+		t.Errorf("unexpected items: %+v", items)
+	}
+	// Loading reports the valid prefix but leaves the file alone.
+	if _, n, err := replayJournal(ctx, path, Snapshot{}); err != nil || n != valid {
+		t.Errorf("expected a valid prefix of %d bytes, got %d (%v)", valid, n, err)
+	}
+	if data, _ := os.ReadFile(journalPath(path)); !bytes.Equal(data, torn) {
+		t.Errorf("expected the journal untouched by loading, got %q", data)
+	}
+
+	// The next append must start on a clean line.
+	if err := AppendJournal(ctx, path, JournalEntry{Op: JournalPut, Item: &Item{ID: 3, Description: "after"}}); err != nil {
+		t.Fatalf("AppendJournal failed: %v", err)
+	}
+	items, err = LoadItems(ctx, path)
+	if err != nil {
+		t.Fatalf("LoadItems after append failed: %v", err)
+	}
+	if len(items) != 2 {
+		t.Errorf("expected 2 items, got %+v", items)
+	}
+}
+
+func TestJournal_CorruptEntryIsError(t *testing.T) {
+	ctx := journalCtx()
+	path := filepath.Join(t.TempDir(), "todos.json")
+	data := "{not json}\n" + `{"op":"put","item":{"id":1}}` + "\n"
+	if err := os.WriteFile(journalPath(path), []byte(data), 0644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	if _, err := LoadItems(ctx, path); err == nil {
+		t.Fatal("expected error for corrupt journal, got nil")
+	}
+}
+
+func TestJournal_CompactsIntoSnapshot(t *testing.T) {
+	ctx := journalCtx()
+	path := filepath.Join(t.TempDir(), "todos.json")
+	s := NewJSONFileStorage(path)
+	s.Journal = true
+	s.CompactEvery = 3
+
+	for id := 1; id <= 3; id++ {
+		if err := s.Put(ctx, Item{ID: id, Description: "item"}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if _, err := os.Stat(journalPath(path)); !os.IsNotExist(err) {
+		t.Errorf("expected journal to be removed after compaction, stat err = %v", err)
+	}
+	items, err := LoadItems(ctx, path)
+	if err != nil {
+		t.Fatalf("LoadItems failed: %v", err)
+	}
+	if len(items) != 3 {
+		t.Errorf("expected 3 items in snapshot, got %d", len(items))
+	}
+}
+
+func TestSaveItems_LeavesNoTempFiles(t *testing.T) {
+	ctx := journalCtx()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todos.json")
+	for i := 0; i < 3; i++ {
+		if err := SaveItems(ctx, path, []Item{{ID: i + 1}}); err != nil {
+			t.Fatalf("SaveItems failed: %v", err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "todos.json" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("expected only todos.json, found %v", names)
+	}
+}
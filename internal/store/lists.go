@@ -0,0 +1,151 @@
+package store
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultList is the list items belong to unless another one is named. It
+// always exists and cannot be deleted.
+const DefaultList = "default"
+
+// List is a named list of items, such as a project. Every item belongs to
+// exactly one list, together with its subtasks; dependencies may cross lists.
+type List struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// ListSummary is a List with a count of its items.
+type ListSummary struct {
+	List
+	Items   int `json:"items"`   // items outside the trash
+	Trashed int `json:"trashed"` // items in the trash
+}
+
+// WithLists seeds the actor with the lists loaded from storage. The default
+// list, and any list an item names, exist whether or not they are given.
+func WithLists(lists []List) ActorOption {
+	return func(a *ToDoActor) {
+		a.lists = lists
+	}
+}
+
+var listNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// normalizeListName trims “name” and checks that it can be used in a URL
+// path: letters, digits, dots, dashes and underscores, at most 64 of them.
+func normalizeListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if !listNamePattern.MatchString(name) {
+		return "", &ValidationError{Field: "list", Message: fmt.Sprintf("%q is not a valid list name: use up to 64 letters, digits, '.', '-' or '_'", name)}
+	}
+	return name, nil
+}
+
+// initLists returns the lists the actor starts with: the default list
+// first, then “lists” and any list named by an item. Items that name no
+// list are put in the default list.
+func initLists(lists []List, items []Item) []List {
+	if !slices.ContainsFunc(lists, func(l List) bool { return l.Name == DefaultList }) {
+		lists = append([]List{{Name: DefaultList}}, lists...)
+	} else {
+		lists = slices.Clone(lists)
+	}
+	add := func(name string) {
+		if !slices.ContainsFunc(lists, func(l List) bool { return l.Name == name }) {
+			lists = append(lists, List{Name: name})
+		}
+	}
+	for i := range items {
+		if items[i].List == "" {
+			items[i].List = DefaultList
+		}
+		add(items[i].List)
+	}
+	return lists
+}
+
+// summarizeLists counts the items of every list.
+func summarizeLists(lists []List, items []Item) []ListSummary {
+	out := make([]ListSummary, len(lists))
+	for i, l := range lists {
+		out[i].List = l
+		for _, it := range items {
+			switch {
+			case it.List != l.Name:
+			case it.DeletedAt.IsZero():
+				out[i].Items++
+			default:
+				out[i].Trashed++
+			}
+		}
+	}
+	return out
+}
+
+// itemsInList returns the items of list “name”, or every item if name is "".
+func itemsInList(items []Item, name string) []Item {
+	if name == "" {
+		return items
+	}
+	var out []Item
+	for _, it := range items {
+		if it.List == name {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func putList(lists []List, l List) []List {
+	for i := range lists {
+		if lists[i].Name == l.Name {
+			lists[i] = l
+			return lists
+		}
+	}
+	return append(lists, l)
+}
+
+func removeList(lists []List, name string) []List {
+	return slices.DeleteFunc(lists, func(l List) bool { return l.Name == name })
+}
+
+// summarizeLists replies with every list and its item counts.
+func (s *actorState) summarizeLists(m listsMsg) {
+	m.reply <- summarizeLists(s.lists, s.items)
+}
+
+// createList adds an empty list.
+func (s *actorState) createList(m createListMsg) {
+	if s.hasList(m.name) {
+		m.reply <- listReply{err: ErrListExists}
+		return
+	}
+	l := List{Name: m.name, CreatedAt: time.Now()}
+	s.lists = append(s.lists, l)
+	s.a.changed(JournalEntry{Op: JournalList, List: &l})
+	m.reply <- listReply{list: l}
+}
+
+// deleteList removes an empty list other than the default one.
+func (s *actorState) deleteList(m deleteListMsg) {
+	switch {
+	case m.name == DefaultList:
+		m.reply <- listReply{err: &ValidationError{Field: "list", Message: "the default list cannot be deleted"}}
+		return
+	case !s.hasList(m.name):
+		m.reply <- listReply{err: ErrListNotFound}
+		return
+	case len(itemsInList(s.items, m.name)) > 0:
+		m.reply <- listReply{err: ErrListNotEmpty}
+		return
+	}
+	s.lists = removeList(s.lists, m.name)
+	s.a.changed(JournalEntry{Op: JournalListDelete, List: &List{Name: m.name}})
+	m.reply <- listReply{list: List{Name: m.name}}
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestToDoActor_Lists(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor([]Item{{ID: 1, Description: "Legacy"}})
+	if _, err := actor.CreateList(ctx, "work"); err != nil {
+		t.Fatalf("CreateList failed: %v", err)
+	}
+	if _, err := actor.CreateList(ctx, "work"); !errors.Is(err, ErrListExists) {
+		t.Errorf("expected ErrListExists, got %v", err)
+	}
+	var ve *ValidationError
+	if _, err := actor.CreateList(ctx, "no/slashes"); !errors.As(err, &ve) {
+		t.Errorf("expected a ValidationError for a bad name, got %v", err)
+	}
+
+	parent, err := actor.CreateItem(ctx, ItemInput{Description: "Ship it", List: "work"})
+	if err != nil || parent.List != "work" {
+		t.Fatalf("expected the item in the work list, got %+v: %v", parent, err)
+	}
+	sub, _ := actor.CreateItem(ctx, ItemInput{Description: "Tag release", ParentID: parent.ID})
+	if sub.List != "work" {
+		t.Errorf("expected a subtask to join its parent's list, got %q", sub.List)
+	}
+	if _, err := actor.CreateItem(ctx, ItemInput{Description: "Stray", ParentID: parent.ID, List: DefaultList}); !errors.As(err, &ve) {
+		t.Errorf("expected a ValidationError for a subtask in another list, got %v", err)
+	}
+	if _, err := actor.CreateItem(ctx, ItemInput{Description: "Lost", List: "home"}); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("expected ErrListNotFound, got %v", err)
+	}
+
+	if views, _ := actor.ListTree(DefaultList, false); len(views) != 1 || views[0].List != DefaultList {
+		t.Errorf("expected the legacy item alone in the default list, got %+v", views)
+	}
+	if views, _ := actor.ListTree("work", false); len(views) != 2 {
+		t.Errorf("expected two items in the work list, got %+v", views)
+	}
+	if views := actor.Tree(); len(views) != 3 {
+		t.Errorf("expected the all-items view to span lists, got %+v", views)
+	}
+	if _, err := actor.ListTree("home", false); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("expected ErrListNotFound, got %v", err)
+	}
+
+	if err := actor.DeleteList(ctx, "work"); !errors.Is(err, ErrListNotEmpty) {
+		t.Errorf("expected ErrListNotEmpty, got %v", err)
+	}
+	if err := actor.DeleteList(ctx, DefaultList); !errors.As(err, &ve) {
+		t.Errorf("expected the default list to be undeletable, got %v", err)
+	}
+	summaries := actor.Lists()
+	if len(summaries) != 2 || summaries[0].Name != DefaultList || summaries[1].Items != 2 {
+		t.Errorf("unexpected list summaries: %+v", summaries)
+	}
+}
+
+func TestToDoActor_MoveItemToList(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	actor.CreateList(ctx, "work")
+	parent, _ := actor.CreateItem(ctx, ItemInput{Description: "Parent"})
+	sub, _ := actor.CreateItem(ctx, ItemInput{Description: "Sub", ParentID: parent.ID})
+
+	moved, err := actor.MoveItemToList(ctx, sub.ID, "work", 0)
+	if err != nil || moved.List != "work" || moved.ParentID != 0 {
+		t.Fatalf("expected the subtask at the top of the work list, got %+v: %v", moved, err)
+	}
+	if _, err := actor.MoveItemToList(ctx, parent.ID, "work", 0); err != nil {
+		t.Fatalf("MoveItemToList failed: %v", err)
+	}
+	// Moving under a parent in another list follows the parent.
+	if moved, err = actor.MoveItem(ctx, sub.ID, parent.ID); err != nil || moved.List != "work" {
+		t.Fatalf("expected the item to follow its parent, got %+v: %v", moved, err)
+	}
+	if _, err := actor.MoveItemToList(ctx, parent.ID, DefaultList, 0); err != nil {
+		t.Fatalf("MoveItemToList failed: %v", err)
+	}
+	if got, _ := actor.GetItem(sub.ID); got.List != DefaultList {
+		t.Errorf("expected the subtask to move with its parent, got %q", got.List)
+	}
+	if err := actor.DeleteList(ctx, "work"); err != nil {
+		t.Errorf("expected the emptied list to be deletable, got %v", err)
+	}
+}
+
+func TestSnapshot_ListsRoundTrip(t *testing.T) {
+	ctx := testCtx()
+	path := filepath.Join(t.TempDir(), "todos.json")
+	s := NewJSONFileStorage(path)
+	s.Journal = true
+
+	var changes []JournalEntry
+	actor := NewToDoActor(nil, WithChangeListener(func(e JournalEntry) { changes = append(changes, e) }))
+	actor.CreateList(ctx, "work")
+	actor.CreateList(ctx, "scratch")
+	actor.CreateItem(ctx, ItemInput{Description: "Report", List: "work"})
+	actor.DeleteList(ctx, "scratch")
+	if err := s.Apply(ctx, changes...); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	snap, err := s.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	reloaded := NewToDoActor(snap.Items, WithLists(snap.Lists))
+	lists := reloaded.Lists()
+	if len(lists) != 2 || lists[1].Name != "work" || lists[1].Items != 1 {
+		t.Errorf("expected the work list and its item after a reload, got %+v", lists)
+	}
+}
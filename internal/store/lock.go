@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrLocked is returned when another process holds the lock on a store.
+var ErrLocked = errors.New("store is locked by another process")
+
+// LockOwner describes the process holding a store's lock. It is written into
+// the lock file so that other processes can tell who they are waiting for.
+type LockOwner struct {
+	PID    int       `json:"pid"`
+	Server string    `json:"server,omitempty"` // base URL when the owner is an API server
+	Since  time.Time `json:"since"`
+}
+
+// LockedError reports a lock held by someone else. It matches ErrLocked with errors.Is.
+type LockedError struct {
+	Path  string
+	Owner *LockOwner // nil if the owner could not be determined
+}
+
+func (e *LockedError) Error() string {
+	switch {
+	case e.Owner == nil:
+		return fmt.Sprintf("%s is locked by another process", e.Path)
+	case e.Owner.Server != "":
+		return fmt.Sprintf("%s is owned by a running server at %s (pid %d)", e.Path, e.Owner.Server, e.Owner.PID)
+	default:
+		return fmt.Sprintf("%s is locked by pid %d", e.Path, e.Owner.PID)
+	}
+}
+
+func (e *LockedError) Is(target error) bool { return target == ErrLocked }
+
+// FileLock is an advisory, cross-process lock on a store file. It is held
+// on “filename.lock” so that the data file itself can be replaced by rename.
+type FileLock struct {
+	f    *os.File
+	path string
+}
+
+// lockPath returns the lock file that guards “filename”.
+func lockPath(filename string) string {
+	return filename + ".lock"
+}
+
+// TryLock takes the lock on “filename” without waiting. If another process
+// holds it, the error is a *LockedError.
+func TryLock(filename string, owner LockOwner) (*FileLock, error) {
+	path := lockPath(filename)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, ErrLocked) {
+			locked := &LockedError{Path: filename}
+			if o, err := ReadLockOwner(filename); err == nil {
+				locked.Owner = &o
+			}
+			return nil, locked
+		}
+		return nil, err
+	}
+	if owner.Since.IsZero() {
+		owner.Since = time.Now()
+	}
+	data, _ := json.Marshal(owner)
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt(data, 0)
+	}
+	return &FileLock{f: f, path: path}, nil
+}
+
+// Lock takes the lock on “filename”, polling until it is free or ctx is done.
+// On timeout the last *LockedError is returned.
+func Lock(ctx context.Context, filename string, owner LockOwner, poll time.Duration) (*FileLock, error) {
+	for {
+		l, err := TryLock(filename, owner)
+		if !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(poll):
+		}
+	}
+}
+
+// Unlock clears the owner record and releases the lock.
+func (l *FileLock) Unlock() error {
+	l.f.Truncate(0)
+	if err := unlockFile(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// ReadLockOwner returns the owner recorded in the lock file of “filename”.
+// The record is only meaningful while the lock is actually held.
+func ReadLockOwner(filename string) (LockOwner, error) {
+	var owner LockOwner
+	data, err := os.ReadFile(lockPath(filename))
+	if err != nil {
+		return owner, err
+	}
+	if len(data) == 0 {
+		return owner, errors.New("lock file has no owner record")
+	}
+	err = json.Unmarshal(data, &owner)
+	return owner, err
+}
@@ -0,0 +1,24 @@
+//go:build !unix
+
+package store
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+)
+
+var warnNoLockOnce sync.Once
+
+// lockFile is a no-op on platforms without flock; concurrent invocations
+// are not protected there.
+func lockFile(f *os.File) error {
+	warnNoLockOnce.Do(func() {
+		slog.Warn("File locking is not supported on this platform; concurrent runs may overwrite each other")
+	})
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,70 @@
+//go:build unix
+
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTryLock_ConflictReportsOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	l, err := TryLock(path, LockOwner{PID: 42, Server: "http://localhost:8080"})
+	if err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	defer l.Unlock()
+
+	_, err = TryLock(path, LockOwner{PID: 43})
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.Owner == nil {
+		t.Fatalf("expected LockedError with owner, got %#v", err)
+	}
+	if locked.Owner.PID != 42 || locked.Owner.Server != "http://localhost:8080" {
+		t.Errorf("unexpected owner: %+v", locked.Owner)
+	}
+}
+
+func TestLock_WaitsForRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	first, err := TryLock(path, LockOwner{PID: 1})
+	if err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		first.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	second, err := Lock(ctx, path, LockOwner{PID: 2}, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	defer second.Unlock()
+	if owner, err := ReadLockOwner(path); err != nil || owner.PID != 2 {
+		t.Errorf("expected owner pid 2, got %+v (err %v)", owner, err)
+	}
+}
+
+func TestLock_TimesOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	held, err := TryLock(path, LockOwner{PID: 1})
+	if err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	defer held.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := Lock(ctx, path, LockOwner{PID: 2}, 5*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked after timeout, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStorage is a Storage that keeps the list in memory. It is useful for
+// tests and for throwaway servers; nothing survives the process.
+type MemoryStorage struct {
+	mu   sync.Mutex
+	snap Snapshot
+}
+
+// NewMemoryStorage returns a MemoryStorage seeded with a copy of “initial”.
+func NewMemoryStorage(initial []Item) *MemoryStorage {
+	return &MemoryStorage{snap: Snapshot{Items: cloneItems(initial)}}
+}
+
+func (s *MemoryStorage) Load(ctx context.Context) (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneSnapshot(s.snap), nil
+}
+
+func (s *MemoryStorage) Save(ctx context.Context, snap Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snap = cloneSnapshot(snap)
+	return nil
+}
+
+func (s *MemoryStorage) Apply(ctx context.Context, entries ...JournalEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entries {
+		s.snap = e.apply(s.snap)
+	}
+	return nil
+}
+
+func (s *MemoryStorage) Put(ctx context.Context, item Item) error {
+	return s.Apply(ctx, putEntry(item))
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, id int) error {
+	return s.Apply(ctx, deleteEntry(id))
+}
+
+func cloneItems(items []Item) []Item {
+	cp := make([]Item, len(items))
+	copy(cp, items)
+	return cp
+}
+
+func cloneSnapshot(snap Snapshot) Snapshot {
+	return Snapshot{
+		Items:    cloneItems(snap.Items),
+		History:  append([]Change(nil), snap.History...),
+		Undo:     append([]UndoStep(nil), snap.Undo...),
+		Redo:     append([]UndoStep(nil), snap.Redo...),
+		LastID:   snap.LastID,
+		Lists:    append([]List(nil), snap.Lists...),
+		Webhooks: append([]Webhook(nil), snap.Webhooks...),
+		Users:    append([]User(nil), snap.Users...),
+	}
+}
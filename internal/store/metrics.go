@@ -0,0 +1,301 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Histogram buckets, in seconds: request latencies use the Prometheus
+// client defaults, the actor inbox much finer ones since a message usually
+// waits microseconds.
+var (
+	latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	inboxBuckets   = []float64{.00001, .0001, .001, .005, .01, .05, .1, .5, 1}
+)
+
+// Metrics collects what the server exposes on /metrics, in the Prometheus
+// text exposition format:
+//
+//	todoapp_http_requests_total{route,method,code}  counter
+//	todoapp_http_request_duration_seconds{route}    histogram
+//	todoapp_actor_inbox_wait_seconds                histogram
+//	todoapp_items{status}                           gauge, items outside the trash
+//	todoapp_store_save_duration_seconds             histogram
+//	todoapp_store_save_errors_total                 counter
+//
+// The zero value is not usable; call NewMetrics, and pass the actor the
+// metrics are about WithMetrics.
+type Metrics struct {
+	actor atomic.Pointer[ToDoActor]
+
+	mu         sync.Mutex
+	requests   map[requestKey]uint64
+	latencies  map[string]*histogram // by route
+	inboxWait  *histogram
+	saves      *histogram
+	saveErrors uint64
+}
+
+type requestKey struct {
+	route, method string
+	code          int
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:  map[requestKey]uint64{},
+		latencies: map[string]*histogram{},
+		inboxWait: newHistogram(inboxBuckets),
+		saves:     newHistogram(latencyBuckets),
+	}
+}
+
+// WithMetrics makes the actor record how long messages wait for it and how
+// its writes to storage go in “m”, and report its items there.
+func WithMetrics(m *Metrics) ActorOption {
+	return func(a *ToDoActor) {
+		a.metrics = m
+		m.actor.Store(a)
+	}
+}
+
+// statusCountsMsg asks the actor how many items outside the trash are in
+// each status.
+type statusCountsMsg struct {
+	reply chan map[string]int
+}
+
+// statusCounts returns how many items outside the trash are in each status.
+func (a *ToDoActor) statusCounts() map[string]int {
+	reply := make(chan map[string]int)
+	a.send(statusCountsMsg{reply})
+	return <-reply
+}
+
+// countStatuses counts the items outside the trash by status.
+func countStatuses(items []Item) map[string]int {
+	counts := map[string]int{}
+	for _, it := range items {
+		if it.DeletedAt.IsZero() {
+			counts[it.Status]++
+		}
+	}
+	return counts
+}
+
+// histogram counts observations into cumulative buckets, as Prometheus
+// histograms do.
+type histogram struct {
+	bounds []float64
+	counts []uint64 // counts[i] observations were ≤ bounds[i]
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (m *Metrics) observeRequest(route, method string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{route, method, code}]++
+	h := m.latencies[route]
+	if h == nil {
+		h = newHistogram(latencyBuckets)
+		m.latencies[route] = h
+	}
+	h.observe(d.Seconds())
+}
+
+func (m *Metrics) observeInboxWait(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inboxWait.observe(d.Seconds())
+}
+
+func (m *Metrics) observeSave(d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saves.observe(d.Seconds())
+	if err != nil {
+		m.saveErrors++
+	}
+}
+
+// statusRecorder remembers the status code a handler answered with.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the flusher of /events.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// standardMethods are the methods Instrument labels requests with as they are.
+var standardMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// Instrument counts and times the requests “next” serves. Each is labelled
+// with the route “route” returns for it, the pattern it matched such as
+// "GET /items/{id}"; requests that match none count as "other". Methods
+// outside the standard ones count as "OTHER", so that clients cannot add
+// label values at will.
+func (m *Metrics) Instrument(next http.Handler, route func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern := route(r)
+		// The method is a label of its own.
+		if _, path, ok := strings.Cut(pattern, " "); ok {
+			pattern = path
+		}
+		if pattern == "" {
+			pattern = "other"
+		}
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		method := r.Method
+		if !slices.Contains(standardMethods, method) {
+			method = "OTHER"
+		}
+		m.observeRequest(pattern, method, rec.code, time.Since(start))
+	})
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	b := bufio.NewWriter(w)
+	defer b.Flush()
+
+	// Counting the items goes through the actor, so do it before taking
+	// the lock its inbox wait is recorded under.
+	var items map[string]int
+	var statuses []string
+	if a := m.actor.Load(); a != nil {
+		items = a.statusCounts()
+		statuses = a.Workflow().Names()
+		for s := range items {
+			if !slices.Contains(statuses, s) {
+				statuses = append(statuses, s)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	header(b, "todoapp_http_requests_total", "counter", "HTTP requests served, by route, method and status code.")
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(x, y requestKey) int {
+		if c := strings.Compare(x.route, y.route); c != 0 {
+			return c
+		}
+		if c := strings.Compare(x.method, y.method); c != 0 {
+			return c
+		}
+		return x.code - y.code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(b, "todoapp_http_requests_total{route=%s,method=%s,code=\"%d\"} %d\n", quote(k.route), quote(k.method), k.code, m.requests[k])
+	}
+
+	header(b, "todoapp_http_request_duration_seconds", "histogram", "Time taken to serve HTTP requests, by route.")
+	routes := make([]string, 0, len(m.latencies))
+	for route := range m.latencies {
+		routes = append(routes, route)
+	}
+	slices.Sort(routes)
+	for _, route := range routes {
+		writeHistogram(b, "todoapp_http_request_duration_seconds", "route="+quote(route), m.latencies[route])
+	}
+
+	header(b, "todoapp_actor_inbox_wait_seconds", "histogram", "Time a request waited for the actor to take it.")
+	writeHistogram(b, "todoapp_actor_inbox_wait_seconds", "", m.inboxWait)
+
+	if items != nil {
+		header(b, "todoapp_items", "gauge", "Items outside the trash, by status.")
+		for _, s := range statuses {
+			fmt.Fprintf(b, "todoapp_items{status=%s} %d\n", quote(s), items[s])
+		}
+	}
+
+	header(b, "todoapp_store_save_duration_seconds", "histogram", "Time taken to write the store to storage, failed writes included.")
+	writeHistogram(b, "todoapp_store_save_duration_seconds", "", m.saves)
+	header(b, "todoapp_store_save_errors_total", "counter", "Writes to storage that failed.")
+	fmt.Fprintf(b, "todoapp_store_save_errors_total %d\n", m.saveErrors)
+}
+
+func header(b *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeHistogram writes the series of “h”, whose other labels are “labels”.
+func writeHistogram(b *bufio.Writer, name, labels string, h *histogram) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, bound := range h.bounds {
+		fmt.Fprintf(b, "%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(b, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(b, "%s_sum%s %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(b, "%s_count%s %d\n", name, labels, h.count)
+}
+
+// labelEscaper escapes a label value as the exposition format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quote(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+// statusCounts replies with the number of items outside the trash in each status.
+func (s *actorState) statusCounts(m statusCountsMsg) {
+	m.reply <- countStatuses(s.items)
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics_Requests(t *testing.T) {
+	m := NewMetrics()
+	actor := NewToDoActor(nil, WithMetrics(m))
+	mux := http.NewServeMux()
+	(&API{Actor: actor}).Routes(mux)
+	handler := m.Instrument(mux, func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	})
+	serve := func(method, path, body string) {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, strings.NewReader(body)).WithContext(testCtx()))
+	}
+	serve(http.MethodPost, "/items", `{"description":"Buy milk"}`)
+	serve(http.MethodPost, "/items", `{"description":"Walk dog"}`)
+	serve(http.MethodGet, "/items/1", "")
+	serve(http.MethodGet, "/items/9", "")
+	serve(http.MethodGet, "/nowhere", "")
+	serve("BREW", "/items", "")
+	serve(http.MethodPost, "/items", `{"description":"Trashed"}`)
+	serve(http.MethodDelete, "/items/3", "")
+	started := "started"
+	actor.PatchItem(testCtx(), 1, ItemPatch{Status: &started})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("expected the text exposition format, got %q", ct)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`todoapp_http_requests_total{route="/items",method="POST",code="201"} 3`,
+		`todoapp_http_requests_total{route="other",method="OTHER",code="405"} 1`,
+		`todoapp_http_requests_total{route="/items/{id}",method="GET",code="200"} 1`,
+		`todoapp_http_requests_total{route="/items/{id}",method="GET",code="404"} 1`,
+		`todoapp_http_requests_total{route="other",method="GET",code="404"} 1`,
+		`todoapp_http_request_duration_seconds_bucket{route="/items/{id}",le="+Inf"} 3`,
+		`todoapp_http_request_duration_seconds_count{route="/items"} 3`,
+		"# TYPE todoapp_actor_inbox_wait_seconds histogram",
+		`todoapp_items{status="not started"} 1`,
+		`todoapp_items{status="started"} 1`,
+		`todoapp_items{status="completed"} 0`,
+		"todoapp_store_save_errors_total 0",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("expected %q in\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "todoapp_actor_inbox_wait_seconds_count 0\n") {
+		t.Error("expected the inbox waits recorded")
+	}
+}
+
+func TestMetrics_Saves(t *testing.T) {
+	m := NewMetrics()
+	p := &recordingPersist{}
+	actor := NewToDoActor(nil, WithMetrics(m), WithWriteBehind(p.persist, WriteBehindConfig{Interval: time.Hour}))
+	defer actor.Close(context.Background())
+
+	actor.AddItem("one")
+	actor.Flush(testCtx())
+	p.setFail(errors.New("disk full"))
+	actor.AddItem("two")
+	actor.Flush(testCtx())
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{"todoapp_store_save_duration_seconds_count 2", "todoapp_store_save_errors_total 1"} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("expected %q in\n%s", want, body)
+		}
+	}
+	p.setFail(nil)
+}
+
+func TestMetrics_Instrument(t *testing.T) {
+	m := NewMetrics()
+	// Streams must still be flushable through the recorder.
+	handler := m.Instrument(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush failed: %v", err)
+		}
+		w.Write([]byte("data\n"))
+	}), func(*http.Request) string { return `GET /odd "route"` })
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `todoapp_http_requests_total{route="/odd \"route\"",method="GET",code="200"} 1`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected %q in\n%s", want, w.Body)
+	}
+	// Without an actor there are no item counts.
+	if strings.Contains(w.Body.String(), "todoapp_items") {
+		t.Errorf("expected no item counts, got\n%s", w.Body)
+	}
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"net"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -14,3 +15,27 @@ func TraceIDMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// IdentityHeader is the request header a client names itself in, e.g. the
+// proxying CLI sends "cli:alice".
+const IdentityHeader = "X-Actor"
+
+// IdentityMiddleware stores who is making the request under IdentityKey:
+// the IdentityHeader if given, otherwise "anonymous@" and the remote address.
+// The header is only a claim, so the request acts as no user (see UserKey)
+// unless AuthMiddleware, further in, verifies one.
+func IdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity := r.Header.Get(IdentityHeader)
+		if identity == "" {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			identity = "anonymous@" + host
+		}
+		ctx := context.WithValue(r.Context(), IdentityKey, identity)
+		ctx = context.WithValue(ctx, UserKey, "")
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
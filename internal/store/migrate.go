@@ -0,0 +1,198 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+// SchemaVersion is the on-disk schema version written by SaveItems.
+// Bump it together with a new entry in migrations.
+const SchemaVersion = 7
+
+// fileDocument is the versioned envelope SaveSnapshot writes.
+type fileDocument struct {
+	Version  int        `json:"version"`
+	Items    []Item     `json:"items"`
+	History  []Change   `json:"history,omitempty"`
+	Undo     []UndoStep `json:"undo,omitempty"`
+	Redo     []UndoStep `json:"redo,omitempty"`
+	LastID   int        `json:"last_id"`
+	Lists    []List     `json:"lists,omitempty"`
+	Webhooks []Webhook  `json:"webhooks,omitempty"`
+	Users    []User     `json:"users,omitempty"`
+}
+
+// Migration upgrades a raw document from schema version From to From+1. The
+// document is the top-level JSON object, keyed by field name, so a migration
+// can reshape anything in it without depending on today's Go types.
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(doc map[string]json.RawMessage) error
+}
+
+// migrations is the registry of schema upgrades; migrations[i] takes a
+// document from version i to version i+1.
+var migrations = []Migration{
+	{
+		From:        0,
+		Description: "wrap the bare item array in a versioned envelope and default empty statuses to not started",
+		Apply:       migrateV0ToV1,
+	},
+	{
+		// Nothing to convert, but older programs would drop the history
+		// when saving, so they must refuse to open the file.
+		From:        1,
+		Description: "add the change history",
+		Apply:       func(doc map[string]json.RawMessage) error { return nil },
+	},
+	{
+		From:        2,
+		Description: "add the undo log",
+		Apply:       func(doc map[string]json.RawMessage) error { return nil },
+	},
+	{
+		From:        3,
+		Description: "give every item a UUID and record the last assigned ID",
+		Apply:       migrateV3ToV4,
+	},
+	{
+		From:        4,
+		Description: "put every item in the default list",
+		Apply:       migrateV4ToV5,
+	},
+	{
+		From:        5,
+		Description: "add the webhook registrations",
+		Apply:       func(doc map[string]json.RawMessage) error { return nil },
+	},
+	{
+		From:        6,
+		Description: "add the user accounts and item owners",
+		Apply:       func(doc map[string]json.RawMessage) error { return nil },
+	},
+}
+
+// decodeDocument parses a stored file into its raw top-level fields and
+// schema version. A bare JSON array is the original, unversioned format and
+// is reported as version 0.
+func decodeDocument(data []byte) (map[string]json.RawMessage, int, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if !json.Valid(trimmed) {
+			return nil, 0, fmt.Errorf("invalid JSON array")
+		}
+		return map[string]json.RawMessage{"items": trimmed}, 0, nil
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &doc); err != nil {
+		return nil, 0, err
+	}
+	raw, ok := doc["version"]
+	if !ok {
+		return nil, 0, fmt.Errorf("document has no version field")
+	}
+	var version int
+	if err := json.Unmarshal(raw, &version); err != nil {
+		return nil, 0, fmt.Errorf("invalid version: %w", err)
+	}
+	return doc, version, nil
+}
+
+// migrateDocument brings “doc” from “version” up to SchemaVersion.
+func migrateDocument(ctx context.Context, doc map[string]json.RawMessage, version int) error {
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	if version > SchemaVersion {
+		return fmt.Errorf("schema version %d is newer than this program supports (%d)", version, SchemaVersion)
+	}
+	for v := version; v < SchemaVersion; v++ {
+		m := migrations[v]
+		if err := m.Apply(doc); err != nil {
+			return fmt.Errorf("migrating schema %d to %d: %w", v, v+1, err)
+		}
+		doc["version"] = json.RawMessage(fmt.Sprint(v + 1))
+		slog.Info("Migrated schema",
+			"from", v,
+			"to", v+1,
+			"description", m.Description,
+			"traceID", traceID,
+		)
+	}
+	return nil
+}
+
+// backupPath returns where the pre-migration copy of “filename” at “version” is kept.
+func backupPath(filename string, version int) string {
+	return fmt.Sprintf("%s.v%d.bak", filename, version)
+}
+
+// writeBackup stores the original bytes of “filename” before it is migrated.
+func writeBackup(filename string, version int, data []byte) error {
+	return os.WriteFile(backupPath(filename, version), data, 0644)
+}
+
+func migrateV0ToV1(doc map[string]json.RawMessage) error {
+	var items []map[string]any
+	if err := json.Unmarshal(doc["items"], &items); err != nil {
+		return err
+	}
+	for _, it := range items {
+		if s, _ := it["status"].(string); s == "" {
+			it["status"] = StatusNotStarted
+		}
+	}
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	doc["items"] = raw
+	return nil
+}
+
+func migrateV3ToV4(doc map[string]json.RawMessage) error {
+	var items []map[string]any
+	if err := json.Unmarshal(doc["items"], &items); err != nil {
+		return err
+	}
+	lastID := 0.0
+	for _, it := range items {
+		if u, _ := it["uuid"].(string); u == "" {
+			it["uuid"] = uuid.NewString()
+		}
+		if id, _ := it["id"].(float64); id > lastID {
+			lastID = id
+		}
+	}
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	doc["items"] = raw
+	doc["last_id"] = json.RawMessage(fmt.Sprint(int(lastID)))
+	return nil
+}
+
+func migrateV4ToV5(doc map[string]json.RawMessage) error {
+	var items []map[string]any
+	if err := json.Unmarshal(doc["items"], &items); err != nil {
+		return err
+	}
+	for _, it := range items {
+		if l, _ := it["list"].(string); l == "" {
+			it["list"] = DefaultList
+		}
+	}
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	doc["items"] = raw
+	doc["lists"] = json.RawMessage(fmt.Sprintf(`[{"name": %q}]`, DefaultList))
+	return nil
+}
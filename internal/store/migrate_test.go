@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrations_RegistryIsContiguous(t *testing.T) {
+	if len(migrations) != SchemaVersion {
+		t.Fatalf("expected %d migrations for schema version %d, got %d", SchemaVersion, SchemaVersion, len(migrations))
+	}
+	for i, m := range migrations {
+		if m.From != i || m.Apply == nil {
+			t.Errorf("migration %d is misregistered: %+v", i, m)
+		}
+	}
+}
+
+func TestLoadItems_MigratesBareArray(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "test-trace-id")
+	path := filepath.Join(t.TempDir(), "todos.json")
+	original := []byte(`[{"id":1,"description":"legacy","status":""},{"id":2,"description":"done","status":"completed"}]`)
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	items, err := LoadItems(ctx, path)
+	if err != nil {
+		t.Fatalf("LoadItems failed: %v", err)
+	}
+	if len(items) != 2 || items[0].Status != StatusNotStarted || items[1].Status != StatusCompleted {
+		t.Errorf("unexpected migrated items: %+v", items)
+	}
+
+	backup, err := os.ReadFile(backupPath(path, 0))
+	if err != nil {
+		t.Fatalf("expected backup: %v", err)
+	}
+	if string(backup) != string(original) {
+		t.Errorf("backup does not match original: %s", backup)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	var doc fileDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("migrated file is not an envelope: %v", err)
+	}
+	if doc.Version != SchemaVersion || len(doc.Items) != 2 {
+		t.Errorf("unexpected migrated document: %+v", doc)
+	}
+}
+
+func TestLoadItems_RejectsNewerSchema(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "test-trace-id")
+	path := filepath.Join(t.TempDir(), "todos.json")
+	if err := os.WriteFile(path, []byte(`{"version": 999, "items": []}`), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := LoadItems(ctx, path); err == nil {
+		t.Fatal("expected error for newer schema, got nil")
+	}
+}
+
+func TestLoadItems_CurrentSchemaNeedsNoBackup(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "test-trace-id")
+	path := filepath.Join(t.TempDir(), "todos.json")
+	if err := SaveItems(ctx, path, []Item{{ID: 1, Status: StatusStarted}}); err != nil {
+		t.Fatalf("SaveItems failed: %v", err)
+	}
+	if _, err := LoadItems(ctx, path); err != nil {
+		t.Fatalf("LoadItems failed: %v", err)
+	}
+	if _, err := os.Stat(backupPath(path, 0)); !os.IsNotExist(err) {
+		t.Errorf("expected no backup for current schema, stat err = %v", err)
+	}
+}
+
+func TestLoadSnapshot_MigratesV3GivesUUIDs(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "test-trace-id")
+	path := filepath.Join(t.TempDir(), "todos.json")
+	original := []byte(`{"version":3,"items":[{"id":4,"description":"a","status":"not started"},{"id":7,"description":"b","status":"not started"}]}`)
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	snap, err := LoadSnapshot(ctx, path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if snap.LastID != 7 {
+		t.Errorf("expected the last ID to be 7, got %d", snap.LastID)
+	}
+	if len(snap.Items) != 2 || snap.Items[0].UUID == "" || snap.Items[0].UUID == snap.Items[1].UUID {
+		t.Errorf("expected distinct UUIDs on every item, got %+v", snap.Items)
+	}
+}
+
+func TestLoadSnapshot_MigratesV4IntoDefaultList(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "test-trace-id")
+	path := filepath.Join(t.TempDir(), "todos.json")
+	original := []byte(`{"version":4,"items":[{"id":1,"uuid":"0b5e7b4c-5f0e-4d7c-9d0a-6a3b1f2e4c5d","description":"a","status":"not started"}],"last_id":1}`)
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	snap, err := LoadSnapshot(ctx, path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if len(snap.Items) != 1 || snap.Items[0].List != DefaultList {
+		t.Errorf("expected the item in the default list, got %+v", snap.Items)
+	}
+	if len(snap.Lists) != 1 || snap.Lists[0].Name != DefaultList {
+		t.Errorf("expected the default list to be recorded, got %+v", snap.Lists)
+	}
+}
@@ -4,18 +4,17 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // nextID returns one larger than the maximum ID in “items”. If the slice is empty, returns 1.
+// Unlike the actor, which keeps a counter, it reuses the IDs of removed items at the end of the slice.
 func nextID(items []Item) int {
-	max := 0
-	for _, item := range items {
-		if item.ID > max {
-			max = item.ID
-		}
-	}
-	return max + 1
+	return maxID(items) + 1
 }
 
 // AddItem appends a new Item to the slice and returns the new slice.
@@ -25,6 +24,7 @@ func AddItem(ctx context.Context, items []Item, description string) []Item {
 	id := nextID(items)
 	newItem := Item{
 		ID:          id,
+		UUID:        uuid.NewString(),
 		Description: description,
 		CreatedAt:   time.Now(),
 		Status:      StatusNotStarted,
@@ -118,8 +118,15 @@ func ListItems(items []Item) []Item {
 	return items
 }
 
-// PrintItems writes the current list of items to stdout in a human-readable format.
+// PrintItems writes the current list of items to stdout in a human-readable format,
+// with subtasks indented under their parent and a rollup of their progress
+// under the default workflow.
 func PrintItems(ctx context.Context, items []Item) {
+	PrintTree(ctx, buildTree(items, DefaultWorkflow()))
+}
+
+// PrintTree is PrintItems for a list already ordered by ToDoActor.Tree.
+func PrintTree(ctx context.Context, items []ItemView) {
 	traceID, _ := ctx.Value(TraceIDKey).(string)
 	if len(items) == 0 {
 		slog.InfoContext(ctx, "No to-do items", "traceID", traceID)
@@ -127,18 +134,70 @@ func PrintItems(ctx context.Context, items []Item) {
 		return
 	}
 	fmt.Println("Current to-do list:")
-	for _, it := range items {
+	for _, v := range items {
+		it := v.Item
 		slog.InfoContext(ctx, "To-do item",
 			"id", it.ID,
 			"description", it.Description,
 			"status", it.Status,
 			"created_at", it.CreatedAt.Format(time.RFC3339),
+			"priority", it.Priority,
+			"tags", it.Tags,
 			"traceID", traceID,
 		)
-		fmt.Printf("  [%d] %s (status: %s, created: %s)\n",
+		fmt.Printf("  %s[%d] %s (status: %s, created: %s%s)%s\n",
+			strings.Repeat("  ", v.Depth),
 			it.ID,
 			it.Description,
 			it.Status,
-			it.CreatedAt.Format(time.RFC3339))
+			it.CreatedAt.Format(time.RFC3339),
+			itemDetails(it),
+			progressDetails(v.Progress))
+	}
+}
+
+// progressDetails formats a subtask rollup for PrintItems, e.g. " — 3/5 subtasks completed".
+func progressDetails(p *Progress) string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf(" — %d/%d subtasks completed", p.Done, p.Total)
+}
+
+// itemDetails formats the optional fields of an item for PrintItems,
+// e.g. ", priority: high, due: 2025-07-01, tags: home,errands".
+func itemDetails(it Item) string {
+	var b strings.Builder
+	if it.List != "" && it.List != DefaultList {
+		fmt.Fprintf(&b, ", list: %s", it.List)
+	}
+	if it.Priority != "" {
+		fmt.Fprintf(&b, ", priority: %s", it.Priority)
+	}
+	if !it.DueAt.IsZero() {
+		fmt.Fprintf(&b, ", due: %s", it.DueAt.Format(time.DateOnly))
+	}
+	if len(it.Tags) > 0 {
+		fmt.Fprintf(&b, ", tags: %s", strings.Join(it.Tags, ","))
+	}
+	if it.AssigneeID != "" {
+		fmt.Fprintf(&b, ", assignee: %s", it.AssigneeID)
+	}
+	if !it.DeletedAt.IsZero() {
+		fmt.Fprintf(&b, ", in trash since: %s", it.DeletedAt.Format(time.RFC3339))
+	}
+	if !it.CompletedAt.IsZero() {
+		fmt.Fprintf(&b, ", completed: %s", it.CompletedAt.Format(time.RFC3339))
+	}
+	if it.Recurrence != nil {
+		fmt.Fprintf(&b, ", repeats: %s", it.Recurrence)
+	}
+	if len(it.BlockedBy) > 0 {
+		ids := make([]string, len(it.BlockedBy))
+		for i, id := range it.BlockedBy {
+			ids[i] = strconv.Itoa(id)
+		}
+		fmt.Fprintf(&b, ", blocked by: %s", strings.Join(ids, ","))
 	}
+	return b.String()
 }
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PersistFunc writes a snapshot of the actor's state to storage.
+// Storage.Save has this shape.
+type PersistFunc func(ctx context.Context, snap Snapshot) error
+
+// Default write-behind timings.
+const (
+	DefaultFlushInterval = 500 * time.Millisecond
+	DefaultFlushMaxDelay = 5 * time.Second
+)
+
+// WriteBehindConfig controls how ToDoActor batches writes to storage.
+type WriteBehindConfig struct {
+	// Interval is the quiet period after the last mutation before a flush.
+	// It is also the retry delay after a failed flush.
+	Interval time.Duration
+	// MaxDelay caps how long a change may stay unflushed while mutations
+	// keep arriving and pushing the quiet period back.
+	MaxDelay time.Duration
+}
+
+// FlushStatus reports the state of an actor's write-behind persistence.
+type FlushStatus struct {
+	Enabled     bool      `json:"enabled"`
+	Dirty       bool      `json:"dirty"`                  // changes not yet written
+	Flushes     int       `json:"flushes"`                // successful flushes
+	Failures    int       `json:"failures"`               // failed flushes
+	LastFlushAt time.Time `json:"last_flush_at,omitzero"` // time of the last successful flush
+	LastError   string    `json:"last_error,omitempty"`   // error of the last flush, cleared on success
+	LastErrorAt time.Time `json:"last_error_at,omitzero"` // time of the last failed flush
+}
+
+// WithWriteBehind makes the actor persist its items through “persist” after
+// each mutation, debounced according to “cfg”. Zero durations fall back to
+// DefaultFlushInterval and DefaultFlushMaxDelay.
+func WithWriteBehind(persist PersistFunc, cfg WriteBehindConfig) ActorOption {
+	return func(a *ToDoActor) {
+		if cfg.Interval <= 0 {
+			cfg.Interval = DefaultFlushInterval
+		}
+		if cfg.MaxDelay <= 0 {
+			cfg.MaxDelay = DefaultFlushMaxDelay
+		}
+		if cfg.MaxDelay < cfg.Interval {
+			cfg.MaxDelay = cfg.Interval
+		}
+		a.persist = &writeBehind{
+			persist: persist,
+			cfg:     cfg,
+			notify:  make(chan struct{}, 1),
+			done:    make(chan struct{}),
+			stopped: make(chan struct{}),
+		}
+	}
+}
+
+// writeBehind flushes an actor's items in the background. The actor loop only
+// ever calls markDirty, which never blocks, so a slow disk cannot stall it.
+type writeBehind struct {
+	persist PersistFunc
+	cfg     WriteBehindConfig
+	notify  chan struct{} // capacity 1: "something changed since you last looked"
+	done    chan struct{} // closed to stop run
+	stopped chan struct{} // closed when run has returned
+
+	changes atomic.Uint64 // mutations seen
+	flushMu sync.Mutex    // serialises flushes
+	mu      sync.Mutex    // guards the fields below
+	flushed uint64        // value of changes covered by the last successful flush
+	status  FlushStatus
+}
+
+func (wb *writeBehind) markDirty() {
+	wb.changes.Add(1)
+	select {
+	case wb.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (wb *writeBehind) run(a *ToDoActor) {
+	defer close(wb.stopped)
+	var (
+		timer      *time.Timer
+		timerC     <-chan time.Time
+		firstDirty time.Time
+	)
+	schedule := func(d time.Duration) {
+		if timer == nil {
+			timer = time.NewTimer(d)
+		} else {
+			timer.Reset(d)
+		}
+		timerC = timer.C
+	}
+	for {
+		select {
+		case <-wb.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-wb.notify:
+			now := time.Now()
+			if firstDirty.IsZero() {
+				firstDirty = now
+			}
+			// Debounce, but never past firstDirty+MaxDelay.
+			wait := wb.cfg.Interval
+			if deadline := firstDirty.Add(wb.cfg.MaxDelay); now.Add(wait).After(deadline) {
+				wait = max(deadline.Sub(now), 0)
+			}
+			schedule(wait)
+		case <-timerC:
+			timerC = nil
+			if err := wb.flush(context.Background(), a); err != nil {
+				schedule(wb.cfg.Interval)
+				continue
+			}
+			firstDirty = time.Time{}
+		}
+	}
+}
+
+// flush writes the actor's current items if anything changed since the last
+// successful flush.
+func (wb *writeBehind) flush(ctx context.Context, a *ToDoActor) error {
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	wb.flushMu.Lock()
+	defer wb.flushMu.Unlock()
+
+	seq := wb.changes.Load()
+	wb.mu.Lock()
+	clean := seq == wb.flushed
+	wb.mu.Unlock()
+	if clean {
+		return nil
+	}
+
+	// Every mutation counted in seq happened before this request reaches the actor.
+	snap := a.Snapshot()
+	start := time.Now()
+	err := wb.persist(ctx, snap)
+	if a.metrics != nil {
+		a.metrics.observeSave(time.Since(start), err)
+	}
+
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if err != nil {
+		wb.status.Failures++
+		wb.status.LastError = err.Error()
+		wb.status.LastErrorAt = time.Now()
+		slog.Error("Write-behind flush failed", "error", err, "traceID", traceID)
+		return err
+	}
+	wb.flushed = seq
+	wb.status.Flushes++
+	wb.status.LastFlushAt = time.Now()
+	wb.status.LastError = ""
+	slog.Debug("Write-behind flush", "count", len(snap.Items), "traceID", traceID)
+	return nil
+}
+
+func (wb *writeBehind) snapshot() FlushStatus {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	st := wb.status
+	st.Enabled = true
+	st.Dirty = wb.changes.Load() != wb.flushed
+	return st
+}
+
+// Flush synchronously writes any unflushed changes. It is a no-op when the
+// actor has no write-behind persistence.
+func (a *ToDoActor) Flush(ctx context.Context) error {
+	if a.persist == nil {
+		return nil
+	}
+	return a.persist.flush(ctx, a)
+}
+
+// FlushStatus reports the state of write-behind persistence.
+func (a *ToDoActor) FlushStatus() FlushStatus {
+	if a.persist == nil {
+		return FlushStatus{}
+	}
+	return a.persist.snapshot()
+}
+
+// Close stops background persistence after a final flush, and the periodic
+// trash purge, and ends every event subscription. The actor keeps serving
+// requests, but further changes are no longer written.
+func (a *ToDoActor) Close(ctx context.Context) error {
+	a.closeOnce.Do(func() { close(a.closed) })
+	a.events.closeAll()
+	if a.persist == nil {
+		return nil
+	}
+	select {
+	case <-a.persist.done:
+	default:
+		close(a.persist.done)
+	}
+	<-a.persist.stopped
+	return a.Flush(ctx)
+}
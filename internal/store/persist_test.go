@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingPersist is a PersistFunc that remembers every snapshot and can be
+// told to fail.
+type recordingPersist struct {
+	mu        sync.Mutex
+	snapshots []Snapshot
+	fail      error
+}
+
+func (p *recordingPersist) persist(ctx context.Context, snap Snapshot) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail != nil {
+		return p.fail
+	}
+	p.snapshots = append(p.snapshots, snap)
+	return nil
+}
+
+func (p *recordingPersist) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.snapshots)
+}
+
+func (p *recordingPersist) setFail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fail = err
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWriteBehind_FlushesAfterMutation(t *testing.T) {
+	p := &recordingPersist{}
+	actor := NewToDoActor([]Item{}, WithWriteBehind(p.persist, WriteBehindConfig{
+		Interval: 10 * time.Millisecond,
+		MaxDelay: 50 * time.Millisecond,
+	}))
+	defer actor.Close(context.Background())
+
+	actor.AddItem("one")
+	actor.AddItem("two")
+	waitFor(t, "flush", func() bool { return p.count() > 0 })
+
+	st := actor.FlushStatus()
+	if !st.Enabled || st.Dirty || st.Flushes == 0 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+	p.mu.Lock()
+	last := p.snapshots[len(p.snapshots)-1]
+	p.mu.Unlock()
+	if len(last.Items) != 2 || len(last.History) != 2 {
+		t.Errorf("expected last snapshot to hold 2 items and their history, got %+v", last)
+	}
+}
+
+func TestWriteBehind_MaxDelayBoundsDebounce(t *testing.T) {
+	p := &recordingPersist{}
+	actor := NewToDoActor([]Item{}, WithWriteBehind(p.persist, WriteBehindConfig{
+		Interval: 40 * time.Millisecond,
+		MaxDelay: 60 * time.Millisecond,
+	}))
+	defer actor.Close(context.Background())
+
+	// Keep mutating faster than Interval; MaxDelay must still force a flush.
+	stop := time.Now().Add(300 * time.Millisecond)
+	for time.Now().Before(stop) && p.count() == 0 {
+		actor.AddItem("busy")
+		time.Sleep(10 * time.Millisecond)
+	}
+	if p.count() == 0 {
+		t.Fatal("expected a flush while mutations kept arriving")
+	}
+}
+
+func TestWriteBehind_ReportsAndRecoversFromErrors(t *testing.T) {
+	p := &recordingPersist{}
+	p.setFail(errors.New("disk full"))
+	actor := NewToDoActor([]Item{}, WithWriteBehind(p.persist, WriteBehindConfig{
+		Interval: 10 * time.Millisecond,
+		MaxDelay: 10 * time.Millisecond,
+	}))
+	defer actor.Close(context.Background())
+
+	actor.AddItem("doomed")
+	waitFor(t, "failed flush", func() bool { return actor.FlushStatus().Failures > 0 })
+	st := actor.FlushStatus()
+	if st.LastError != "disk full" || !st.Dirty {
+		t.Errorf("unexpected status after failure: %+v", st)
+	}
+
+	p.setFail(nil)
+	waitFor(t, "retry", func() bool { return p.count() > 0 })
+	st = actor.FlushStatus()
+	if st.LastError != "" || st.Dirty {
+		t.Errorf("unexpected status after recovery: %+v", st)
+	}
+}
+
+func TestWriteBehind_CloseFlushesPendingChanges(t *testing.T) {
+	p := &recordingPersist{}
+	actor := NewToDoActor([]Item{}, WithWriteBehind(p.persist, WriteBehindConfig{
+		Interval: time.Hour,
+		MaxDelay: time.Hour,
+	}))
+	actor.AddItem("pending")
+	if err := actor.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if p.count() != 1 {
+		t.Errorf("expected exactly one flush on close, got %d", p.count())
+	}
+}
@@ -0,0 +1,402 @@
+package store
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields a Query can sort by.
+const (
+	SortID          = "id"
+	SortCreated     = "created"
+	SortUpdated     = "updated"
+	SortDue         = "due"
+	SortPriority    = "priority"
+	SortDescription = "description"
+	SortStatus      = "status"
+)
+
+var sortFields = []string{SortID, SortCreated, SortUpdated, SortDue, SortPriority, SortDescription, SortStatus}
+
+// Query selects a page of items. The zero Query is every live item of every
+// list in tree order, like ToDoActor.Tree.
+type Query struct {
+	List          string    // "" for every list
+	Statuses      []string  // any of these; none means every status
+	Text          string    // case-insensitive, in the description or a tag
+	CreatedAfter  time.Time // at or after, if set
+	CreatedBefore time.Time // before, if set
+	WithTrash     bool
+	CreatedBy     string // ID of the owner, if set
+	Assignee      string // ID of the assignee, or Unassigned; "" for anyone
+	// Mine keeps the items User created or is assigned. User is who is
+	// asking, taken from the request rather than its parameters.
+	Mine bool
+	User string
+
+	// Sort is one of the Sort constants; "" keeps tree order. Sorted pages
+	// are flat: every item has depth 0. Ties are broken by ID, and items
+	// without a due date come last either way.
+	Sort string
+	Desc bool
+
+	Limit  int    // items per page; 0 for all of them
+	Offset int    // items to skip; not with Cursor
+	Cursor string // Page.Next of the previous page
+}
+
+// Page is one page of the items a Query selects.
+type Page struct {
+	Items []ItemView `json:"items"`
+	Total int        `json:"total"`          // items matching the query, across pages
+	Next  string     `json:"next,omitempty"` // cursor for the next page; "" on the last one
+}
+
+// ParseQuery reads a Query from URL query parameters: list, status (comma
+// separated or repeated), text, created_after, created_before (RFC 3339 or
+// YYYY-MM-DD), include_trashed, created_by, assignee (a user ID or "none"),
+// mine, sort, order (asc or desc), limit, offset and cursor. The caller
+// sets User for mine.
+func ParseQuery(v url.Values) (Query, error) {
+	q := Query{
+		List:      strings.TrimSpace(v.Get("list")),
+		Text:      strings.TrimSpace(v.Get("text")),
+		CreatedBy: strings.ToLower(strings.TrimSpace(v.Get("created_by"))),
+		Assignee:  strings.ToLower(strings.TrimSpace(v.Get("assignee"))),
+		Sort:      strings.TrimSpace(v.Get("sort")),
+		Cursor:    v.Get("cursor"),
+	}
+	for _, s := range v["status"] {
+		for _, st := range strings.Split(s, ",") {
+			if st = strings.TrimSpace(st); st != "" {
+				q.Statuses = append(q.Statuses, st)
+			}
+		}
+	}
+	var err error
+	if q.CreatedAfter, err = parseQueryTime("created_after", v.Get("created_after")); err != nil {
+		return q, err
+	}
+	if q.CreatedBefore, err = parseQueryTime("created_before", v.Get("created_before")); err != nil {
+		return q, err
+	}
+	if s := v.Get("include_trashed"); s != "" {
+		if q.WithTrash, err = strconv.ParseBool(s); err != nil {
+			return q, &ValidationError{Field: "include_trashed", Message: fmt.Sprintf("%q is not true or false", s)}
+		}
+	}
+	if s := v.Get("mine"); s != "" {
+		if q.Mine, err = strconv.ParseBool(s); err != nil {
+			return q, &ValidationError{Field: "mine", Message: fmt.Sprintf("%q is not true or false", s)}
+		}
+	}
+	switch order := strings.ToLower(v.Get("order")); order {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, &ValidationError{Field: "order", Message: fmt.Sprintf("%q is not asc or desc", order)}
+	}
+	if q.Limit, err = parseQueryInt("limit", v.Get("limit")); err != nil {
+		return q, err
+	}
+	if q.Offset, err = parseQueryInt("offset", v.Get("offset")); err != nil {
+		return q, err
+	}
+	return q, q.validate()
+}
+
+func parseQueryTime(field, s string) (time.Time, error) {
+	t, err := ParseDue(s)
+	if err != nil {
+		return t, &ValidationError{Field: field, Message: fmt.Sprintf("%q is neither RFC 3339 nor YYYY-MM-DD", s)}
+	}
+	return t, nil
+}
+
+func parseQueryInt(field, s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, &ValidationError{Field: field, Message: fmt.Sprintf("%q is not a non-negative number", s)}
+	}
+	return n, nil
+}
+
+// Values is the inverse of ParseQuery.
+func (q Query) Values() url.Values {
+	v := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	set("list", q.List)
+	set("status", strings.Join(q.Statuses, ","))
+	set("text", q.Text)
+	if !q.CreatedAfter.IsZero() {
+		v.Set("created_after", q.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	if !q.CreatedBefore.IsZero() {
+		v.Set("created_before", q.CreatedBefore.Format(time.RFC3339Nano))
+	}
+	if q.WithTrash {
+		v.Set("include_trashed", "true")
+	}
+	set("created_by", q.CreatedBy)
+	set("assignee", q.Assignee)
+	if q.Mine {
+		v.Set("mine", "true")
+	}
+	set("sort", q.Sort)
+	if q.Desc {
+		v.Set("order", "desc")
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		v.Set("offset", strconv.Itoa(q.Offset))
+	}
+	set("cursor", q.Cursor)
+	return v
+}
+
+func (q Query) validate() error {
+	if q.Sort != "" && !slices.Contains(sortFields, q.Sort) {
+		return &ValidationError{Field: "sort", Message: fmt.Sprintf("%q is not one of %s", q.Sort, strings.Join(sortFields, ", "))}
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return &ValidationError{Field: "limit", Message: "limit and offset cannot be negative"}
+	}
+	if q.Offset > 0 && q.Cursor != "" {
+		return &ValidationError{Field: "cursor", Message: "use either offset or cursor, not both"}
+	}
+	return nil
+}
+
+// cursor is what Page.Next encodes: the sort the page was taken with and
+// the last item on it, so that the next page starts after that item even if
+// items were added or removed in between.
+type cursor struct {
+	Sort string `json:"s,omitempty"`
+	Desc bool   `json:"d,omitempty"`
+	ID   int    `json:"id"`
+	Key  string `json:"k,omitempty"` // the item's value of the sort field
+}
+
+func encodeCursor(q Query, last Item) string {
+	c := cursor{Sort: q.Sort, Desc: q.Desc, ID: last.ID, Key: sortKey(last, q.Sort)}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(q Query) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return c, &ValidationError{Field: "cursor", Message: "not a cursor returned by this server"}
+	}
+	if c.Sort != q.Sort || c.Desc != q.Desc {
+		return c, &ValidationError{Field: "cursor", Message: "the cursor was made for another sort order"}
+	}
+	return c, nil
+}
+
+// sortKey returns the value of “field” for “it” as a string, and keyItem
+// turns it back into an item that sorts at the same place.
+func sortKey(it Item, field string) string {
+	switch field {
+	case SortCreated:
+		return it.CreatedAt.Format(time.RFC3339Nano)
+	case SortUpdated:
+		return it.UpdatedAt.Format(time.RFC3339Nano)
+	case SortDue:
+		if it.DueAt.IsZero() {
+			return ""
+		}
+		return it.DueAt.Format(time.RFC3339Nano)
+	case SortPriority:
+		return it.Priority
+	case SortDescription:
+		return it.Description
+	case SortStatus:
+		return it.Status
+	}
+	return ""
+}
+
+func keyItem(field, key string, id int) Item {
+	it := Item{ID: id}
+	t, _ := time.Parse(time.RFC3339Nano, key)
+	switch field {
+	case SortCreated:
+		it.CreatedAt = t
+	case SortUpdated:
+		it.UpdatedAt = t
+	case SortDue:
+		it.DueAt = t
+	case SortPriority:
+		it.Priority = key
+	case SortDescription:
+		it.Description = key
+	case SortStatus:
+		it.Status = key
+	}
+	return it
+}
+
+var priorityRank = map[string]int{PriorityLow: 1, PriorityMedium: 2, PriorityHigh: 3, PriorityUrgent: 4}
+
+// compareItems orders “a” and “b” by “field”, then by ID.
+func compareItems(a, b Item, field string, desc bool, w *Workflow) int {
+	if field == SortDue && a.DueAt.IsZero() != b.DueAt.IsZero() {
+		if a.DueAt.IsZero() {
+			return 1
+		}
+		return -1
+	}
+	var c int
+	switch field {
+	case SortCreated:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	case SortUpdated:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	case SortDue:
+		c = a.DueAt.Compare(b.DueAt)
+	case SortPriority:
+		c = cmp.Compare(priorityRank[a.Priority], priorityRank[b.Priority])
+	case SortDescription:
+		c = cmp.Compare(strings.ToLower(a.Description), strings.ToLower(b.Description))
+	case SortStatus:
+		c = cmp.Compare(statusRank(w, a.Status), statusRank(w, b.Status))
+	}
+	if desc {
+		c = -c
+	}
+	if c == 0 {
+		c = cmp.Compare(a.ID, b.ID)
+	}
+	return c
+}
+
+// statusRank orders statuses as the workflow declares them, unknown ones last.
+func statusRank(w *Workflow, status string) int {
+	i := slices.IndexFunc(w.Statuses, func(s StatusDef) bool { return s.Name == status })
+	if i < 0 {
+		return len(w.Statuses)
+	}
+	return i
+}
+
+// matches reports whether “it” passes the filters of “q”.
+func (q Query) matches(it Item) bool {
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, it.Status) {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() && it.CreatedAt.Before(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !it.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	if q.CreatedBy != "" && it.CreatedBy != q.CreatedBy {
+		return false
+	}
+	switch q.Assignee {
+	case "":
+	case Unassigned:
+		if it.AssigneeID != "" {
+			return false
+		}
+	default:
+		if it.AssigneeID != q.Assignee {
+			return false
+		}
+	}
+	if q.Mine && it.CreatedBy != q.User && it.AssigneeID != q.User {
+		return false
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		if !strings.Contains(strings.ToLower(it.Description), text) &&
+			!slices.ContainsFunc(it.Tags, func(t string) bool { return strings.Contains(t, text) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// runQuery filters, sorts and pages “views”, which are in tree order.
+func runQuery(views []ItemView, q Query, w *Workflow) (Page, error) {
+	matched := views[:0:0]
+	for _, v := range views {
+		if q.matches(v.Item) {
+			matched = append(matched, v)
+		}
+	}
+	if q.Sort != "" {
+		for i := range matched {
+			matched[i].Depth = 0
+		}
+		slices.SortFunc(matched, func(a, b ItemView) int { return compareItems(a.Item, b.Item, q.Sort, q.Desc, w) })
+	}
+
+	start := min(q.Offset, len(matched))
+	if q.Cursor != "" {
+		c, err := decodeCursor(q)
+		if err != nil {
+			return Page{}, err
+		}
+		if q.Sort == "" {
+			i := slices.IndexFunc(matched, func(v ItemView) bool { return v.ID == c.ID })
+			if i < 0 {
+				return Page{}, &ValidationError{Field: "cursor", Message: fmt.Sprintf("item %d is no longer listed; start again from the first page", c.ID)}
+			}
+			start = i + 1
+		} else {
+			after := keyItem(q.Sort, c.Key, c.ID)
+			start, _ = slices.BinarySearchFunc(matched, after, func(v ItemView, t Item) int {
+				if compareItems(v.Item, t, q.Sort, q.Desc, w) <= 0 {
+					return -1
+				}
+				return 1
+			})
+		}
+	}
+	end := len(matched)
+	if q.Limit > 0 {
+		end = min(start+q.Limit, end)
+	}
+	page := Page{Items: slices.Clone(matched[start:end]), Total: len(matched)}
+	if end < len(matched) && end > start {
+		page.Next = encodeCursor(q, matched[end-1].Item)
+	}
+	return page, nil
+}
+
+// query replies with a page of the item tree.
+func (s *actorState) query(m queryMsg) {
+	if m.q.List != "" && !s.hasList(m.q.List) {
+		m.reply <- queryReply{err: ErrListNotFound}
+		return
+	}
+	shown := itemsInList(s.items, m.q.List)
+	if !m.q.WithTrash {
+		shown = liveItems(shown)
+	}
+	page, err := runQuery(buildTree(shown, s.a.workflow), m.q, s.a.workflow)
+	m.reply <- queryReply{page, err}
+}
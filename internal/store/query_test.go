@@ -0,0 +1,178 @@
+package store
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	v, _ := url.ParseQuery("status=started,completed&status=blocked&text=+milk+&created_after=2025-06-01&sort=due&order=desc&limit=10&offset=20&include_trashed=true")
+	q, err := ParseQuery(v)
+	if err != nil {
+		t.Fatalf("ParseQuery failed: %v", err)
+	}
+	if len(q.Statuses) != 3 || q.Text != "milk" || q.CreatedAfter.IsZero() || q.Sort != SortDue || !q.Desc || q.Limit != 10 || q.Offset != 20 || !q.WithTrash {
+		t.Errorf("unexpected query: %+v", q)
+	}
+	again, err := ParseQuery(q.Values())
+	if err != nil || again.Limit != q.Limit || !again.CreatedAfter.Equal(q.CreatedAfter) || len(again.Statuses) != 3 {
+		t.Errorf("expected Values to round-trip, got %+v: %v", again, err)
+	}
+
+	tests := []struct {
+		name, query string
+	}{
+		{"unknown sort", "sort=colour"},
+		{"bad order", "sort=id&order=sideways"},
+		{"negative limit", "limit=-1"},
+		{"bad time", "created_before=yesterday"},
+		{"offset and cursor", "offset=5&cursor=abc"},
+	}
+	for _, tt := range tests {
+		v, _ := url.ParseQuery(tt.query)
+		var ve *ValidationError
+		if _, err := ParseQuery(v); !errors.As(err, &ve) {
+			t.Errorf("%s: expected a ValidationError, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestToDoActor_QueryFilters(t *testing.T) {
+	ctx := testCtx()
+	old := time.Now().Add(-48 * time.Hour)
+	actor := NewToDoActor([]Item{
+		{ID: 1, Description: "Buy milk", Status: StatusNotStarted, CreatedAt: old},
+		{ID: 2, Description: "Call mum", Status: StatusStarted, Tags: []string{"family"}, CreatedAt: old},
+	})
+	actor.CreateItem(ctx, ItemInput{Description: "Milk the cow", Tags: []string{"farm"}})
+
+	tests := []struct {
+		name string
+		q    Query
+		want []int
+	}{
+		{"status", Query{Statuses: []string{StatusStarted}}, []int{2}},
+		{"text in description", Query{Text: "MILK"}, []int{1, 3}},
+		{"text in tag", Query{Text: "fam"}, []int{2}},
+		{"created after", Query{CreatedAfter: time.Now().Add(-time.Hour)}, []int{3}},
+		{"created before", Query{CreatedBefore: time.Now().Add(-time.Hour)}, []int{1, 2}},
+		{"combined", Query{Text: "milk", Statuses: []string{StatusNotStarted}, CreatedBefore: time.Now().Add(-time.Hour)}, []int{1}},
+	}
+	for _, tt := range tests {
+		page, err := actor.Query(tt.q)
+		if err != nil {
+			t.Errorf("%s: Query failed: %v", tt.name, err)
+			continue
+		}
+		if got := viewIDs(page.Items); !slices.Equal(got, tt.want) || page.Total != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v (total %d)", tt.name, tt.want, got, page.Total)
+		}
+	}
+}
+
+func TestToDoActor_QuerySort(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 0, 0, 0, 0, time.UTC) }
+	actor := NewToDoActor([]Item{
+		{ID: 1, Description: "b", Priority: PriorityLow, DueAt: day(3)},
+		{ID: 2, Description: "A", Priority: PriorityUrgent},
+		{ID: 3, Description: "c", Priority: PriorityHigh, DueAt: day(1), ParentID: 1},
+	})
+
+	tests := []struct {
+		q    Query
+		want []int
+	}{
+		{Query{}, []int{1, 3, 2}},
+		{Query{Sort: SortPriority, Desc: true}, []int{2, 3, 1}},
+		{Query{Sort: SortDue}, []int{3, 1, 2}},
+		{Query{Sort: SortDue, Desc: true}, []int{1, 3, 2}},
+		{Query{Sort: SortDescription}, []int{2, 1, 3}},
+	}
+	for _, tt := range tests {
+		page, _ := actor.Query(tt.q)
+		if got := viewIDs(page.Items); !slices.Equal(got, tt.want) {
+			t.Errorf("sort %q desc=%v: expected %v, got %v", tt.q.Sort, tt.q.Desc, tt.want, got)
+		}
+		if tt.q.Sort != "" && page.Items[1].Depth != 0 {
+			t.Errorf("sort %q: expected a flat page, got depth %d", tt.q.Sort, page.Items[1].Depth)
+		}
+	}
+}
+
+func TestToDoActor_QueryPages(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	for _, d := range []string{"e", "d", "c", "b", "a"} {
+		actor.CreateItem(ctx, ItemInput{Description: d})
+	}
+
+	q := Query{Sort: SortDescription, Limit: 2}
+	first, err := actor.Query(q)
+	if err != nil || !slices.Equal(viewIDs(first.Items), []int{5, 4}) || first.Total != 5 || first.Next == "" {
+		t.Fatalf("unexpected first page %+v: %v", first, err)
+	}
+	// Items added or removed before the cursor do not shift the next page.
+	actor.CreateItem(ctx, ItemInput{Description: "aa"})
+	actor.RemoveItem(ctx, 4)
+	q.Cursor = first.Next
+	second, _ := actor.Query(q)
+	if got := viewIDs(second.Items); !slices.Equal(got, []int{3, 2}) {
+		t.Errorf("expected the second page to continue after b, got %v", got)
+	}
+	q.Cursor = second.Next
+	if last, _ := actor.Query(q); !slices.Equal(viewIDs(last.Items), []int{1}) || last.Next != "" {
+		t.Errorf("expected a last page with item 1 and no cursor, got %+v", last)
+	}
+
+	if page, _ := actor.Query(Query{Limit: 2, Offset: 3}); !slices.Equal(viewIDs(page.Items), []int{5, 6}) {
+		t.Errorf("expected the offset page, got %v", viewIDs(page.Items))
+	}
+
+	tree, _ := actor.Query(Query{Limit: 3})
+	actor.RemoveItem(ctx, 3)
+	var ve *ValidationError
+	if _, err := actor.Query(Query{Limit: 3, Cursor: tree.Next}); !errors.As(err, &ve) {
+		t.Errorf("expected a tree cursor to fail once its item is gone, got %v", err)
+	}
+	if _, err := actor.Query(Query{Sort: SortID, Cursor: first.Next}); !errors.As(err, &ve) {
+		t.Errorf("expected a cursor for another sort to fail, got %v", err)
+	}
+}
+
+func TestAPI_GetPages(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	for range 3 {
+		actor.CreateItem(ctx, ItemInput{Description: "Task"})
+	}
+	serve := newTestMux(actor)
+
+	w := serve(http.MethodGet, "/items?sort=id&limit=2", "")
+	link := w.Header().Get("Link")
+	if w.Code != http.StatusOK || w.Header().Get("X-Total-Count") != "3" || !strings.Contains(link, "cursor=") {
+		t.Fatalf("expected a first page with a next link, got %d %v", w.Code, w.Header())
+	}
+	next := link[1:strings.Index(link, ">")]
+	if w := serve(http.MethodGet, next, ""); w.Header().Get("Link") != "" || !strings.Contains(w.Body.String(), `"id":3`) {
+		t.Errorf("expected the last page to hold item 3 and no link, got %v %s", w.Header(), w.Body)
+	}
+	if w := serve(http.MethodGet, "/items?limit=1&offset=1", ""); !strings.Contains(w.Header().Get("Link"), "offset=2") {
+		t.Errorf("expected an offset link, got %q", w.Header().Get("Link"))
+	}
+	if w := serve(http.MethodGet, "/items?sort=colour", ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an unknown sort field, got %d", w.Code)
+	}
+}
+
+func viewIDs(views []ItemView) []int {
+	ids := make([]int, len(views))
+	for i, v := range views {
+		ids[i] = v.ID
+	}
+	return ids
+}
@@ -0,0 +1,214 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Recurrence kinds.
+const (
+	RepeatDaily   = "daily"   // every Interval days
+	RepeatWeekly  = "weekly"  // on Weekdays, or every Interval weeks
+	RepeatMonthly = "monthly" // every Interval months on DayOfMonth
+	RepeatAfter   = "after"   // Interval days after the previous one was completed
+)
+
+// Recurrence says when the next occurrence of a recurring item is due. It is
+// written as a short rule such as "daily", "daily:2", "weekly:mon,thu",
+// "monthly:15" or "after:10", both on the command line and in JSON.
+type Recurrence struct {
+	Kind       string
+	Interval   int            // daily, weekly without weekdays, monthly, after; defaults to 1
+	Weekdays   []time.Weekday // weekly only; empty means the weekday of the due date
+	DayOfMonth int            // monthly only; 0 means the day of the due date
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseRecurrence parses a rule in the form written by Recurrence.String.
+func ParseRecurrence(s string) (*Recurrence, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return nil, nil
+	}
+	kind, arg, hasArg := strings.Cut(s, ":")
+	r := &Recurrence{Kind: kind, Interval: 1}
+	invalid := func(msg string) error {
+		return &ValidationError{Field: "recurrence", Message: fmt.Sprintf("%q: %s", s, msg)}
+	}
+	switch kind {
+	case RepeatDaily, RepeatAfter:
+		if hasArg {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return nil, invalid("expected a number of days")
+			}
+			r.Interval = n
+		}
+	case RepeatWeekly:
+		if hasArg {
+			for _, name := range strings.Split(arg, ",") {
+				d := indexOf(weekdayNames, strings.TrimSpace(name))
+				if d < 0 {
+					return nil, invalid("expected weekdays such as mon,thu")
+				}
+				r.Weekdays = append(r.Weekdays, time.Weekday(d))
+			}
+		}
+	case RepeatMonthly:
+		if hasArg {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 || n > 31 {
+				return nil, invalid("expected a day of the month between 1 and 31")
+			}
+			r.DayOfMonth = n
+		}
+	default:
+		return nil, invalid("expected daily, weekly, monthly or after")
+	}
+	return r, nil
+}
+
+// validate rejects rules ParseRecurrence cannot produce. An Interval only
+// counts weeks when no Weekdays are given, so the two cannot be combined.
+func (r Recurrence) validate() error {
+	if r.Kind == RepeatWeekly && len(r.Weekdays) > 0 && r.Interval > 1 {
+		return &ValidationError{Field: "recurrence", Message: fmt.Sprintf("%q: weekdays cannot be combined with an interval of %d weeks", r.String(), r.Interval)}
+	}
+	return nil
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r Recurrence) String() string {
+	switch r.Kind {
+	case RepeatDaily, RepeatAfter:
+		if r.Kind == RepeatDaily && r.Interval <= 1 {
+			return r.Kind
+		}
+		return fmt.Sprintf("%s:%d", r.Kind, max(r.Interval, 1))
+	case RepeatWeekly:
+		if len(r.Weekdays) == 0 {
+			return r.Kind
+		}
+		names := make([]string, len(r.Weekdays))
+		for i, d := range r.Weekdays {
+			names[i] = weekdayNames[d]
+		}
+		return r.Kind + ":" + strings.Join(names, ",")
+	case RepeatMonthly:
+		if r.DayOfMonth == 0 {
+			return r.Kind
+		}
+		return fmt.Sprintf("%s:%d", r.Kind, r.DayOfMonth)
+	}
+	return r.Kind
+}
+
+func (r Recurrence) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Recurrence) UnmarshalText(text []byte) error {
+	parsed, err := ParseRecurrence(string(text))
+	if err != nil {
+		return err
+	}
+	if parsed == nil {
+		*r = Recurrence{}
+		return nil
+	}
+	*r = *parsed
+	return nil
+}
+
+// Next returns when the occurrence after one due at “due” and completed at
+// “completed” is due. Calendar rules skip occurrences that already passed by
+// the time the item was completed, so a late chore is not immediately overdue.
+func (r Recurrence) Next(due, completed time.Time) time.Time {
+	interval := max(r.Interval, 1)
+	if r.Kind == RepeatAfter {
+		return completed.AddDate(0, 0, interval)
+	}
+	base := due
+	if base.IsZero() {
+		base = completed
+	}
+	next := r.step(base, base)
+	for !next.After(completed) {
+		next = r.step(next, base)
+	}
+	return next
+}
+
+// step returns the first occurrence after “t”. “anchor” supplies the
+// weekday or day of month when the rule leaves it out.
+func (r Recurrence) step(t, anchor time.Time) time.Time {
+	interval := max(r.Interval, 1)
+	switch r.Kind {
+	case RepeatWeekly:
+		if len(r.Weekdays) == 0 {
+			return t.AddDate(0, 0, 7*interval)
+		}
+		for i := 1; i <= 7; i++ {
+			d := t.AddDate(0, 0, i)
+			for _, wd := range r.Weekdays {
+				if d.Weekday() == wd {
+					return d
+				}
+			}
+		}
+	case RepeatMonthly:
+		day := r.DayOfMonth
+		if day == 0 {
+			day = anchor.Day()
+		}
+		if d := dayInMonth(t, 0, day); d.After(t) {
+			return d
+		}
+		return dayInMonth(t, interval, day)
+	}
+	return t.AddDate(0, 0, interval)
+}
+
+// dayInMonth returns “day” of the month “months” after t's, clamped to the
+// last day of that month, at t's time of day.
+func dayInMonth(t time.Time, months, day int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
+
+// nextOccurrence returns the item that replaces a completed recurring item;
+// it starts out in “status”.
+func nextOccurrence(done Item, id int, status string, now time.Time) Item {
+	return Item{
+		ID:          id,
+		UUID:        uuid.NewString(),
+		Description: done.Description,
+		List:        done.List,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Version:     1,
+		Status:      status,
+		Priority:    done.Priority,
+		DueAt:       done.Recurrence.Next(done.DueAt, now),
+		Tags:        done.Tags,
+		ParentID:    done.ParentID,
+		Recurrence:  done.Recurrence,
+		PreviousID:  done.ID,
+		CreatedBy:   done.CreatedBy,
+		AssigneeID:  done.AssigneeID,
+	}
+}
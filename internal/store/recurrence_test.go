@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence_RoundTrip(t *testing.T) {
+	for _, rule := range []string{"daily", "daily:3", "weekly", "weekly:mon,thu", "monthly", "monthly:31", "after:10"} {
+		r, err := ParseRecurrence(rule)
+		if err != nil {
+			t.Fatalf("ParseRecurrence(%q) failed: %v", rule, err)
+		}
+		if got := r.String(); got != rule {
+			t.Errorf("ParseRecurrence(%q).String() = %q", rule, got)
+		}
+	}
+	if r, err := ParseRecurrence(""); r != nil || err != nil {
+		t.Errorf("expected nil for an empty rule, got %v, %v", r, err)
+	}
+	for _, bad := range []string{"yearly", "daily:0", "weekly:funday", "monthly:32", "after:x"} {
+		var verr *ValidationError
+		if _, err := ParseRecurrence(bad); !errors.As(err, &verr) {
+			t.Errorf("ParseRecurrence(%q): expected ValidationError, got %v", bad, err)
+		}
+	}
+}
+
+func TestRecurrence_JSON(t *testing.T) {
+	in := Item{ID: 1, Recurrence: &Recurrence{Kind: RepeatWeekly, Interval: 1, Weekdays: []time.Weekday{time.Monday}}}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var out Item
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.Recurrence == nil || out.Recurrence.String() != "weekly:mon" {
+		t.Errorf("expected weekly:mon after round trip of %s, got %v", data, out.Recurrence)
+	}
+}
+
+func TestRecurrence_Next(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		rule      string
+		due, done time.Time
+		want      time.Time
+	}{
+		{"daily", day(2025, 6, 2), day(2025, 6, 1), day(2025, 6, 3)},
+		{"daily:2", day(2025, 6, 2), day(2025, 6, 9), day(2025, 6, 10)}, // skips missed occurrences
+		{"weekly", day(2025, 6, 2), day(2025, 6, 2), day(2025, 6, 9)},
+		{"weekly:mon,thu", day(2025, 6, 2), day(2025, 6, 2), day(2025, 6, 5)},
+		{"weekly:mon,thu", day(2025, 6, 5), day(2025, 6, 5), day(2025, 6, 9)},
+		{"monthly", day(2025, 1, 31), day(2025, 1, 31), day(2025, 2, 28)}, // clamped to month end
+		{"monthly:15", day(2025, 1, 15), day(2025, 1, 10), day(2025, 2, 15)},
+		{"monthly:15", day(2025, 1, 3), day(2025, 1, 3), day(2025, 1, 15)},   // day 15 still ahead this month
+		{"monthly:15", day(2025, 1, 20), day(2025, 1, 20), day(2025, 2, 15)}, // day 15 already passed
+		{"after:10", day(2025, 6, 2), day(2025, 6, 20), day(2025, 6, 30)},
+		{"daily", time.Time{}, day(2025, 6, 20), day(2025, 6, 21)}, // no due date
+	}
+	for _, tt := range tests {
+		r, err := ParseRecurrence(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseRecurrence(%q) failed: %v", tt.rule, err)
+		}
+		if got := r.Next(tt.due, tt.done); !got.Equal(tt.want) {
+			t.Errorf("%s: Next(%s, %s) = %s, want %s", tt.rule, tt.due.Format(time.DateOnly), tt.done.Format(time.DateOnly), got.Format(time.DateOnly), tt.want.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestToDoActor_RejectsWeekdaysWithInterval(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor(nil)
+	rec := &Recurrence{Kind: RepeatWeekly, Interval: 2, Weekdays: []time.Weekday{time.Monday}}
+
+	var verr *ValidationError
+	if _, err := actor.CreateItem(ctx, ItemInput{Description: "Standup", Recurrence: rec}); !errors.As(err, &verr) {
+		t.Fatalf("CreateItem: expected ValidationError, got %v", err)
+	}
+	item, err := actor.CreateItem(ctx, ItemInput{Description: "Standup"})
+	if err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	if _, err := actor.PatchItem(ctx, item.ID, ItemPatch{Recurrence: rec}); !errors.As(err, &verr) {
+		t.Errorf("PatchItem: expected ValidationError, got %v", err)
+	}
+}
+
+func TestToDoActor_CompletingRecurringItemSpawnsNext(t *testing.T) {
+	ctx := context.Background()
+	var changes []JournalEntry
+	actor := NewToDoActor(nil, WithChangeListener(func(e JournalEntry) { changes = append(changes, e) }))
+
+	rec, _ := ParseRecurrence("daily")
+	item, err := actor.CreateItem(ctx, ItemInput{Description: "Water plants", Tags: []string{"home"}, Recurrence: rec})
+	if err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	status := StatusCompleted
+	if _, err := actor.PatchItem(ctx, item.ID, ItemPatch{Status: &status}); err != nil {
+		t.Fatalf("PatchItem failed: %v", err)
+	}
+
+	items := actor.GetItems()
+	if len(items) != 2 {
+		t.Fatalf("expected the next occurrence to be added, got %+v", items)
+	}
+	next := items[1]
+	if next.PreviousID != item.ID || next.Status != StatusNotStarted || next.Description != "Water plants" {
+		t.Errorf("unexpected next occurrence %+v", next)
+	}
+	if next.Recurrence == nil || len(next.Tags) != 1 || next.DueAt.IsZero() {
+		t.Errorf("expected recurrence, tags and due date to carry over, got %+v", next)
+	}
+	if changes = itemWrites(changes); len(changes) != 3 || changes[2].Item == nil || changes[2].Item.ID != next.ID {
+		t.Errorf("expected create, complete and spawn to be reported, got %+v", changes)
+	}
+
+	// Completing the old occurrence again must not spawn a second copy.
+	if _, err := actor.PatchItem(ctx, item.ID, ItemPatch{Status: &status}); err != nil {
+		t.Fatalf("PatchItem failed: %v", err)
+	}
+	if n := len(actor.GetItems()); n != 2 {
+		t.Errorf("expected 2 items after re-completing, got %d", n)
+	}
+}
+
+func TestToDoActor_NextOccurrenceKeepsOwnerAndAssignee(t *testing.T) {
+	actor := NewToDoActor(nil)
+	actor.CreateUser(as("alice"), User{ID: "alice"})
+	actor.CreateUser(as("alice"), User{ID: "bob"})
+
+	rec, _ := ParseRecurrence("weekly")
+	item, err := actor.CreateItem(as("alice"), ItemInput{Description: "Take out bins", Recurrence: rec, AssigneeID: "bob"})
+	if err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	status := StatusCompleted
+	if _, err := actor.PatchItem(as("bob"), item.ID, ItemPatch{Status: &status}); err != nil {
+		t.Fatalf("PatchItem failed: %v", err)
+	}
+	items := actor.GetItems()
+	if len(items) != 2 {
+		t.Fatalf("expected the next occurrence to be added, got %+v", items)
+	}
+	if next := items[1]; next.CreatedBy != "alice" || next.AssigneeID != "bob" {
+		t.Errorf("expected the next occurrence to belong to alice and be assigned to bob, got %+v", next)
+	}
+}
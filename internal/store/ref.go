@@ -0,0 +1,94 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ItemRef names an item the way a user or client may give it: by its
+// number or by its UUID. In JSON it may be a number or a string holding either.
+type ItemRef string
+
+// RefID returns the ItemRef for item number “id”; 0 names no item.
+func RefID(id int) ItemRef {
+	if id == 0 {
+		return ""
+	}
+	return ItemRef(strconv.Itoa(id))
+}
+
+// IsZero reports whether the reference names no item.
+func (r ItemRef) IsZero() bool {
+	return r == "" || r == "0"
+}
+
+func (r ItemRef) MarshalJSON() ([]byte, error) {
+	if n, err := strconv.Atoi(string(r)); err == nil {
+		return []byte(strconv.Itoa(n)), nil
+	}
+	return json.Marshal(string(r))
+}
+
+func (r *ItemRef) UnmarshalJSON(data []byte) error {
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*r = ""
+		return nil
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		err := json.Unmarshal(data, &s)
+		*r = ItemRef(strings.TrimSpace(s))
+		return err
+	}
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("item reference must be a number or a UUID: %w", err)
+	}
+	*r = RefID(n)
+	return nil
+}
+
+// maxID returns the largest ID in “items”, or 0 if there are none.
+func maxID(items []Item) int {
+	n := 0
+	for _, it := range items {
+		n = max(n, it.ID)
+	}
+	return n
+}
+
+// WithLastID tells the actor the largest ID it has ever assigned, as kept
+// in Snapshot.LastID, so that IDs of items that were purged or whose
+// creation was undone are not given out again.
+func WithLastID(id int) ActorOption {
+	return func(a *ToDoActor) {
+		a.lastID = id
+	}
+}
+
+// Resolve returns the ID of the item “ref” names, which may be its number
+// or its UUID. Numbers are returned as they are, so the operation that uses
+// them reports a missing item as usual; an unknown UUID is ErrNotFound, and
+// anything else a ValidationError. The zero ItemRef resolves to 0.
+func (a *ToDoActor) Resolve(ref ItemRef) (int, error) {
+	s := strings.TrimSpace(string(ref))
+	if ItemRef(s).IsZero() {
+		return 0, nil
+	}
+	if id, err := strconv.Atoi(s); err == nil && id > 0 {
+		return id, nil
+	}
+	u, err := uuid.Parse(s)
+	if err != nil {
+		return 0, &ValidationError{Field: "id", Message: fmt.Sprintf("%q is neither an item number nor a UUID", s)}
+	}
+	reply := make(chan itemReply)
+	a.send(findUUIDMsg{u.String(), reply})
+	r := <-reply
+	return r.item.ID, r.err
+}
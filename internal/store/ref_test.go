@@ -0,0 +1,74 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestItemRef_JSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ItemRef
+	}{
+		{`3`, "3"},
+		{`"3"`, "3"},
+		{`" 0b5e7b4c-5f0e-4d7c-9d0a-6a3b1f2e4c5d "`, "0b5e7b4c-5f0e-4d7c-9d0a-6a3b1f2e4c5d"},
+		{`0`, ""},
+		{`null`, ""},
+	}
+	for _, tt := range tests {
+		var got ItemRef
+		if err := json.Unmarshal([]byte(tt.in), &got); err != nil || got != tt.want {
+			t.Errorf("unmarshal %s: expected %q, got %q (%v)", tt.in, tt.want, got, err)
+		}
+	}
+	if err := json.Unmarshal([]byte(`true`), new(ItemRef)); err == nil {
+		t.Error("expected an error for a boolean reference")
+	}
+	if data, _ := json.Marshal(RefID(12)); string(data) != `12` {
+		t.Errorf("expected a number reference to marshal as a number, got %s", data)
+	}
+}
+
+func TestToDoActor_Resolve(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "Task"})
+	if item.UUID == "" {
+		t.Fatal("expected new items to get a UUID")
+	}
+
+	for _, ref := range []ItemRef{RefID(item.ID), ItemRef(item.UUID)} {
+		if id, err := actor.Resolve(ref); err != nil || id != item.ID {
+			t.Errorf("Resolve(%q): expected %d, got %d (%v)", ref, item.ID, id, err)
+		}
+	}
+	if _, err := actor.Resolve("0b5e7b4c-5f0e-4d7c-9d0a-6a3b1f2e4c5d"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown UUID, got %v", err)
+	}
+	var ve *ValidationError
+	if _, err := actor.Resolve("first"); !errors.As(err, &ve) {
+		t.Errorf("expected a ValidationError for a malformed reference, got %v", err)
+	}
+}
+
+func TestToDoActor_NeverReusesIDs(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil, WithLastID(5))
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "First"})
+	if item.ID != 6 {
+		t.Fatalf("expected to continue from the last assigned ID, got %d", item.ID)
+	}
+	actor.RemoveItem(ctx, item.ID)
+	actor.PurgeTrash(ctx, time.Now().Add(time.Hour))
+
+	next, _ := actor.CreateItem(ctx, ItemInput{Description: "Second"})
+	if next.ID != 7 {
+		t.Errorf("expected the purged ID not to be reused, got %d", next.ID)
+	}
+	if snap := actor.Snapshot(); snap.LastID != 7 {
+		t.Errorf("expected the snapshot to carry the last ID, got %d", snap.LastID)
+	}
+}
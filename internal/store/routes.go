@@ -0,0 +1,86 @@
+package store
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// Routes registers the API on “mux”. Items are resources:
+//
+//	GET    /items        every item (?list=, ?assignee=, ?mine=true, ...)
+//	POST   /items        create an item
+//	GET    /items/{id}   one item, by number or UUID
+//	PATCH  /items/{id}   change some fields
+//	PUT    /items/{id}   replace the editable fields
+//	DELETE /items/{id}   move an item to the trash
+//
+//	POST   /items/{id}/restore             take it out of the trash
+//	POST   /items/{id}/move                give it a new parent or list
+//	PUT    /items/{id}/blockers/{blocker}  make it wait for another item
+//	DELETE /items/{id}/blockers/{blocker}  stop it waiting
+//	GET    /items/{id}/history             its recorded changes
+//
+// The RPC-style endpoints they replace (/create, /get, /update, /delete,
+// /restore, /move, /block, /unblock, /history) remain as deprecated
+// aliases. /undo and /redo act on the whole store rather than one item, so
+// they stay as they are. Every route names its method, so ServeMux
+// answers any other method with 405 and an Allow header.
+func (api *API) Routes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /items", api.Get)
+	mux.HandleFunc("POST /items", api.CreateItem)
+	mux.HandleFunc("GET /items/{id}", api.GetItem)
+	mux.HandleFunc("PATCH /items/{id}", api.PatchItem)
+	mux.HandleFunc("PUT /items/{id}", api.ReplaceItem)
+	mux.HandleFunc("DELETE /items/{id}", api.DeleteItem)
+	mux.HandleFunc("POST /items/{id}/restore", api.RestoreItem)
+	mux.HandleFunc("POST /items/{id}/move", api.MoveItem)
+	mux.HandleFunc("PUT /items/{id}/blockers/{blocker}", api.AddBlocker)
+	mux.HandleFunc("DELETE /items/{id}/blockers/{blocker}", api.RemoveBlocker)
+	mux.HandleFunc("GET /items/{id}/history", api.ItemHistory)
+
+	mux.HandleFunc("POST /create", deprecated("/items", api.Create))
+	mux.HandleFunc("GET /get", deprecated("/items", api.Get))
+	mux.HandleFunc("POST /update", deprecated("/items/{id}", api.Update))
+	mux.HandleFunc("POST /delete", deprecated("/items/{id}", api.Delete))
+	mux.HandleFunc("POST /restore", deprecated("/items/{id}/restore", api.Restore))
+	mux.HandleFunc("POST /move", deprecated("/items/{id}/move", api.Move))
+	mux.HandleFunc("POST /block", deprecated("/items/{id}/blockers/{blocker}", api.Block))
+	mux.HandleFunc("POST /unblock", deprecated("/items/{id}/blockers/{blocker}", api.Unblock))
+	mux.HandleFunc("GET /history", deprecated("/items/{id}/history", api.History))
+
+	mux.HandleFunc("POST /batch", api.Batch)
+	mux.HandleFunc("GET /search", api.Search)
+	mux.HandleFunc("GET /events", api.Events)
+	mux.HandleFunc("GET /ready", api.Ready)
+	mux.HandleFunc("GET /status", api.Status)
+	mux.HandleFunc("GET /workflow", api.Workflow)
+	mux.HandleFunc("POST /undo", api.Undo)
+	mux.HandleFunc("POST /redo", api.Redo)
+
+	mux.HandleFunc("GET /lists", api.Lists)
+	mux.HandleFunc("POST /lists", api.CreateList)
+	mux.HandleFunc("DELETE /lists/{name}", api.DeleteList)
+	mux.HandleFunc("GET /lists/{name}/items", api.Get)
+	mux.HandleFunc("POST /lists/{name}/items", api.CreateItem)
+
+	mux.HandleFunc("GET /webhooks", api.Webhooks)
+	mux.HandleFunc("POST /webhooks", api.CreateWebhook)
+	mux.HandleFunc("DELETE /webhooks/{id}", api.DeleteWebhook)
+	mux.HandleFunc("GET /webhooks/dead-letters", api.DeadLetters)
+
+	mux.HandleFunc("GET /users", api.Users)
+	mux.HandleFunc("POST /users", api.CreateUser)
+	mux.HandleFunc("DELETE /users/{id}", api.DeleteUser)
+}
+
+// deprecated marks the responses of an old endpoint with a Deprecation
+// header and a Link to the resource that replaces it.
+func deprecated(successor string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		traceID, _ := r.Context().Value(TraceIDKey).(string)
+		slog.Warn("Deprecated endpoint called", "method", r.Method, "path", r.URL.Path, "successor", successor, "traceID", traceID)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		h(w, r)
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestMux(actor *ToDoActor) func(method, path, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	(&API{Actor: actor}).Routes(mux)
+	return func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(body)).WithContext(testCtx()))
+		return w
+	}
+}
+
+func TestRoutes_ItemResource(t *testing.T) {
+	actor := NewToDoActor(nil)
+	serve := newTestMux(actor)
+
+	w := serve(http.MethodPost, "/items", `{"description":"Plan","priority":"high","tags":["work"]}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/items/1" {
+		t.Fatalf("expected 201 with a Location, got %d %q: %s", w.Code, w.Header().Get("Location"), w.Body)
+	}
+	var item Item
+	json.NewDecoder(w.Body).Decode(&item)
+
+	if w := serve(http.MethodGet, "/items/"+item.UUID, ""); w.Code != http.StatusOK {
+		t.Errorf("expected GET by UUID to succeed, got %d", w.Code)
+	}
+	w = serve(http.MethodPatch, "/items/1", `{"status":"started"}`)
+	json.NewDecoder(w.Body).Decode(&item)
+	if w.Code != http.StatusOK || item.Status != StatusStarted || item.Priority != "high" {
+		t.Errorf("expected PATCH to change only the status, got %d %+v", w.Code, item)
+	}
+	w = serve(http.MethodPut, "/items/1", `{"description":"Plan it"}`)
+	item = Item{}
+	json.NewDecoder(w.Body).Decode(&item)
+	if w.Code != http.StatusOK || item.Description != "Plan it" || item.Priority != "" || len(item.Tags) != 0 || item.Status != StatusStarted {
+		t.Errorf("expected PUT to clear omitted fields but keep the status, got %d %+v", w.Code, item)
+	}
+	if w := serve(http.MethodPut, "/items/1", `{}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for PUT without a description, got %d", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/items/1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 for DELETE, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/items/1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a trashed item, got %d", w.Code)
+	}
+	w = serve(http.MethodGet, "/items?include_trashed=true", "")
+	var items []Item
+	if json.NewDecoder(w.Body).Decode(&items); len(items) != 1 {
+		t.Errorf("expected the trashed item in the full listing, got %+v", items)
+	}
+}
+
+func TestRoutes_ItemSubresources(t *testing.T) {
+	actor := NewToDoActor(nil)
+	serve := newTestMux(actor)
+	serve(http.MethodPost, "/items", `{"description":"Plan"}`)
+	serve(http.MethodPost, "/items", `{"description":"Build"}`)
+
+	var item Item
+	w := serve(http.MethodPut, "/items/2/blockers/1", "")
+	if json.NewDecoder(w.Body).Decode(&item); w.Code != http.StatusOK || len(item.BlockedBy) != 1 || item.BlockedBy[0] != 1 {
+		t.Errorf("expected item 2 blocked by 1, got %d %+v", w.Code, item)
+	}
+	if w := serve(http.MethodPut, "/items/1/blockers/2", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a cycle, got %d", w.Code)
+	}
+	w = serve(http.MethodDelete, "/items/2/blockers/1", "")
+	item = Item{}
+	if json.NewDecoder(w.Body).Decode(&item); w.Code != http.StatusOK || len(item.BlockedBy) != 0 {
+		t.Errorf("expected the blocker removed, got %d %+v", w.Code, item)
+	}
+
+	w = serve(http.MethodPost, "/items/2/move", `{"parent_id":1}`)
+	if json.NewDecoder(w.Body).Decode(&item); w.Code != http.StatusOK || item.ParentID != 1 {
+		t.Errorf("expected item 2 under 1, got %d %+v", w.Code, item)
+	}
+
+	serve(http.MethodDelete, "/items/2", "")
+	w = serve(http.MethodPost, "/items/2/restore", "")
+	item = Item{}
+	if json.NewDecoder(w.Body).Decode(&item); w.Code != http.StatusOK || item.ID != 2 || !item.DeletedAt.IsZero() {
+		t.Errorf("expected item 2 restored, got %d %+v", w.Code, item)
+	}
+	if w := serve(http.MethodPost, "/items/2/restore", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for an item not in the trash, got %d", w.Code)
+	}
+
+	w = serve(http.MethodGet, "/items/2/history", "")
+	var changes []Change
+	if json.NewDecoder(w.Body).Decode(&changes); w.Code != http.StatusOK || len(changes) == 0 {
+		t.Errorf("expected the history of item 2, got %d %+v", w.Code, changes)
+	}
+	if w := serve(http.MethodGet, "/items/9/history", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for the history of a missing item, got %d", w.Code)
+	}
+}
+
+func TestRoutes_MethodNotAllowed(t *testing.T) {
+	serve := newTestMux(NewToDoActor(nil))
+	tests := []struct {
+		method, path, allow string
+	}{
+		{http.MethodDelete, "/items", "GET, HEAD, POST"},
+		{http.MethodPost, "/items/1", "DELETE, GET, HEAD, PATCH, PUT"},
+		{http.MethodGet, "/delete", "POST"},
+		{http.MethodPost, "/get", "GET, HEAD"},
+		{http.MethodGet, "/undo", "POST"},
+	}
+	for _, tt := range tests {
+		w := serve(tt.method, tt.path, `{"id":1}`)
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s %s: expected 405 with Allow %q, got %d %q", tt.method, tt.path, tt.allow, w.Code, w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestRoutes_DeprecatedAliases(t *testing.T) {
+	actor := NewToDoActor(nil)
+	serve := newTestMux(actor)
+
+	w := serve(http.MethodPost, "/create", `{"description":"Old style"}`)
+	if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "true" || w.Header().Get("Link") != `</items>; rel="successor-version"` {
+		t.Errorf("expected a deprecated 200, got %d %v", w.Code, w.Header())
+	}
+	if w := serve(http.MethodPost, "/update", `{"id":1,"status":"completed"}`); w.Code != http.StatusOK {
+		t.Errorf("expected /update to keep working, got %d", w.Code)
+	}
+	if w := serve(http.MethodPost, "/delete", `{"id":1}`); w.Code != http.StatusNoContent {
+		t.Errorf("expected /delete to keep working, got %d", w.Code)
+	}
+	if items := actor.GetItems(); len(items) != 0 {
+		t.Errorf("expected the item in the trash, got %+v", items)
+	}
+	w = serve(http.MethodPost, "/restore", `{"id":1}`)
+	if w.Code != http.StatusOK || w.Header().Get("Link") != `</items/{id}/restore>; rel="successor-version"` {
+		t.Errorf("expected a deprecated /restore, got %d %v", w.Code, w.Header())
+	}
+	serve(http.MethodPost, "/items", `{"description":"New style"}`)
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPost, "/block", `{"id":2,"blocker_id":1}`},
+		{http.MethodPost, "/unblock", `{"id":2,"blocker_id":1}`},
+		{http.MethodPost, "/move", `{"id":2,"parent_id":1}`},
+		{http.MethodGet, "/history?id=2", ""},
+	} {
+		if w := serve(req.method, req.path, req.body); w.Code != http.StatusOK || w.Header().Get("Deprecation") != "true" {
+			t.Errorf("expected %s %s to keep working, deprecated, got %d %v", req.method, req.path, w.Code, w.Header())
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package store
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// SearchResult is an item found by ToDoActor.Search, with its relevance
+// and the indexed words that matched the query.
+type SearchResult struct {
+	Item
+	Score   float64  `json:"score"`
+	Matches []string `json:"matches"`
+}
+
+// token is a word of an item's text: its case-folded form and where it
+// sits in the original text, in bytes.
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits “text” into words: runs of letters and digits, folded to
+// lower case.
+func tokenize(text string) []token {
+	var out []token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsNumber(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			out = append(out, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out = append(out, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return out
+}
+
+// queryTerms returns the distinct words of a search query.
+func queryTerms(query string) []string {
+	var terms []string
+	for _, t := range tokenize(query) {
+		if !slices.Contains(terms, t.term) {
+			terms = append(terms, t.term)
+		}
+	}
+	return terms
+}
+
+// searchText is the text of an item that search looks at. Notes will join
+// the description here once items have them.
+func searchText(it Item) string {
+	return it.Description
+}
+
+// searchIndex is an inverted index over the text of the live items. The
+// actor keeps it up to date with every write, so searching never has to
+// look at every item.
+type searchIndex struct {
+	postings map[string]map[int]int // term → item ID → occurrences
+	terms    []string               // every term, sorted, for prefix lookups
+	docs     map[int][]string       // item ID → its terms, with repeats
+}
+
+func newSearchIndex(items []Item) *searchIndex {
+	x := &searchIndex{postings: map[string]map[int]int{}, docs: map[int][]string{}}
+	for _, it := range items {
+		x.add(it)
+	}
+	return x
+}
+
+// apply updates the index with the writes of one mutation.
+func (x *searchIndex) apply(entries []JournalEntry) {
+	for _, e := range entries {
+		switch e.Op {
+		case JournalPut:
+			x.add(*e.Item)
+		case JournalDelete:
+			x.remove(e.ID)
+		}
+	}
+}
+
+// add (re)indexes “it”; items in the trash are left out.
+func (x *searchIndex) add(it Item) {
+	x.remove(it.ID)
+	if !it.DeletedAt.IsZero() {
+		return
+	}
+	var terms []string
+	for _, t := range tokenize(searchText(it)) {
+		terms = append(terms, t.term)
+		p, ok := x.postings[t.term]
+		if !ok {
+			p = map[int]int{}
+			x.postings[t.term] = p
+			i, _ := slices.BinarySearch(x.terms, t.term)
+			x.terms = slices.Insert(x.terms, i, t.term)
+		}
+		p[it.ID]++
+	}
+	x.docs[it.ID] = terms
+}
+
+func (x *searchIndex) remove(id int) {
+	for _, term := range x.docs[id] {
+		p := x.postings[term]
+		delete(p, id)
+		if len(p) == 0 {
+			delete(x.postings, term)
+			if i, ok := slices.BinarySearch(x.terms, term); ok {
+				x.terms = slices.Delete(x.terms, i, i+1)
+			}
+		}
+	}
+	delete(x.docs, id)
+}
+
+// expand returns the indexed terms that start with “prefix”.
+func (x *searchIndex) expand(prefix string) []string {
+	i, _ := slices.BinarySearch(x.terms, prefix)
+	j := i
+	for j < len(x.terms) && strings.HasPrefix(x.terms[j], prefix) {
+		j++
+	}
+	return x.terms[i:j]
+}
+
+// hit is an item matching a search, before it is turned into a SearchResult.
+type hit struct {
+	id      int
+	score   float64
+	matches []string
+}
+
+// search returns the items that match every word of “query”, each word
+// either exactly or as the start of a longer word, best match first. An
+// item scores the TF-IDF of its best match for each query word, exact
+// matches counting double, divided by the square root of its length so
+// that short descriptions that are mostly the query rank first.
+func (x *searchIndex) search(query string) []hit {
+	qterms := queryTerms(query)
+	if len(qterms) == 0 {
+		return nil
+	}
+	n := float64(len(x.docs))
+	var found map[int]*hit
+	for _, q := range qterms {
+		best := map[int]float64{}
+		matched := map[int][]string{}
+		for _, term := range x.expand(q) {
+			p := x.postings[term]
+			weight := math.Log(1 + n/float64(len(p)))
+			if term != q {
+				weight /= 2
+			}
+			for id, tf := range p {
+				best[id] = max(best[id], float64(tf)*weight)
+				matched[id] = append(matched[id], term)
+			}
+		}
+		next := map[int]*hit{}
+		for id, s := range best {
+			h := found[id]
+			switch {
+			case found == nil:
+				h = &hit{id: id}
+			case h == nil:
+				continue // did not match an earlier query word
+			}
+			h.score += s
+			h.matches = append(h.matches, matched[id]...)
+			next[id] = h
+		}
+		found = next
+	}
+	hits := make([]hit, 0, len(found))
+	for id, h := range found {
+		h.score /= math.Sqrt(float64(len(x.docs[id])))
+		slices.Sort(h.matches)
+		h.matches = slices.Compact(h.matches)
+		hits = append(hits, *h)
+	}
+	slices.SortFunc(hits, func(a, b hit) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+	return hits
+}
+
+// MatchRanges returns the byte ranges of the words in “text” that a search
+// for “query” matches, in order, for highlighting.
+func MatchRanges(text, query string) [][2]int {
+	qterms := queryTerms(query)
+	var out [][2]int
+	for _, t := range tokenize(text) {
+		if slices.ContainsFunc(qterms, func(q string) bool { return strings.HasPrefix(t.term, q) }) {
+			out = append(out, [2]int{t.start, t.end})
+		}
+	}
+	return out
+}
+
+// search replies with the best matches of a search, in the list if one is given.
+func (s *actorState) search(m searchMsg) {
+	if m.list != "" && !s.hasList(m.list) {
+		m.reply <- searchReply{err: ErrListNotFound}
+		return
+	}
+	results := []SearchResult{}
+	for _, h := range s.index.search(m.query) {
+		it := s.items[s.find(h.id)]
+		if m.list != "" && it.List != m.list {
+			continue
+		}
+		results = append(results, SearchResult{Item: it, Score: h.score, Matches: h.matches})
+		if len(results) == m.limit {
+			break
+		}
+	}
+	m.reply <- searchReply{results: results}
+}
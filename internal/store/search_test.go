@@ -0,0 +1,103 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	var got []string
+	for _, tok := range tokenize("Café: buy 2 OAT-milk!") {
+		got = append(got, tok.term)
+	}
+	if want := []string{"café", "buy", "2", "oat", "milk"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestToDoActor_Search(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor([]Item{{ID: 1, Description: "Buy milk and bread"}})
+	actor.CreateItem(ctx, ItemInput{Description: "Milk"})
+	actor.CreateItem(ctx, ItemInput{Description: "Millstone repairs"})
+	actor.CreateItem(ctx, ItemInput{Description: "Call the milkman about milk"})
+
+	search := func(q string) []int {
+		t.Helper()
+		results, err := actor.Search(q, "", 0)
+		if err != nil {
+			t.Fatalf("Search(%q) failed: %v", q, err)
+		}
+		ids := make([]int, len(results))
+		for i, r := range results {
+			ids[i] = r.ID
+		}
+		return ids
+	}
+
+	// Shorter descriptions rank first.
+	if got := search("MILK"); !slices.Equal(got, []int{2, 1, 4}) {
+		t.Errorf("expected matches ranked by relevance, got %v", got)
+	}
+	if got := search("mil"); len(got) != 4 {
+		t.Errorf("expected a prefix to match every mil- word, got %v", got)
+	}
+	if got := search("milk bread"); !slices.Equal(got, []int{1}) {
+		t.Errorf("expected every word to be required, got %v", got)
+	}
+
+	// The index follows every write.
+	desc := "Buy oat drink"
+	actor.PatchItem(ctx, 1, ItemPatch{Description: &desc})
+	if got := search("bread"); len(got) != 0 {
+		t.Errorf("expected the old description to be forgotten, got %v", got)
+	}
+	if got := search("oat"); !slices.Equal(got, []int{1}) {
+		t.Errorf("expected the new description to be indexed, got %v", got)
+	}
+	actor.RemoveItem(ctx, 2)
+	if got := search("milk"); slices.Contains(got, 2) {
+		t.Errorf("expected trashed items to be left out, got %v", got)
+	}
+	actor.Undo(ctx, 1)
+	if got := search("milk"); !slices.Contains(got, 2) {
+		t.Errorf("expected an undone delete to be found again, got %v", got)
+	}
+
+	results, _ := actor.Search("milk", "", 2)
+	if len(results) != 2 || !slices.Equal(results[1].Matches, []string{"milk", "milkman"}) {
+		t.Errorf("expected one result with its matched words, got %+v", results)
+	}
+	var ve *ValidationError
+	if _, err := actor.Search(" ,. ", "", 0); !errors.As(err, &ve) {
+		t.Errorf("expected a ValidationError for an empty query, got %v", err)
+	}
+	if _, err := actor.Search("milk", "nowhere", 0); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("expected ErrListNotFound, got %v", err)
+	}
+}
+
+func TestMatchRanges(t *testing.T) {
+	text := "Milk, milkman & mill"
+	got := MatchRanges(text, "milk")
+	if len(got) != 2 || text[got[0][0]:got[0][1]] != "Milk" || text[got[1][0]:got[1][1]] != "milkman" {
+		t.Errorf("unexpected ranges %v", got)
+	}
+}
+
+func TestAPI_Search(t *testing.T) {
+	actor := NewToDoActor([]Item{{ID: 1, Description: "Buy milk"}, {ID: 2, Description: "Walk dog"}})
+	serve := newTestMux(actor)
+
+	w := serve(http.MethodGet, "/search?q=mi", "")
+	var results []SearchResult
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil || len(results) != 1 || results[0].ID != 1 || results[0].Score <= 0 {
+		t.Errorf("expected item 1 with a score, got %+v (%v)", results, err)
+	}
+	if w := serve(http.MethodGet, "/search", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without q, got %d", w.Code)
+	}
+}
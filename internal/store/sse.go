@@ -0,0 +1,77 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// eventHeartbeat is how often an idle event stream gets a comment line, so
+// that proxies do not take it for a dead connection.
+const eventHeartbeat = 15 * time.Second
+
+// Events streams the item events as Server-Sent Events, GET /events: each
+// with its ID, its type as the event name and the Event as JSON data. A
+// client that reconnects with Last-Event-ID (or ?last_event_id=, for
+// clients that cannot set headers) first gets the events it missed; if
+// they are no longer kept it gets a "reset" event and should reload the
+// items. A client that falls behind is disconnected and can reconnect the
+// same way.
+func (api *API) Events(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get("last_event_id")
+	}
+	sub := api.Actor.Subscribe(last)
+	defer sub.Close()
+	slog.Info("Event stream opened", "last_event_id", last, "replay", len(sub.Replay), "missed", sub.Missed, "traceID", traceID)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if sub.Missed {
+		fmt.Fprintf(w, "id: %s\nevent: reset\ndata: {}\n\n", sub.LastID)
+	}
+	for _, ev := range sub.Replay {
+		writeEvent(w, ev)
+	}
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		slog.Error("Event stream cannot be flushed", "error", err, "traceID", traceID)
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Event stream closed by the client", "traceID", traceID)
+			return
+		case ev, ok := <-sub.C:
+			if !ok {
+				slog.Info("Event stream ended by the server", "traceID", traceID)
+				return
+			}
+			writeEvent(w, ev)
+		case <-heartbeat.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes “ev” in the text/event-stream format.
+func writeEvent(w io.Writer, ev Event) {
+	data, _ := json.Marshal(ev)
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Status categories. Every status belongs to one, and the rules about
+// blockers, readiness, subtask progress, recurrence and timestamps look only
+// at the category, so custom statuses behave like the built-in ones.
+const (
+	CategoryTodo   = "todo"   // not started yet
+	CategoryActive = "active" // being worked on
+	CategoryDone   = "done"   // finished; leaving it needs an explicit reopen
+)
+
+// StatusDef declares a status and its category.
+type StatusDef struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// Workflow is the status state machine: the statuses an item may have and
+// the moves allowed between them. Moving out of a done status is never a
+// plain transition; it has to be asked for as a reopen.
+type Workflow struct {
+	// Statuses lists every status; the first one is given to new items and
+	// is where a reopened item goes unless another status is asked for.
+	Statuses []StatusDef `json:"statuses"`
+	// Transitions maps a status to the statuses it may move to.
+	Transitions map[string][]string `json:"transitions"`
+}
+
+// DefaultWorkflow returns the built-in workflow: not started ⇄ started,
+// either of them → completed, and completed back only by reopening.
+func DefaultWorkflow() *Workflow {
+	return &Workflow{
+		Statuses: []StatusDef{
+			{Name: StatusNotStarted, Category: CategoryTodo},
+			{Name: StatusStarted, Category: CategoryActive},
+			{Name: StatusCompleted, Category: CategoryDone},
+		},
+		Transitions: map[string][]string{
+			StatusNotStarted: {StatusStarted, StatusCompleted},
+			StatusStarted:    {StatusNotStarted, StatusCompleted},
+		},
+	}
+}
+
+// Validate checks that every status has a known category, names are unique,
+// the first status is a todo status and transitions only name declared statuses.
+func (w *Workflow) Validate() error {
+	invalid := func(format string, args ...any) error {
+		return &ValidationError{Field: "workflow", Message: fmt.Sprintf(format, args...)}
+	}
+	if len(w.Statuses) == 0 {
+		return invalid("no statuses declared")
+	}
+	seen := make(map[string]bool, len(w.Statuses))
+	for _, s := range w.Statuses {
+		switch {
+		case strings.TrimSpace(s.Name) == "":
+			return invalid("status without a name")
+		case seen[s.Name]:
+			return invalid("status %q declared twice", s.Name)
+		}
+		switch s.Category {
+		case CategoryTodo, CategoryActive, CategoryDone:
+		default:
+			return invalid("status %q has category %q, expected todo, active or done", s.Name, s.Category)
+		}
+		seen[s.Name] = true
+	}
+	if w.Statuses[0].Category != CategoryTodo {
+		return invalid("the first status, %q, must be in the todo category", w.Statuses[0].Name)
+	}
+	for from, targets := range w.Transitions {
+		if !seen[from] {
+			return invalid("transition from undeclared status %q", from)
+		}
+		for _, to := range targets {
+			if !seen[to] {
+				return invalid("transition from %q to undeclared status %q", from, to)
+			}
+		}
+	}
+	return nil
+}
+
+// Initial returns the status new and reopened items get.
+func (w *Workflow) Initial() string {
+	return w.Statuses[0].Name
+}
+
+// Names returns every status in declaration order.
+func (w *Workflow) Names() []string {
+	names := make([]string, len(w.Statuses))
+	for i, s := range w.Statuses {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// Category returns the category of “status”, or "" if it is not declared.
+func (w *Workflow) Category(status string) string {
+	for _, s := range w.Statuses {
+		if s.Name == status {
+			return s.Category
+		}
+	}
+	return ""
+}
+
+func (w *Workflow) isDone(status string) bool {
+	return w.Category(status) == CategoryDone
+}
+
+// check reports whether an item may move from “from” to “to”. Unknown
+// targets are a ValidationError; disallowed moves are a *TransitionError.
+// An item whose current status is not declared (e.g. left over from an
+// older workflow) may move to any declared status.
+func (w *Workflow) check(id int, from, to string, reopen bool) error {
+	if w.Category(to) == "" {
+		return &ValidationError{Field: "status", Message: fmt.Sprintf("unknown status %q, expected one of %s", to, strings.Join(w.Names(), ", "))}
+	}
+	if from == to && !reopen {
+		return nil
+	}
+	fromCategory := w.Category(from)
+	if reopen {
+		if fromCategory != CategoryDone || w.isDone(to) {
+			return &TransitionError{ID: id, From: from, To: to, Reopen: true}
+		}
+		return nil
+	}
+	if fromCategory == "" || slices.Contains(w.Transitions[from], to) {
+		return nil
+	}
+	return &TransitionError{ID: id, From: from, To: to, Allowed: w.Transitions[from], NeedsReopen: fromCategory == CategoryDone}
+}
+
+// stamp updates the lifecycle timestamps of an item that just moved into
+// its current status at “now”.
+func (w *Workflow) stamp(it *Item, now time.Time) {
+	switch w.Category(it.Status) {
+	case CategoryTodo:
+		it.StartedAt, it.CompletedAt = time.Time{}, time.Time{}
+	case CategoryActive:
+		if it.StartedAt.IsZero() {
+			it.StartedAt = now
+		}
+		it.CompletedAt = time.Time{}
+	case CategoryDone:
+		it.CompletedAt = now
+	}
+}
+
+// LoadWorkflow reads a workflow from a JSON file such as
+//
+//	{"statuses": [{"name": "todo", "category": "todo"}, ...],
+//	 "transitions": {"todo": ["doing"], ...}}
+func LoadWorkflow(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var w Workflow
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, fmt.Errorf("parse workflow %s: %w", path, err)
+	}
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// WithWorkflow replaces the built-in DefaultWorkflow.
+func WithWorkflow(w *Workflow) ActorOption {
+	return func(a *ToDoActor) {
+		a.workflow = w
+	}
+}
+
+// TransitionError is returned when the workflow does not allow a status
+// change. It matches ErrInvalidTransition.
+type TransitionError struct {
+	ID          int
+	From, To    string
+	Reopen      bool     // the move was asked for as a reopen
+	NeedsReopen bool     // “From” is finished, so leaving it needs a reopen
+	Allowed     []string // statuses “From” may move to without reopening
+}
+
+func (e *TransitionError) Error() string {
+	switch {
+	case e.Reopen:
+		return fmt.Sprintf("item %d cannot be reopened from %q to %q: only finished items can be reopened, into an unfinished status", e.ID, e.From, e.To)
+	case e.NeedsReopen:
+		return fmt.Sprintf("item %d cannot move from %q to %q without reopening it", e.ID, e.From, e.To)
+	case len(e.Allowed) == 0:
+		return fmt.Sprintf("item %d cannot move from %q to %q: no moves out of %q are allowed", e.ID, e.From, e.To, e.From)
+	}
+	return fmt.Sprintf("item %d cannot move from %q to %q, only to %s", e.ID, e.From, e.To, strings.Join(e.Allowed, ", "))
+}
+
+func (e *TransitionError) Is(target error) bool { return target == ErrInvalidTransition }
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// reviewWorkflow adds an active "in review" status and a done "won't do" status.
+func reviewWorkflow() *Workflow {
+	return &Workflow{
+		Statuses: []StatusDef{
+			{Name: "todo", Category: CategoryTodo},
+			{Name: "doing", Category: CategoryActive},
+			{Name: "in review", Category: CategoryActive},
+			{Name: "done", Category: CategoryDone},
+			{Name: "won't do", Category: CategoryDone},
+		},
+		Transitions: map[string][]string{
+			"todo":      {"doing", "won't do"},
+			"doing":     {"todo", "in review"},
+			"in review": {"doing", "done"},
+		},
+	}
+}
+
+func TestWorkflow_Validate(t *testing.T) {
+	if err := DefaultWorkflow().Validate(); err != nil {
+		t.Fatalf("default workflow is invalid: %v", err)
+	}
+	if err := reviewWorkflow().Validate(); err != nil {
+		t.Fatalf("review workflow is invalid: %v", err)
+	}
+	bad := []*Workflow{
+		{},
+		{Statuses: []StatusDef{{Name: "a", Category: "later"}}},
+		{Statuses: []StatusDef{{Name: "a", Category: CategoryTodo}, {Name: "a", Category: CategoryDone}}},
+		{Statuses: []StatusDef{{Name: "a", Category: CategoryDone}}},
+		{Statuses: []StatusDef{{Name: "a", Category: CategoryTodo}}, Transitions: map[string][]string{"a": {"b"}}},
+	}
+	for i, w := range bad {
+		var verr *ValidationError
+		if err := w.Validate(); !errors.As(err, &verr) {
+			t.Errorf("workflow %d: expected ValidationError, got %v", i, err)
+		}
+	}
+}
+
+func TestLoadWorkflow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workflow.json")
+	data := `{"statuses": [{"name": "todo", "category": "todo"}, {"name": "done", "category": "done"}],
+		"transitions": {"todo": ["done"]}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := LoadWorkflow(path)
+	if err != nil {
+		t.Fatalf("LoadWorkflow failed: %v", err)
+	}
+	if w.Initial() != "todo" || !w.isDone("done") {
+		t.Errorf("unexpected workflow %+v", w)
+	}
+}
+
+func TestToDoActor_StatusTransitions(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor(nil)
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "Write report"})
+	if item.Status != StatusNotStarted || item.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected new item %+v", item)
+	}
+
+	set := func(status string, reopen bool) (Item, error) {
+		return actor.PatchItem(ctx, item.ID, ItemPatch{Status: &status, Reopen: reopen})
+	}
+	var verr *ValidationError
+	if _, err := set("banana", false); !errors.As(err, &verr) {
+		t.Errorf("expected ValidationError for unknown status, got %v", err)
+	}
+
+	started, err := set(StatusStarted, false)
+	if err != nil || started.StartedAt.IsZero() || !started.CompletedAt.IsZero() {
+		t.Fatalf("expected StartedAt to be set, got %+v, %v", started, err)
+	}
+	done, err := set(StatusCompleted, false)
+	if err != nil || done.CompletedAt.IsZero() || !done.StartedAt.Equal(started.StartedAt) {
+		t.Fatalf("expected CompletedAt to be set and StartedAt kept, got %+v, %v", done, err)
+	}
+
+	var terr *TransitionError
+	if _, err := set(StatusNotStarted, false); !errors.As(err, &terr) || !errors.Is(err, ErrInvalidTransition) || !terr.NeedsReopen {
+		t.Errorf("expected a TransitionError asking for a reopen, got %v", err)
+	}
+	reopened, err := actor.PatchItem(ctx, item.ID, ItemPatch{Reopen: true})
+	if err != nil || reopened.Status != StatusNotStarted || !reopened.CompletedAt.IsZero() || !reopened.StartedAt.IsZero() {
+		t.Fatalf("expected reopen to reset the item, got %+v, %v", reopened, err)
+	}
+	if _, err := set(StatusStarted, true); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("expected reopening an unfinished item to fail, got %v", err)
+	}
+}
+
+func TestToDoActor_CustomWorkflow(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor(nil, WithWorkflow(reviewWorkflow()))
+	blocker, _ := actor.CreateItem(ctx, ItemInput{Description: "Decide"})
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "Build"})
+	if item.Status != "todo" {
+		t.Fatalf("expected the first workflow status, got %q", item.Status)
+	}
+	if _, err := actor.AddDependency(ctx, item.ID, blocker.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	doing := "doing"
+	if _, err := actor.PatchItem(ctx, item.ID, ItemPatch{Status: &doing}); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected a custom active status to respect blockers, got %v", err)
+	}
+	wontDo := "won't do"
+	if _, err := actor.PatchItem(ctx, blocker.ID, ItemPatch{Status: &wontDo}); err != nil {
+		t.Fatalf("PatchItem failed: %v", err)
+	}
+	if _, err := actor.PatchItem(ctx, item.ID, ItemPatch{Status: &doing}); err != nil {
+		t.Errorf("expected a custom done status to unblock, got %v", err)
+	}
+	done := "done"
+	var terr *TransitionError
+	if _, err := actor.PatchItem(ctx, item.ID, ItemPatch{Status: &done}); !errors.As(err, &terr) || len(terr.Allowed) != 2 {
+		t.Errorf("expected doing → done to be refused with the allowed moves, got %v", err)
+	}
+}
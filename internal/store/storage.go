@@ -5,13 +5,38 @@ import (
 	"encoding/json"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"sync"
 )
 
+// Snapshot is everything a store persists: the lists and their items, the
+// history of changes made to them, the undo log, the webhooks and the users.
+type Snapshot struct {
+	Items    []Item
+	History  []Change
+	Undo     []UndoStep // oldest first
+	Redo     []UndoStep // the undone steps, most recently undone last
+	LastID   int        // largest item ID ever assigned; IDs are never reused
+	Lists    []List
+	Webhooks []Webhook
+	Users    []User
+}
+
 // LoadItems reads a JSON file at path “filename” and returns the slice of Items.
-// If the file does not exist, it returns an empty slice and no error. Any other error is returned directly.
+// It is LoadSnapshot without the history.
 func LoadItems(ctx context.Context, filename string) ([]Item, error) {
+	snap, err := LoadSnapshot(ctx, filename)
+	return snap.Items, err
+}
+
+// LoadSnapshot reads the JSON file at path “filename”.
+// Files written with an older schema (including the original bare array) are
+// migrated to SchemaVersion; the original is kept in “filename.vN.bak” and the
+// upgraded document is written back. Any journal next to the file is replayed.
+// If the file does not exist, it returns an empty list and no error. Any other error is returned directly.
+func LoadSnapshot(ctx context.Context, filename string) (Snapshot, error) {
 	traceID, _ := ctx.Value(TraceIDKey).(string)
-	f, err := os.Open(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		// If the file simply doesn’t exist, we start with an empty list.
 		if os.IsNotExist(err) {
@@ -19,52 +44,128 @@ func LoadItems(ctx context.Context, filename string) ([]Item, error) {
 				"file", filename,
 				"traceID", traceID,
 			)
-			return []Item{}, nil
+			snap, _, err := replayJournal(ctx, filename, Snapshot{Items: []Item{}})
+			return snap, err
 		}
 		slog.Error("Failed to open file",
 			"file", filename,
 			"error", err,
 			"traceID", traceID,
 		)
-		return nil, err
+		return Snapshot{}, err
 	}
-	defer f.Close()
 
-	var items []Item
-	if err := json.NewDecoder(f).Decode(&items); err != nil {
+	doc, version, err := decodeDocument(data)
+	if err == nil && version != SchemaVersion {
+		if version < SchemaVersion {
+			if err := writeBackup(filename, version, data); err != nil {
+				slog.Error("Failed to back up file before migration",
+					"file", filename,
+					"error", err,
+					"traceID", traceID,
+				)
+				return Snapshot{}, err
+			}
+		}
+		err = migrateDocument(ctx, doc, version)
+	}
+	var snap Snapshot
+	if err == nil {
+		err = json.Unmarshal(doc["items"], &snap.Items)
+	}
+	if raw, ok := doc["history"]; ok && err == nil {
+		err = json.Unmarshal(raw, &snap.History)
+	}
+	if raw, ok := doc["undo"]; ok && err == nil {
+		err = json.Unmarshal(raw, &snap.Undo)
+	}
+	if raw, ok := doc["redo"]; ok && err == nil {
+		err = json.Unmarshal(raw, &snap.Redo)
+	}
+	if raw, ok := doc["last_id"]; ok && err == nil {
+		err = json.Unmarshal(raw, &snap.LastID)
+	}
+	if raw, ok := doc["lists"]; ok && err == nil {
+		err = json.Unmarshal(raw, &snap.Lists)
+	}
+	if raw, ok := doc["webhooks"]; ok && err == nil {
+		err = json.Unmarshal(raw, &snap.Webhooks)
+	}
+	if raw, ok := doc["users"]; ok && err == nil {
+		err = json.Unmarshal(raw, &snap.Users)
+	}
+	if err != nil {
 		slog.Error("Failed to decode items from file",
 			"file", filename,
 			"error", err,
 			"traceID", traceID,
 		)
-		return nil, err
+		return Snapshot{}, err
+	}
+	if snap.Items == nil {
+		snap.Items = []Item{}
+	}
+	snap, _, err = replayJournal(ctx, filename, snap)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if version < SchemaVersion {
+		if err := SaveSnapshot(ctx, filename, snap); err != nil {
+			return Snapshot{}, err
+		}
 	}
 	slog.Info("Loaded items from file",
 		"file", filename,
-		"count", len(items),
+		"count", len(snap.Items),
 		"traceID", traceID,
 	)
-	return items, nil
+	return snap, nil
 }
 
-// SaveItems writes the slice of Items as JSON to “filename” (overwriting or creating it).
-// Returns any error encountered while creating or encoding.
+// SaveItems writes the slice of Items to “filename”. The rest of the
+// snapshot already there, such as the history, is loaded first and kept.
 func SaveItems(ctx context.Context, filename string, items []Item) error {
+	snap, err := LoadSnapshot(ctx, filename)
+	if err != nil {
+		return err
+	}
+	snap.Items = items
+	return SaveSnapshot(ctx, filename, snap)
+}
+
+// SaveSnapshot writes “snap” to “filename” (overwriting or creating it) as a JSON
+// document tagged with SchemaVersion.
+// The data is written to a temporary file in the same directory, fsynced and then renamed over
+// “filename”, so a crash part-way through leaves the previous contents intact. Once the new
+// snapshot is in place any journal next to it is folded in and removed.
+// Returns any error encountered while creating, encoding or renaming.
+func SaveSnapshot(ctx context.Context, filename string, snap Snapshot) error {
 	traceID, _ := ctx.Value(TraceIDKey).(string)
-	f, err := os.Create(filename)
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, base+".tmp-*")
 	if err != nil {
-		slog.Error("Failed to create file for saving items",
+		slog.Error("Failed to create temp file for saving items",
 			"file", filename,
 			"error", err,
 			"traceID", traceID,
 		)
 		return err
 	}
-	defer f.Close()
+	tmpName := f.Name()
+	// Any early return leaves the original untouched; just clean up the temp file.
+	defer os.Remove(tmpName)
 
+	doc := fileDocument{Version: SchemaVersion, Items: snap.Items, History: snap.History, Undo: snap.Undo, Redo: snap.Redo, LastID: max(snap.LastID, maxID(snap.Items)), Lists: snap.Lists, Webhooks: snap.Webhooks, Users: snap.Users}
+	if doc.Items == nil {
+		doc.Items = []Item{}
+	}
 	enc := json.NewEncoder(f)
 	enc.SetIndent("", "  ") // pretty-print with two-space indentation
-	if err := enc.Encode(items); err != nil {
+	if err := enc.Encode(doc); err != nil {
+		f.Close()
 		slog.Error("Failed to encode items to file",
 			"file", filename,
 			"error", err,
@@ -72,11 +173,187 @@ func SaveItems(ctx context.Context, filename string, items []Item) error {
 		)
 		return err
 	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		slog.Error("Failed to sync items file",
+			"file", filename,
+			"error", err,
+			"traceID", traceID,
+		)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		slog.Error("Failed to close items file",
+			"file", filename,
+			"error", err,
+			"traceID", traceID,
+		)
+		return err
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		slog.Error("Failed to replace items file",
+			"file", filename,
+			"error", err,
+			"traceID", traceID,
+		)
+		return err
+	}
+	syncDir(dir)
+
+	// The snapshot now contains everything the journal recorded.
+	if err := os.Remove(journalPath(filename)); err != nil && !os.IsNotExist(err) {
+		slog.Error("Failed to remove compacted journal",
+			"file", journalPath(filename),
+			"error", err,
+			"traceID", traceID,
+		)
+		return err
+	}
 	slog.Info("Saved items to file",
 		"file", filename,
-		"count", len(items),
+		"count", len(snap.Items),
 		"traceID", traceID,
 	)
 	return nil
+}
+
+// syncDir fsyncs a directory so a rename inside it survives a crash. Not every
+// platform supports this, so failures are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// Storage persists a to-do list. Load and Save operate on the whole
+// snapshot, while Apply records the writes of a single change, as reported
+// by the actor's change listener, so that callers need not rewrite everything.
+type Storage interface {
+	Load(ctx context.Context) (Snapshot, error)
+	Save(ctx context.Context, snap Snapshot) error
+	Apply(ctx context.Context, entries ...JournalEntry) error
+}
+
+// DefaultCompactEvery is how many journal entries JSONFileStorage accumulates
+// before folding them into a fresh snapshot.
+const DefaultCompactEvery = 100
+
+// JSONFileStorage is the Storage backed by a single pretty-printed JSON file.
+// With Journal enabled, Put and Delete append to “Path.journal” instead of
+// rewriting the whole file, and the journal is compacted into the snapshot
+// once it holds CompactEvery entries.
+type JSONFileStorage struct {
+	Path         string
+	Journal      bool
+	CompactEvery int
+
+	mu         sync.Mutex
+	journalLen int // entries currently in the journal, as far as we know
+}
+
+// NewJSONFileStorage returns a JSONFileStorage that reads and writes “path”.
+func NewJSONFileStorage(path string) *JSONFileStorage {
+	return &JSONFileStorage{Path: path, CompactEvery: DefaultCompactEvery}
+}
+
+func (s *JSONFileStorage) Load(ctx context.Context) (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(ctx)
+}
+
+func (s *JSONFileStorage) Save(ctx context.Context, snap Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(ctx, snap)
+}
+
+// Put inserts or replaces the item with the same ID.
+func (s *JSONFileStorage) Put(ctx context.Context, item Item) error {
+	return s.Apply(ctx, putEntry(item))
+}
+
+// Delete removes the item with the given ID (if present).
+func (s *JSONFileStorage) Delete(ctx context.Context, id int) error {
+	return s.Apply(ctx, deleteEntry(id))
+}
+
+// Apply records the entries, either in the journal or by rewriting the file.
+func (s *JSONFileStorage) Apply(ctx context.Context, entries ...JournalEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.Journal {
+		snap, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			snap = e.apply(snap)
+		}
+		return s.save(ctx, snap)
+	}
+	if err := AppendJournal(ctx, s.Path, entries...); err != nil {
+		return err
+	}
+	s.journalLen += len(entries)
+	if s.CompactEvery > 0 && s.journalLen >= s.CompactEvery {
+		return s.compact(ctx)
+	}
+	return nil
+}
+
+func (s *JSONFileStorage) load(ctx context.Context) (Snapshot, error) {
+	snap, err := LoadSnapshot(ctx, s.Path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	s.journalLen, err = countJournal(s.Path)
+	return snap, err
+}
+
+func (s *JSONFileStorage) save(ctx context.Context, snap Snapshot) error {
+	if err := SaveSnapshot(ctx, s.Path, snap); err != nil {
+		return err
+	}
+	s.journalLen = 0
+	return nil
+}
+
+// compact folds the journal into a fresh snapshot.
+func (s *JSONFileStorage) compact(ctx context.Context) error {
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	snap, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("Compacting journal into snapshot",
+		"file", s.Path,
+		"entries", s.journalLen,
+		"traceID", traceID,
+	)
+	return s.save(ctx, snap)
+}
 
+// putItem replaces the Item whose ID matches “item” or appends it if there is none.
+func putItem(items []Item, item Item) []Item {
+	for i := range items {
+		if items[i].ID == item.ID {
+			items[i] = item
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+// removeItem drops the Item whose ID == id from the slice (if present).
+func removeItem(items []Item, id int) []Item {
+	for i := range items {
+		if items[i].ID == id {
+			return append(items[:i], items[i+1:]...)
+		}
+	}
+	return items
 }
@@ -50,6 +50,35 @@ func TestSaveAndLoadItems(t *testing.T) {
 	}
 }
 
+func TestSaveItems_KeepsRestOfSnapshot(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "test-trace-id")
+	tmpFile := filepath.Join(t.TempDir(), "todos.json")
+	snap := Snapshot{
+		Items:   []Item{{ID: 1, Description: "Old"}},
+		History: []Change{{ItemID: 1, Field: FieldCreated}},
+		LastID:  7,
+		Lists:   []List{{Name: "work"}},
+		Users:   []User{{ID: "alice", Admin: true}},
+	}
+	if err := SaveSnapshot(ctx, tmpFile, snap); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	if err := SaveItems(ctx, tmpFile, []Item{{ID: 2, Description: "New"}}); err != nil {
+		t.Fatalf("SaveItems failed: %v", err)
+	}
+	loaded, err := LoadSnapshot(ctx, tmpFile)
+	if err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if len(loaded.Items) != 1 || loaded.Items[0].ID != 2 {
+		t.Errorf("expected only the new item, got %+v", loaded.Items)
+	}
+	if len(loaded.History) != 1 || loaded.LastID != 7 || len(loaded.Lists) != 1 || len(loaded.Users) != 1 {
+		t.Errorf("expected history, ID counter, lists and users to be kept, got %+v", loaded)
+	}
+}
+
 func TestLoadItems_InvalidJSON(t *testing.T) {
 	ctx := context.WithValue(context.Background(), TraceIDKey, "test-trace-id")
 	tmpFile := filepath.Join(t.TempDir(), "bad.json")
@@ -62,3 +91,29 @@ func TestLoadItems_InvalidJSON(t *testing.T) {
 		t.Fatal("expected error for invalid JSON, got nil")
 	}
 }
+
+func TestJSONFileStorage_PutAndDelete(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "test-trace-id")
+	s := NewJSONFileStorage(filepath.Join(t.TempDir(), "todos.json"))
+
+	if err := s.Put(ctx, Item{ID: 1, Description: "First"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Put(ctx, Item{ID: 2, Description: "Second"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Put(ctx, Item{ID: 1, Description: "First (edited)"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Delete(ctx, 2); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	loaded, err := s.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Items) != 1 || loaded.Items[0].ID != 1 || loaded.Items[0].Description != "First (edited)" {
+		t.Errorf("unexpected items: %+v", loaded)
+	}
+}
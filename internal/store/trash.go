@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultTrashRetention is how long the CLI and server keep trashed items
+// before purging them.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// purgeCheckInterval caps how often a running actor looks for trashed items
+// past their retention.
+const purgeCheckInterval = time.Hour
+
+// WithTrashRetention makes the actor purge trashed items for good once they
+// have been in the trash for “d”: once when it starts and then periodically
+// until Close. Without it, or with d <= 0, the trash is never emptied.
+func WithTrashRetention(d time.Duration) ActorOption {
+	return func(a *ToDoActor) {
+		a.retention = d
+	}
+}
+
+// liveItems returns the items that are not in the trash.
+func liveItems(items []Item) []Item {
+	out := make([]Item, 0, len(items))
+	for _, it := range items {
+		if it.DeletedAt.IsZero() {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// expiredItems returns the IDs of the items trashed before “before”.
+func expiredItems(items []Item, before time.Time) []int {
+	var ids []int
+	for _, it := range items {
+		if !it.DeletedAt.IsZero() && it.DeletedAt.Before(before) {
+			ids = append(ids, it.ID)
+		}
+	}
+	return ids
+}
+
+// removeItems deletes “ids” for good and drops them from the blockers of
+// the remaining items, returning the new list and the resulting writes.
+func removeItems(items []Item, ids []int, now time.Time) ([]Item, []JournalEntry) {
+	var entries []JournalEntry
+	for _, id := range ids {
+		items = removeItem(items, id)
+		entries = append(entries, deleteEntry(id))
+	}
+	for j := range items {
+		before := len(items[j].BlockedBy)
+		for _, id := range ids {
+			items[j].BlockedBy = dropBlocker(items[j].BlockedBy, id)
+		}
+		if len(items[j].BlockedBy) != before {
+			touch(&items[j], now)
+			entries = append(entries, putEntry(items[j]))
+		}
+	}
+	return items, entries
+}
+
+// purgeLoop empties expired items from the trash until the actor is closed.
+func (a *ToDoActor) purgeLoop() {
+	ticker := time.NewTicker(min(a.retention, purgeCheckInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.closed:
+			return
+		case <-ticker.C:
+			ctx := context.WithValue(context.Background(), IdentityKey, SystemIdentity)
+			a.PurgeTrash(ctx, time.Now().Add(-a.retention))
+		}
+	}
+}
+
+// purgeTrash deletes the items trashed before a time for good.
+func (s *actorState) purgeTrash(m purgeMsg) {
+	m.reply <- s.purge(m.by, m.before)
+}
+
+// purge deletes the items trashed before “before” for good. Purges are
+// housekeeping, not something to undo, so they bypass the undo log.
+func (s *actorState) purge(by origin, before time.Time) []int {
+	ids := expiredItems(s.items, before)
+	if len(ids) > 0 {
+		prior := cloneItems(s.items)
+		var entries []JournalEntry
+		now := time.Now()
+		s.items, entries = removeItems(s.items, ids, now)
+		s.a.changed(s.record(by, prior, entries, now)...)
+	}
+	return ids
+}
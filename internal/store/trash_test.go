@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestToDoActor_DeleteMovesToTrash(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor([]Item{{ID: 1, Description: "Parent"}, {ID: 2, Description: "Child", ParentID: 1}, {ID: 3}},
+		WithDeletePolicy(DeleteCascade))
+
+	ids, err := actor.RemoveItem(ctx, 1)
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("expected parent and child trashed, got %v, %v", ids, err)
+	}
+	if items := actor.GetItems(); len(items) != 1 || items[0].ID != 3 {
+		t.Errorf("expected GetItems to hide trashed items, got %+v", items)
+	}
+	if tree := actor.Tree(); len(tree) != 1 {
+		t.Errorf("expected Tree to hide trashed items, got %+v", tree)
+	}
+	if _, ok := actor.GetItem(1); ok {
+		t.Error("expected GetItem to hide a trashed item")
+	}
+	all := actor.GetAllItems()
+	if len(all) != 3 || all[0].DeletedAt.IsZero() || all[1].DeletedAt.IsZero() {
+		t.Errorf("expected GetAllItems to include trashed items, got %+v", all)
+	}
+	desc := "Edited"
+	if _, err := actor.PatchItem(ctx, 1, ItemPatch{Description: &desc}); !errors.Is(err, ErrTrashed) {
+		t.Errorf("expected ErrTrashed when editing a trashed item, got %v", err)
+	}
+	if _, err := actor.RemoveItem(ctx, 1); !errors.Is(err, ErrTrashed) {
+		t.Errorf("expected ErrTrashed when deleting twice, got %v", err)
+	}
+}
+
+func TestToDoActor_RestoreItem(t *testing.T) {
+	ctx := context.Background()
+	actor := NewToDoActor([]Item{{ID: 1}, {ID: 2, ParentID: 1}, {ID: 3, ParentID: 1}}, WithDeletePolicy(DeleteCascade))
+
+	actor.RemoveItem(ctx, 3) // trashed on its own first
+	actor.RemoveItem(ctx, 1)
+	if _, err := actor.RestoreItem(ctx, 2); !errors.Is(err, ErrParentTrashed) {
+		t.Errorf("expected ErrParentTrashed, got %v", err)
+	}
+	ids, err := actor.RestoreItem(ctx, 1)
+	if err != nil || len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("expected 1 and 2 restored but not 3, got %v, %v", ids, err)
+	}
+	if _, err := actor.RestoreItem(ctx, 1); !errors.Is(err, ErrNotTrashed) {
+		t.Errorf("expected ErrNotTrashed, got %v", err)
+	}
+	if _, err := actor.RestoreItem(ctx, 42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if n := len(actor.GetItems()); n != 2 {
+		t.Errorf("expected 2 live items, got %d", n)
+	}
+}
+
+func TestToDoActor_PurgesExpiredTrash(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	var changes []JournalEntry
+	actor := NewToDoActor([]Item{
+		{ID: 1, DeletedAt: old},
+		{ID: 2, DeletedAt: time.Now()},
+		{ID: 3, BlockedBy: []int{1}},
+	}, WithTrashRetention(24*time.Hour), WithChangeListener(func(e JournalEntry) { changes = append(changes, e) }))
+	defer actor.Close(context.Background())
+
+	all := actor.GetAllItems()
+	if len(all) != 2 || all[0].ID != 2 {
+		t.Fatalf("expected only the expired item to be purged on start, got %+v", all)
+	}
+	if len(all[1].BlockedBy) != 0 {
+		t.Errorf("expected the purged blocker to be dropped, got %+v", all[1])
+	}
+	if changes = itemWrites(changes); len(changes) != 2 || changes[0].Op != JournalDelete || changes[0].ID != 1 {
+		t.Errorf("expected the purge to be reported, got %+v", changes)
+	}
+	if ids := actor.PurgeTrash(context.Background(), time.Now().Add(time.Second)); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("expected PurgeTrash to remove item 2, got %v", ids)
+	}
+}
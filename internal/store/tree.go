@@ -0,0 +1,132 @@
+package store
+
+// Progress is the rollup of an item's subtasks, counting every descendant.
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// ItemView is an Item together with values the actor derives from the rest
+// of the list. It marshals to the same JSON as Item plus the derived fields.
+type ItemView struct {
+	Item
+	Depth    int       `json:"depth,omitempty"`    // 0 for top-level items
+	Progress *Progress `json:"progress,omitempty"` // nil for items without subtasks
+}
+
+// DeletePolicy says what deleting an item with subtasks does.
+type DeletePolicy int
+
+const (
+	// DeleteRefuse rejects the delete with ErrHasSubtasks.
+	DeleteRefuse DeletePolicy = iota
+	// DeleteCascade deletes the subtasks as well.
+	DeleteCascade
+)
+
+// ParseDeletePolicy accepts "refuse" or "cascade".
+func ParseDeletePolicy(s string) (DeletePolicy, error) {
+	switch s {
+	case "refuse":
+		return DeleteRefuse, nil
+	case "cascade":
+		return DeleteCascade, nil
+	}
+	return 0, &ValidationError{Field: "delete policy", Message: "expected refuse or cascade"}
+}
+
+// WithDeletePolicy sets what DeleteItem does with subtasks. The default is DeleteRefuse.
+func WithDeletePolicy(p DeletePolicy) ActorOption {
+	return func(a *ToDoActor) {
+		a.deletePolicy = p
+	}
+}
+
+// buildTree orders “items” depth-first, parents before their subtasks and
+// siblings in list order, and computes each item's depth and rollup, in
+// which items in a done status of “w” count as done. Items whose parent is
+// missing are shown at the top level.
+func buildTree(items []Item, w *Workflow) []ItemView {
+	index := make(map[int]int, len(items))
+	for i, it := range items {
+		index[it.ID] = i
+	}
+	children := make(map[int][]int)
+	var roots []int
+	for i, it := range items {
+		if _, ok := index[it.ParentID]; ok && it.ParentID != it.ID {
+			children[it.ParentID] = append(children[it.ParentID], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	out := make([]ItemView, 0, len(items))
+	visited := make(map[int]bool, len(items))
+	var walk func(i, depth int) Progress
+	walk = func(i, depth int) Progress {
+		visited[i] = true
+		pos := len(out)
+		out = append(out, ItemView{Item: items[i], Depth: depth})
+		var p Progress
+		for _, c := range children[items[i].ID] {
+			if visited[c] {
+				continue // a cycle in a hand-edited file; show each item once
+			}
+			sub := walk(c, depth+1)
+			p.Total += 1 + sub.Total
+			p.Done += sub.Done
+			if w.isDone(items[c].Status) {
+				p.Done++
+			}
+		}
+		if p.Total > 0 {
+			out[pos].Progress = &p
+		}
+		return p
+	}
+	for _, r := range roots {
+		walk(r, 0)
+	}
+	// Anything not reached sits on a parent cycle; list it rather than lose it.
+	for i := range items {
+		if !visited[i] {
+			walk(i, 0)
+		}
+	}
+	return out
+}
+
+// isAncestor reports whether “ancestor” is “id” or one of its parents.
+func isAncestor(items []Item, ancestor, id int) bool {
+	parent := make(map[int]int, len(items))
+	for _, it := range items {
+		parent[it.ID] = it.ParentID
+	}
+	for steps := 0; id != 0 && steps <= len(items); steps++ {
+		if id == ancestor {
+			return true
+		}
+		id = parent[id]
+	}
+	return false
+}
+
+// descendants returns the IDs of every subtask below “id”, nearest first.
+func descendants(items []Item, id int) []int {
+	var out []int
+	queue := []int{id}
+	seen := map[int]bool{id: true}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, it := range items {
+			if it.ParentID == cur && !seen[it.ID] {
+				seen[it.ID] = true
+				out = append(out, it.ID)
+				queue = append(queue, it.ID)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"testing"
+)
+
+func TestBuildTree_OrderDepthAndRollup(t *testing.T) {
+	items := []Item{
+		{ID: 1, Description: "release"},
+		{ID: 2, Description: "other"},
+		{ID: 3, ParentID: 1, Status: StatusCompleted},
+		{ID: 4, ParentID: 1},
+		{ID: 5, ParentID: 4, Status: StatusCompleted},
+		{ID: 6, ParentID: 99}, // orphan
+	}
+	tree := buildTree(items, DefaultWorkflow())
+
+	var order []int
+	for _, v := range tree {
+		order = append(order, v.ID)
+	}
+	want := []int{1, 3, 4, 5, 2, 6}
+	if len(order) != len(want) {
+		t.Fatalf("expected order %v, got %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, order)
+		}
+	}
+
+	if p := tree[0].Progress; p == nil || p.Done != 2 || p.Total != 3 {
+		t.Errorf("unexpected rollup for 1: %+v", p)
+	}
+	if p := tree[2].Progress; p == nil || p.Done != 1 || p.Total != 1 {
+		t.Errorf("unexpected rollup for 4: %+v", p)
+	}
+	if tree[3].Depth != 2 || tree[5].Depth != 0 || tree[4].Progress != nil {
+		t.Errorf("unexpected views: %+v", tree)
+	}
+}
+
+func TestBuildTree_SurvivesCycles(t *testing.T) {
+	tree := buildTree([]Item{{ID: 1, ParentID: 2}, {ID: 2, ParentID: 1}}, DefaultWorkflow())
+	if len(tree) != 2 {
+		t.Errorf("expected both items to be listed, got %+v", tree)
+	}
+}
+
+func TestIsAncestor(t *testing.T) {
+	items := []Item{{ID: 1}, {ID: 2, ParentID: 1}, {ID: 3, ParentID: 2}}
+	if !isAncestor(items, 1, 3) || !isAncestor(items, 3, 3) {
+		t.Error("expected 1 and 3 to be ancestors of 3")
+	}
+	if isAncestor(items, 3, 1) {
+		t.Error("3 is not an ancestor of 1")
+	}
+}
@@ -6,16 +6,92 @@ type ctxKey string
 
 const TraceIDKey ctxKey = "traceID"
 
+// IdentityKey is the context key for who is making a request, as a string
+// such as "cli:alice". The actor records it in the history.
+const IdentityKey ctxKey = "identity"
+
+// UserKey is the context key for the ID of the account a request acts as,
+// a string, "" for none. Unlike IdentityKey it decides what a request may
+// do, so it is only set from something checked: IdentityMiddleware sets it
+// to "" and AuthMiddleware to the user of the principal. Without it, as for
+// the CLI, the request acts as the user its IdentityKey names.
+const UserKey ctxKey = "user"
+
+// SystemIdentity is recorded for changes the store makes on its own, such as
+// purging the trash.
+const SystemIdentity = "system"
+
 const (
 	StatusNotStarted = "not started"
 	StatusStarted    = "started"
 	StatusCompleted  = "completed"
 )
 
+const (
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
 // Item represents a single to-do entry.
 type Item struct {
-	ID          int       `json:"id"`          // unique integer ID
-	Description string    `json:"description"` // the task text
-	CreatedAt   time.Time `json:"created_at"`  // timestamp when added
-	Status      string    `json:"status"`      // status of the item
+	ID          int         `json:"id"`                    // unique integer ID, never reused
+	UUID        string      `json:"uuid,omitempty"`        // globally unique ID for sync and external references
+	Description string      `json:"description"`           // the task text
+	List        string      `json:"list,omitempty"`        // name of the List it belongs to
+	CreatedAt   time.Time   `json:"created_at"`            // timestamp when added
+	Status      string      `json:"status"`                // one of the actor's Workflow statuses
+	Priority    string      `json:"priority,omitempty"`    // one of the Priority constants, empty if unset
+	DueAt       time.Time   `json:"due_at,omitzero"`       // deadline, zero if unset
+	Tags        []string    `json:"tags,omitempty"`        // normalized, sorted and unique
+	ParentID    int         `json:"parent_id,omitempty"`   // ID of the parent item, 0 for top-level items
+	BlockedBy   []int       `json:"blocked_by,omitempty"`  // IDs of items that must be completed first
+	Recurrence  *Recurrence `json:"recurrence,omitempty"`  // rule for the next occurrence, nil for one-off items
+	PreviousID  int         `json:"previous_id,omitempty"` // the completed occurrence this one replaced
+	StartedAt   time.Time   `json:"started_at,omitzero"`   // when it first became active since it was last reset to todo
+	CompletedAt time.Time   `json:"completed_at,omitzero"` // when it was finished, zero while unfinished
+	UpdatedAt   time.Time   `json:"updated_at,omitzero"`   // last change of any kind
+	Version     int         `json:"version,omitempty"`     // raised by every change; the item's ETag
+	DeletedAt   time.Time   `json:"deleted_at,omitzero"`   // when it was moved to the trash, zero if it is not there
+	CreatedBy   string      `json:"created_by,omitempty"`  // ID of the user who created it, its owner; "" if unknown
+	AssigneeID  string      `json:"assignee_id,omitempty"` // ID of the User it is assigned to, "" if unassigned
+}
+
+// ItemInput holds the caller-supplied fields of a new item.
+type ItemInput struct {
+	Description string
+	List        string // name of an existing list; defaults to the parent's, or DefaultList
+	Priority    string
+	DueAt       time.Time
+	Tags        []string
+	ParentID    int // create as a subtask of this item; 0 for top level
+	Recurrence  *Recurrence
+	AssigneeID  string // ID of an existing User, "" for nobody
+}
+
+// ItemPatch describes changes to an existing item. Nil fields are left
+// unchanged; a pointer to the zero value clears the field.
+type ItemPatch struct {
+	Description *string
+	Status      *string
+	Priority    *string
+	DueAt       *time.Time
+	Tags        *[]string
+	Recurrence  *Recurrence
+	AssigneeID  *string // "" unassigns
+	// Reopen moves a finished item back to an unfinished status: Status if
+	// set, otherwise the workflow's initial status.
+	Reopen bool
+	// Force skips the check that an item's blockers are finished before it
+	// moves to started or completed.
+	Force bool
+	// IfVersion, if not 0, makes the patch fail with ErrVersionMismatch
+	// unless the item is still at that Version.
+	IfVersion int
+}
+
+// Empty reports whether the patch changes nothing.
+func (p ItemPatch) Empty() bool {
+	return p == ItemPatch{}
 }
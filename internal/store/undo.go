@@ -0,0 +1,246 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UndoLimit is how many mutations can be undone; older steps are forgotten.
+const UndoLimit = 100
+
+// UndoStep is one undoable mutation: the state of every item it wrote,
+// before and after. Undoing puts the Before states back, redoing the After
+// states; either is refused with ErrUndoConflict unless the items are still
+// exactly as the step left them, so an undo never overwrites a later change
+// or brings back an ID that has since been given to another item.
+type UndoStep struct {
+	At      time.Time   `json:"at"`
+	TraceID string      `json:"trace_id,omitempty"`
+	Actor   string      `json:"actor,omitempty"`
+	Items   []UndoState `json:"items"`
+}
+
+// UndoState is an item before and after an UndoStep.
+type UndoState struct {
+	ID     int   `json:"id"`
+	Before *Item `json:"before,omitempty"` // nil if the step created the item
+	After  *Item `json:"after,omitempty"`  // nil if the step removed the item
+}
+
+// Summary describes the step for people, e.g. "add [3] Buy milk".
+func (s UndoStep) Summary() string {
+	parts := make([]string, len(s.Items))
+	for i, st := range s.Items {
+		var verb string
+		var it *Item
+		switch {
+		case st.Before == nil:
+			verb, it = "add", st.After
+		case st.After == nil:
+			verb, it = "purge", st.Before
+		case st.Before.DeletedAt.IsZero() && !st.After.DeletedAt.IsZero():
+			verb, it = "delete", st.After
+		case !st.Before.DeletedAt.IsZero() && st.After.DeletedAt.IsZero():
+			verb, it = "restore", st.After
+		default:
+			verb, it = "update", st.After
+		}
+		parts[i] = fmt.Sprintf("%s [%d] %s", verb, st.ID, it.Description)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WithUndoLog seeds the actor with the undo and redo stacks loaded from
+// storage, oldest step first.
+func WithUndoLog(undo, redo []UndoStep) ActorOption {
+	return func(a *ToDoActor) {
+		a.undo, a.redo = undo, redo
+	}
+}
+
+// newUndoStep returns the step for a mutation that wrote “entries”, where
+// “before” holds the previous version of every written item that existed.
+func newUndoStep(by origin, before []Item, entries []JournalEntry, at time.Time) UndoStep {
+	prior := make(map[int]Item, len(before))
+	for _, it := range before {
+		prior[it.ID] = it
+	}
+	step := UndoStep{At: at, TraceID: by.traceID, Actor: by.actor}
+	for _, e := range entries {
+		var st UndoState
+		switch e.Op {
+		case JournalPut:
+			after := *e.Item
+			st = UndoState{ID: after.ID, After: &after}
+		case JournalDelete:
+			st = UndoState{ID: e.ID}
+		default:
+			continue
+		}
+		if old, ok := prior[st.ID]; ok {
+			st.Before = &old
+		}
+		step.Items = append(step.Items, st)
+	}
+	return step
+}
+
+// checkUndo reports ErrUndoConflict unless every item in “states” is
+// currently exactly in its “is” state (After for undo, Before for redo),
+// going by its Version.
+func checkUndo(items []Item, states []UndoState, is func(*UndoState) **Item) error {
+	for _, st := range states {
+		var cur *Item
+		for i := range items {
+			if items[i].ID == st.ID {
+				cur = &items[i]
+				break
+			}
+		}
+		expected := *is(&st)
+		switch {
+		case expected == nil && cur != nil:
+			return fmt.Errorf("%w: item %d exists again", ErrUndoConflict, st.ID)
+		case expected != nil && cur == nil:
+			return fmt.Errorf("%w: item %d no longer exists", ErrUndoConflict, st.ID)
+		case expected != nil && cur.Version != expected.Version:
+			return fmt.Errorf("%w: item %d was changed since", ErrUndoConflict, st.ID)
+		}
+	}
+	return nil
+}
+
+// applyUndo writes the “want” state of every item in “states” at “now”,
+// returning the new list, a copy of “states” whose “want” side is what was
+// written, for checkUndo to expect when the step is taken back, and the
+// resulting writes.
+func applyUndo(items []Item, states []UndoState, want func(*UndoState) **Item, now time.Time) ([]Item, []UndoState, []JournalEntry) {
+	var entries []JournalEntry
+	states = slices.Clone(states)
+	for i := range states {
+		st := &states[i]
+		if it := *want(st); it != nil {
+			// Going back to an old state is still a change, so the
+			// version keeps rising and old ETags stay stale.
+			restored := *it
+			restored.Version = max(restored.Version, versionOf(items, st.ID))
+			touch(&restored, now)
+			items = putItem(items, restored)
+			entries = append(entries, putEntry(restored))
+			*want(st) = &restored
+		} else {
+			items = removeItem(items, st.ID)
+			entries = append(entries, deleteEntry(st.ID))
+		}
+	}
+	return items, states, entries
+}
+
+// moveStep pops the last step of “from” onto “to” as “done”, the same step
+// with its “want” side as applyUndo wrote it. An earlier step left in
+// “from” that expects to find one of those items as the popped step had
+// it, going by Version, now expects it as written, so the steps can still
+// be taken back one after another.
+func moveStep(from, to []UndoStep, done UndoStep, want, was func(*UndoState) **Item) ([]UndoStep, []UndoStep) {
+	n := len(from)
+	orig := from[n-1]
+	from = from[:n-1]
+	for i := range done.Items {
+		written, old := *want(&done.Items[i]), *want(&orig.Items[i])
+		if written == nil || old == nil {
+			continue
+		}
+	search:
+		for j := len(from) - 1; j >= 0; j-- {
+			for k := range from[j].Items {
+				if from[j].Items[k].ID != written.ID {
+					continue
+				}
+				if p := was(&from[j].Items[k]); *p != nil && (*p).Version == old.Version {
+					from[j].Items = slices.Clone(from[j].Items)
+					it := *written
+					*was(&from[j].Items[k]) = &it
+				}
+				break search
+			}
+		}
+	}
+	return from, append(to, done)
+}
+
+// versionOf returns the Version of item “id”, or 0 if there is none.
+func versionOf(items []Item, id int) int {
+	for _, it := range items {
+		if it.ID == id {
+			return it.Version
+		}
+	}
+	return 0
+}
+
+func stateBefore(st *UndoState) **Item { return &st.Before }
+func stateAfter(st *UndoState) **Item  { return &st.After }
+
+// trimSteps drops the oldest steps beyond UndoLimit.
+func trimSteps(steps []UndoStep) []UndoStep {
+	if len(steps) > UndoLimit {
+		return append([]UndoStep(nil), steps[len(steps)-UndoLimit:]...)
+	}
+	return steps
+}
+
+// undoSteps undoes or redoes steps of the undo log, one at a time.
+func (s *actorState) undoSteps(m undoMsg) {
+	// Undo pops from the undo stack onto the redo stack and puts the
+	// items back as they were before; redo goes the other way.
+	from, to, want, was, op, empty := &s.undo, &s.redo, stateBefore, stateAfter, JournalUndo, ErrNothingToUndo
+	if m.redo {
+		from, to, want, was, op, empty = &s.redo, &s.undo, stateAfter, stateBefore, JournalRedo, ErrNothingToRedo
+	}
+	var r undoReply
+	for len(r.steps) < m.steps {
+		n := len(*from)
+		if n == 0 {
+			if len(r.steps) == 0 {
+				r.err = empty
+			}
+			break
+		}
+		step := (*from)[n-1]
+		if r.err = checkUndo(s.items, step.Items, was); r.err != nil {
+			break
+		}
+		// Taking an item away is a delete, so it needs the same
+		// permission as one.
+		for _, st := range step.Items {
+			it, old := *want(&st), *was(&st)
+			switch {
+			case it != nil && !s.hasList(it.List):
+				r.err = fmt.Errorf("%w: list %q was deleted", ErrUndoConflict, it.List)
+			case it == nil && old != nil:
+				if err := mayDelete(s.users, m.by, *old); err != nil {
+					r.err = err
+				}
+			}
+		}
+		if r.err != nil {
+			break
+		}
+		var prior []Item
+		for _, st := range step.Items {
+			if it := *was(&st); it != nil {
+				prior = append(prior, *it)
+			}
+		}
+		now := time.Now()
+		var entries []JournalEntry
+		s.items, step.Items, entries = applyUndo(s.items, step.Items, want, now)
+		*from, *to = moveStep(*from, *to, step, want, was)
+		entries = s.record(m.by, prior, entries, now)
+		s.a.changed(append(entries, JournalEntry{Op: op, Step: &step})...)
+		r.steps = append(r.steps, step)
+	}
+	m.reply <- r
+}
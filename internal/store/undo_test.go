@@ -0,0 +1,174 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestToDoActor_UndoRedo(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "Draft"})
+	desc := "Final"
+	actor.PatchItem(ctx, item.ID, ItemPatch{Description: &desc})
+	actor.RemoveItem(ctx, item.ID)
+
+	done, err := actor.Undo(ctx, 2)
+	if err != nil || len(done) != 2 {
+		t.Fatalf("expected two steps undone, got %d: %v", len(done), err)
+	}
+	if got := done[0].Summary(); got != "delete [1] Final" {
+		t.Errorf("expected the delete to be undone first, got %q", got)
+	}
+	if got, ok := actor.GetItem(item.ID); !ok || got.Description != "Draft" {
+		t.Fatalf("expected the item back as a draft, got %+v", got)
+	}
+
+	if _, err := actor.Redo(ctx, 1); err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if got, _ := actor.GetItem(item.ID); got.Description != "Final" {
+		t.Errorf("expected the update to be redone, got %+v", got)
+	}
+
+	// A new change makes the remaining undone step impossible to redo.
+	actor.CreateItem(ctx, ItemInput{Description: "Other"})
+	if _, err := actor.Redo(ctx, 1); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("expected ErrNothingToRedo, got %v", err)
+	}
+
+	if done, err := actor.Undo(ctx, 10); err != nil || len(done) != 3 {
+		t.Fatalf("expected every remaining step undone, got %d: %v", len(done), err)
+	}
+	if items := actor.GetAllItems(); len(items) != 0 {
+		t.Errorf("expected undoing every add to leave nothing, got %+v", items)
+	}
+	if _, err := actor.Undo(ctx, 1); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("expected ErrNothingToUndo, got %v", err)
+	}
+}
+
+func TestToDoActor_UndoSpawnedOccurrence(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "Water plants", Recurrence: &Recurrence{Kind: RepeatDaily, Interval: 1}})
+	done := StatusCompleted
+	actor.PatchItem(ctx, item.ID, ItemPatch{Status: &done})
+
+	if _, err := actor.Undo(ctx, 1); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	items := actor.GetItems()
+	if len(items) != 1 || items[0].Status != StatusNotStarted || !items[0].CompletedAt.IsZero() {
+		t.Errorf("expected completing and the next occurrence to be undone together, got %+v", items)
+	}
+}
+
+func TestToDoActor_UndoConflicts(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "Task"})
+	actor.RemoveItem(ctx, item.ID)
+	actor.PurgeTrash(ctx, time.Now().Add(time.Hour))
+
+	// The purge is not undoable, and the trashed item it removed is gone.
+	if _, err := actor.Undo(ctx, 1); !errors.Is(err, ErrUndoConflict) {
+		t.Errorf("expected ErrUndoConflict after a purge, got %v", err)
+	}
+
+	// After a restart, an ID from the log may belong to another item.
+	old := Item{ID: 1, Description: "Old", UpdatedAt: time.Now().Add(-time.Hour)}
+	reused := Item{ID: 1, Description: "Reused", UpdatedAt: time.Now()}
+	step := UndoStep{Items: []UndoState{{ID: 1, Before: &old}}}
+	actor = NewToDoActor([]Item{reused}, WithUndoLog([]UndoStep{step}, nil))
+	if _, err := actor.Undo(ctx, 1); !errors.Is(err, ErrUndoConflict) {
+		t.Errorf("expected ErrUndoConflict for a reused ID, got %v", err)
+	}
+	if got, _ := actor.GetItem(1); got.Description != "Reused" {
+		t.Errorf("expected the reused item to be left alone, got %+v", got)
+	}
+}
+
+func TestToDoActor_UndoGoesByVersion(t *testing.T) {
+	ctx := testCtx()
+	path := filepath.Join(t.TempDir(), "todos.json")
+	s := NewJSONFileStorage(path)
+	s.Journal = true
+
+	var changes []JournalEntry
+	actor := NewToDoActor(nil, WithChangeListener(func(e JournalEntry) { changes = append(changes, e) }))
+	item, _ := actor.CreateItem(ctx, ItemInput{Description: "A"})
+	for _, desc := range []string{"B", "C"} {
+		actor.PatchItem(ctx, item.ID, ItemPatch{Description: &desc})
+	}
+	if _, err := actor.Undo(ctx, 2); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	got, _ := actor.GetItem(item.ID)
+	if got.Description != "A" || got.Version != 5 || !got.UpdatedAt.After(item.UpdatedAt) {
+		t.Errorf("expected A back at version 5 and stamped now, got %+v", got)
+	}
+
+	// The undone steps can be redone after the journal is replayed.
+	if err := s.Apply(ctx, changes...); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	snap, err := s.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	reloaded := NewToDoActor(snap.Items, WithUndoLog(snap.Undo, snap.Redo))
+	if _, err := reloaded.Redo(ctx, 2); err != nil {
+		t.Fatalf("Redo after reload failed: %v", err)
+	}
+	if got, _ := reloaded.GetItem(item.ID); got.Description != "C" || got.Version != 7 {
+		t.Errorf("expected C back at version 7, got %+v", got)
+	}
+
+	// Another write in the same clock tick is still a conflict.
+	at := time.Now()
+	after := Item{ID: 1, Description: "Mine", Version: 2, UpdatedAt: at}
+	later := Item{ID: 1, Description: "Theirs", Version: 3, UpdatedAt: at}
+	actor = NewToDoActor([]Item{later}, WithUndoLog([]UndoStep{{Items: []UndoState{{ID: 1, After: &after}}}}, nil))
+	if _, err := actor.Undo(ctx, 1); !errors.Is(err, ErrUndoConflict) {
+		t.Errorf("expected ErrUndoConflict for a newer version, got %v", err)
+	}
+}
+
+func TestSnapshot_UndoLogRoundTrip(t *testing.T) {
+	ctx := testCtx()
+	path := filepath.Join(t.TempDir(), "todos.json")
+	s := NewJSONFileStorage(path)
+	s.Journal = true
+
+	var changes []JournalEntry
+	actor := NewToDoActor(nil, WithChangeListener(func(e JournalEntry) { changes = append(changes, e) }))
+	actor.CreateItem(ctx, ItemInput{Description: "One"})
+	actor.CreateItem(ctx, ItemInput{Description: "Two"})
+	actor.Undo(ctx, 1)
+	if err := s.Apply(ctx, changes...); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	snap, err := s.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(snap.Undo) != 1 || len(snap.Redo) != 1 {
+		t.Fatalf("expected one step to undo and one to redo, got %d and %d", len(snap.Undo), len(snap.Redo))
+	}
+	if err := s.Save(ctx, snap); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	snap, _ = s.Load(ctx)
+	reloaded := NewToDoActor(snap.Items, WithUndoLog(snap.Undo, snap.Redo))
+	if _, err := reloaded.Redo(ctx, 1); err != nil {
+		t.Fatalf("Redo after reload failed: %v", err)
+	}
+	if items := reloaded.GetItems(); len(items) != 2 {
+		t.Errorf("expected the redone item after a reload, got %+v", items)
+	}
+}
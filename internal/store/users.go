@@ -0,0 +1,236 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// User is an account of someone who shares the store. Items record the
+// user who created them, their owner, and may be assigned to one.
+//
+// A request acts as the account of the subject of its token or the name of
+// its API key (see AuthMiddleware), or, from the CLI, of the login running
+// it. The X-Actor header proves nothing, so it is only recorded in the
+// history; without -auth-file requests to the server act as nobody.
+type User struct {
+	ID        string    `json:"id"`             // login, such as "alice"
+	Name      string    `json:"name,omitempty"` // display name
+	Admin     bool      `json:"admin,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// Unassigned is the Query.Assignee that selects items assigned to nobody.
+// It cannot be used as a user ID.
+const Unassigned = "none"
+
+// DisplayName is the user's name, or ID if it has none.
+func (u User) DisplayName() string {
+	if u.Name != "" {
+		return u.Name
+	}
+	return u.ID
+}
+
+// WithUsers seeds the actor with the accounts loaded from storage.
+func WithUsers(users []User) ActorOption {
+	return func(a *ToDoActor) {
+		a.users = users
+	}
+}
+
+var userIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// normalizeUserID lower-cases and trims “id” and checks it is a valid login.
+func normalizeUserID(id string) (string, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if !userIDPattern.MatchString(id) || id == Unassigned || id == SystemIdentity {
+		return "", &ValidationError{Field: "id", Message: fmt.Sprintf("%q is not a valid user ID: use up to 64 lower-case letters, digits, '.', '-' or '_'", id)}
+	}
+	return id, nil
+}
+
+// userOf returns the user ID an identity stands for: the login of a CLI
+// identity such as "cli:alice", or the identity itself. Anonymous requests
+// and the store itself are no user.
+func userOf(identity string) string {
+	switch {
+	case identity == SystemIdentity, strings.HasPrefix(identity, "anonymous@"):
+		return ""
+	case strings.HasPrefix(identity, "cli:"):
+		return strings.ToLower(strings.TrimPrefix(identity, "cli:"))
+	}
+	return strings.ToLower(identity)
+}
+
+// UserFromContext returns the ID of the user a request acts as, "" if it
+// is anonymous; see UserKey. The store itself is SystemIdentity.
+func UserFromContext(ctx context.Context) string {
+	if user, ok := ctx.Value(UserKey).(string); ok {
+		return user
+	}
+	identity, _ := ctx.Value(IdentityKey).(string)
+	if identity == SystemIdentity {
+		return SystemIdentity
+	}
+	return userOf(identity)
+}
+
+func findUser(users []User, id string) (User, bool) {
+	i := slices.IndexFunc(users, func(u User) bool { return u.ID == id })
+	if i < 0 {
+		return User{}, false
+	}
+	return users[i], true
+}
+
+func putUser(users []User, u User) []User {
+	for i := range users {
+		if users[i].ID == u.ID {
+			users[i] = u
+			return users
+		}
+	}
+	return append(users, u)
+}
+
+func removeUser(users []User, id string) []User {
+	return slices.DeleteFunc(users, func(u User) bool { return u.ID == id })
+}
+
+// isAdmin reports whether “by” may manage the accounts: any user but an
+// anonymous one may create the first, then only admins.
+func isAdmin(users []User, by origin) bool {
+	if by.user == SystemIdentity {
+		return true
+	}
+	if len(users) == 0 {
+		return by.user != ""
+	}
+	u, ok := findUser(users, by.user)
+	return ok && u.Admin
+}
+
+// ownerOf returns who owns an item “by” creates: its user, or nobody for an
+// anonymous request or the store itself.
+func ownerOf(by origin) string {
+	if by.user == SystemIdentity {
+		return ""
+	}
+	return by.user
+}
+
+// mayDelete checks that “by” may move “it” to the trash. Until there are
+// accounts anyone may; then only the item's owner or an admin, though
+// anyone may delete an item nobody owns.
+func mayDelete(users []User, by origin, it Item) error {
+	if len(users) == 0 || it.CreatedBy == "" || it.CreatedBy == by.user || isAdmin(users, by) {
+		return nil
+	}
+	return fmt.Errorf("%w: item %d belongs to %s", ErrForbidden, it.ID, it.CreatedBy)
+}
+
+// checkAssignee checks that “id” names an account, or nobody.
+func checkAssignee(users []User, id string) error {
+	if _, ok := findUser(users, id); id != "" && !ok {
+		return &ValidationError{Field: "assignee_id", Message: fmt.Sprintf("there is no user %q", id)}
+	}
+	return nil
+}
+
+type usersMsg struct {
+	reply chan []User
+}
+type createUserMsg struct {
+	user  User
+	by    origin
+	reply chan userReply
+}
+type deleteUserMsg struct {
+	id    string
+	by    origin
+	reply chan error
+}
+type userReply struct {
+	user User
+	err  error
+}
+
+// Users returns the accounts, oldest first.
+func (a *ToDoActor) Users() []User {
+	reply := make(chan []User)
+	a.send(usersMsg{reply})
+	return <-reply
+}
+
+// CreateUser adds account “u”. The first account can be created by anyone
+// and is always an admin; after that only admins may add accounts.
+func (a *ToDoActor) CreateUser(ctx context.Context, u User) (User, error) {
+	id, err := normalizeUserID(u.ID)
+	if err != nil {
+		return User{}, err
+	}
+	u.ID, u.Name, u.CreatedAt = id, strings.TrimSpace(u.Name), time.Now()
+	reply := make(chan userReply)
+	a.send(createUserMsg{u, originOf(ctx), reply})
+	r := <-reply
+	return r.user, r.err
+}
+
+// DeleteUser removes account “id”; only admins may, and not the last admin.
+// Items keep the ID of their owner and assignee.
+func (a *ToDoActor) DeleteUser(ctx context.Context, id string) error {
+	reply := make(chan error)
+	a.send(deleteUserMsg{strings.ToLower(strings.TrimSpace(id)), originOf(ctx), reply})
+	return <-reply
+}
+
+// listUsers replies with a copy of the accounts.
+func (s *actorState) listUsers(m usersMsg) {
+	m.reply <- slices.Clone(s.users)
+}
+
+// createUser adds an account; the first one is an admin.
+func (s *actorState) createUser(m createUserMsg) {
+	switch _, taken := findUser(s.users, m.user.ID); {
+	case !isAdmin(s.users, m.by):
+		msg := "only admins may add users"
+		if len(s.users) == 0 {
+			msg = "an anonymous request cannot create the first account, an admin"
+		}
+		m.reply <- userReply{err: fmt.Errorf("%w: %s", ErrForbidden, msg)}
+		return
+	case taken:
+		m.reply <- userReply{err: ErrUserExists}
+		return
+	}
+	// Someone has to be able to manage the accounts.
+	if len(s.users) == 0 {
+		m.user.Admin = true
+	}
+	s.users = append(s.users, m.user)
+	s.a.changed(JournalEntry{Op: JournalUser, User: &m.user})
+	m.reply <- userReply{user: m.user}
+}
+
+// deleteUser removes an account, unless it is the last admin.
+func (s *actorState) deleteUser(m deleteUserMsg) {
+	u, ok := findUser(s.users, m.id)
+	switch {
+	case !isAdmin(s.users, m.by):
+		m.reply <- fmt.Errorf("%w: only admins may remove users", ErrForbidden)
+		return
+	case !ok:
+		m.reply <- ErrUserNotFound
+		return
+	case u.Admin && !slices.ContainsFunc(s.users, func(o User) bool { return o.Admin && o.ID != u.ID }):
+		m.reply <- ErrLastAdmin
+		return
+	}
+	s.users = removeUser(s.users, m.id)
+	s.a.changed(JournalEntry{Op: JournalUserDelete, User: &User{ID: m.id}})
+	m.reply <- nil
+}
@@ -0,0 +1,320 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// as returns a test context for requests made by “identity”.
+func as(identity string) context.Context {
+	return context.WithValue(testCtx(), IdentityKey, identity)
+}
+
+func TestToDoActor_Users(t *testing.T) {
+	actor := NewToDoActor(nil)
+
+	var ve *ValidationError
+	for _, id := range []string{"", "none", "Al ice", strings.Repeat("a", 65)} {
+		if _, err := actor.CreateUser(as("alice"), User{ID: id}); !errors.As(err, &ve) {
+			t.Errorf("expected %q to be an invalid ID, got %v", id, err)
+		}
+	}
+	alice, err := actor.CreateUser(as("alice"), User{ID: " Alice ", Name: "Alice"})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if alice.ID != "alice" || !alice.Admin || alice.CreatedAt.IsZero() {
+		t.Errorf("expected the first user to be an admin, got %+v", alice)
+	}
+	if _, err := actor.CreateUser(as("alice"), User{ID: "bob"}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if _, err := actor.CreateUser(as("alice"), User{ID: "bob"}); !errors.Is(err, ErrUserExists) {
+		t.Errorf("expected ErrUserExists, got %v", err)
+	}
+	if _, err := actor.CreateUser(as("bob"), User{ID: "carol"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected only admins to add users, got %v", err)
+	}
+	if err := actor.DeleteUser(as("bob"), "alice"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected only admins to remove users, got %v", err)
+	}
+	if err := actor.DeleteUser(as("alice"), "alice"); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("expected ErrLastAdmin, got %v", err)
+	}
+	if err := actor.DeleteUser(as("alice"), "carol"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+	if err := actor.DeleteUser(as("cli:alice"), "bob"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if users := actor.Users(); len(users) != 1 || users[0].ID != "alice" {
+		t.Errorf("expected only alice left, got %+v", users)
+	}
+}
+
+func TestToDoActor_ItemOwners(t *testing.T) {
+	actor := NewToDoActor(nil)
+	// Before there are accounts anyone may delete anything.
+	early, _ := actor.CreateItem(as("bob"), ItemInput{Description: "Early"})
+	if _, err := actor.RemoveItem(as("carol"), early.ID); err != nil {
+		t.Errorf("expected deletes to be unchecked without accounts, got %v", err)
+	}
+
+	actor.CreateUser(as("alice"), User{ID: "alice"})
+	actor.CreateUser(as("alice"), User{ID: "bob"})
+	actor.CreateUser(as("alice"), User{ID: "carol"})
+	var ve *ValidationError
+	if _, err := actor.CreateItem(as("bob"), ItemInput{Description: "Plan", AssigneeID: "dave"}); !errors.As(err, &ve) || ve.Field != "assignee_id" {
+		t.Errorf("expected an unknown assignee to be invalid, got %v", err)
+	}
+	item, err := actor.CreateItem(as("cli:bob"), ItemInput{Description: "Plan", AssigneeID: " Carol "})
+	if err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	if item.CreatedBy != "bob" || item.AssigneeID != "carol" {
+		t.Errorf("expected bob's item assigned to carol, got %+v", item)
+	}
+	unowned, _ := actor.CreateItem(testCtx(), ItemInput{Description: "Anyone's"})
+
+	if _, err := actor.RemoveItem(as("carol"), item.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected the assignee not to delete bob's item, got %v", err)
+	}
+	if _, err := actor.RemoveItem(as("carol"), unowned.ID); err != nil {
+		t.Errorf("expected an item nobody owns to be deletable, got %v", err)
+	}
+	if _, err := actor.RemoveItem(as("bob"), item.ID); err != nil {
+		t.Errorf("expected the owner to delete the item, got %v", err)
+	}
+	other, _ := actor.CreateItem(as("bob"), ItemInput{Description: "Other"})
+	if _, err := actor.RemoveItem(as("alice"), other.ID); err != nil {
+		t.Errorf("expected an admin to delete the item, got %v", err)
+	}
+
+	// Assignees change with a patch, and "" unassigns.
+	task, _ := actor.CreateItem(as("bob"), ItemInput{Description: "Task"})
+	assignee := "alice"
+	if task, err = actor.PatchItem(as("bob"), task.ID, ItemPatch{AssigneeID: &assignee}); err != nil || task.AssigneeID != "alice" {
+		t.Fatalf("expected the item assigned to alice, got %+v: %v", task, err)
+	}
+	assignee = ""
+	if task, _ = actor.PatchItem(as("bob"), task.ID, ItemPatch{AssigneeID: &assignee}); task.AssigneeID != "" {
+		t.Errorf("expected the item unassigned, got %+v", task)
+	}
+}
+
+func TestToDoActor_QueryUsers(t *testing.T) {
+	actor := NewToDoActor(nil)
+	actor.CreateUser(as("alice"), User{ID: "alice"})
+	actor.CreateUser(as("alice"), User{ID: "bob"})
+	actor.CreateItem(as("alice"), ItemInput{Description: "Alice's"})
+	actor.CreateItem(as("alice"), ItemInput{Description: "For bob", AssigneeID: "bob"})
+	actor.CreateItem(as("bob"), ItemInput{Description: "Bob's"})
+	actor.CreateItem(as("bob"), ItemInput{Description: "For alice", AssigneeID: "alice"})
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"created by", Query{CreatedBy: "bob"}, []string{"Bob's", "For alice"}},
+		{"assigned", Query{Assignee: "bob"}, []string{"For bob"}},
+		{"unassigned", Query{Assignee: Unassigned}, []string{"Alice's", "Bob's"}},
+		{"mine", Query{Mine: true, User: "alice"}, []string{"Alice's", "For bob", "For alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := actor.Query(tt.query)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			var got []string
+			for _, v := range page.Items {
+				got = append(got, v.Description)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+	var ve *ValidationError
+	if _, err := actor.Query(Query{Mine: true}); !errors.As(err, &ve) {
+		t.Errorf("expected mine without a user to be invalid, got %v", err)
+	}
+	q, err := ParseQuery(url.Values{"created_by": {"Bob"}, "assignee": {"none"}, "mine": {"true"}})
+	if err != nil || q.CreatedBy != "bob" || q.Assignee != Unassigned || !q.Mine {
+		t.Errorf("unexpected query %+v: %v", q, err)
+	}
+	if v := q.Values(); v.Get("created_by") != "bob" || v.Get("assignee") != Unassigned || v.Get("mine") != "true" {
+		t.Errorf("expected the filters back, got %v", v)
+	}
+}
+
+func TestSnapshot_UsersRoundTrip(t *testing.T) {
+	ctx := testCtx()
+	path := filepath.Join(t.TempDir(), "todos.json")
+	s := NewJSONFileStorage(path)
+	s.Journal = true
+
+	var changes []JournalEntry
+	actor := NewToDoActor(nil, WithChangeListener(func(e JournalEntry) { changes = append(changes, e) }))
+	actor.CreateUser(as("alice"), User{ID: "alice", Name: "Alice"})
+	actor.CreateUser(as("alice"), User{ID: "bob"})
+	actor.CreateItem(as("alice"), ItemInput{Description: "Plan", AssigneeID: "bob"})
+	actor.DeleteUser(as("alice"), "bob")
+	if err := s.Apply(ctx, changes...); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	snap, err := s.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(snap.Users) != 1 || snap.Users[0].Name != "Alice" || !snap.Users[0].Admin {
+		t.Fatalf("expected alice after replaying the journal, got %+v", snap.Users)
+	}
+	if err := s.Save(ctx, snap); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	snap, _ = s.Load(ctx)
+	reloaded := NewToDoActor(snap.Items, WithUsers(snap.Users))
+	if users := reloaded.Users(); len(users) != 1 || users[0].ID != "alice" {
+		t.Errorf("expected alice after a reload, got %+v", users)
+	}
+	// Items keep the IDs of removed accounts.
+	if items := reloaded.GetItems(); len(items) != 1 || items[0].CreatedBy != "alice" || items[0].AssigneeID != "bob" {
+		t.Errorf("expected the owner and assignee kept, got %+v", items)
+	}
+}
+
+// newUsersServer serves the API of “actor” behind an auth file with a
+// read-write API key for alice and bob; requests are made as one of them
+// with their key.
+func newUsersServer(t *testing.T, actor *ToDoActor) func(who, method, path, body string) *httptest.ResponseRecorder {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	c := &AuthConfig{APIKeys: []APIKey{{Name: "alice", Key: "alice-key", Scope: ScopeReadWrite}, {Name: "bob", Key: "bob-key", Scope: ScopeReadWrite}}}
+	c.Save(path)
+	auth, err := NewAuthenticator(path)
+	if err != nil {
+		t.Fatalf("NewAuthenticator failed: %v", err)
+	}
+	mux := http.NewServeMux()
+	(&API{Actor: actor}).Routes(mux)
+	handler := IdentityMiddleware(AuthMiddleware(auth, mux))
+	return func(who, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body)).WithContext(testCtx())
+		req.Header.Set("X-API-Key", who+"-key")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+}
+
+func TestAPI_UnverifiedActorHasNoAccount(t *testing.T) {
+	actor := NewToDoActor(nil)
+	mux := http.NewServeMux()
+	(&API{Actor: actor}).Routes(mux)
+	handler := IdentityMiddleware(mux)
+	serve := func(who, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body)).WithContext(testCtx())
+		req.Header.Set(IdentityHeader, who)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without -auth-file nobody may make themselves the first admin.
+	if w := serve("alice", http.MethodPost, "/users", `{"id":"alice"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for the first account from an unverified X-Actor, got %d: %s", w.Code, w.Body)
+	}
+	actor.CreateUser(as("cli:alice"), User{ID: "alice"})
+	actor.CreateItem(as("cli:alice"), ItemInput{Description: "Plan"})
+
+	// Naming the admin in X-Actor grants nothing, but is still recorded.
+	if w := serve("alice", http.MethodDelete, "/items/1", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a delete claimed by X-Actor, got %d", w.Code)
+	}
+	w := serve("alice", http.MethodPost, "/items", `{"description":"Other"}`)
+	var item Item
+	json.NewDecoder(w.Body).Decode(&item)
+	if item.CreatedBy != "" {
+		t.Errorf("expected an item created through X-Actor to have no owner, got %q", item.CreatedBy)
+	}
+	if changes, _ := actor.History(testCtx(), item.ID); len(changes) == 0 || changes[0].Actor != "alice" {
+		t.Errorf("expected X-Actor in the history, got %+v", changes)
+	}
+}
+
+func TestAPI_Users(t *testing.T) {
+	actor := NewToDoActor(nil)
+	serve := newUsersServer(t, actor)
+
+	w := serve("alice", http.MethodPost, "/users", `{"id":"alice"}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/users/alice" {
+		t.Fatalf("expected 201 with a Location, got %d %v: %s", w.Code, w.Header(), w.Body)
+	}
+	serve("alice", http.MethodPost, "/users", `{"id":"bob","name":"Bob"}`)
+	if w := serve("bob", http.MethodPost, "/users", `{"id":"mallory","admin":true}`); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a user added by a non-admin, got %d", w.Code)
+	}
+	if w := serve("alice", http.MethodPost, "/users", `{"id":"bob"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a taken ID, got %d", w.Code)
+	}
+	var users []User
+	json.NewDecoder(serve("bob", http.MethodGet, "/users", "").Body).Decode(&users)
+	if len(users) != 2 || users[1].Name != "Bob" {
+		t.Errorf("expected alice and bob, got %+v", users)
+	}
+
+	w = serve("alice", http.MethodPost, "/items", `{"description":"Plan","assignee_id":"bob"}`)
+	var item Item
+	json.NewDecoder(w.Body).Decode(&item)
+	if item.CreatedBy != "alice" || item.AssigneeID != "bob" {
+		t.Fatalf("expected alice's item assigned to bob, got %d %+v", w.Code, item)
+	}
+	if w := serve("alice", http.MethodPost, "/items", `{"description":"Plan","assignee_id":"zed"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an unknown assignee, got %d", w.Code)
+	}
+	var page []Item
+	json.NewDecoder(serve("bob", http.MethodGet, "/items?mine=true", "").Body).Decode(&page)
+	if len(page) != 1 || page[0].ID != item.ID {
+		t.Errorf("expected bob's assigned item, got %+v", page)
+	}
+	if w := serve("bob", http.MethodDelete, "/items/1", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 when the assignee deletes, got %d", w.Code)
+	}
+	if w := serve("alice", http.MethodDelete, "/users/bob", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+	if w := serve("alice", http.MethodDelete, "/users/bob", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a removed user, got %d", w.Code)
+	}
+}
+
+func TestAPI_UndoOfSomeoneElsesCreate(t *testing.T) {
+	actor := NewToDoActor(nil)
+	serve := newUsersServer(t, actor)
+	serve("alice", http.MethodPost, "/users", `{"id":"alice"}`)
+	serve("alice", http.MethodPost, "/users", `{"id":"bob"}`)
+	serve("alice", http.MethodPost, "/items", `{"description":"Plan"}`)
+
+	// Undoing a create deletes the item, which only its owner or an admin may do.
+	if w := serve("bob", http.MethodPost, "/undo", `{}`); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 when bob undoes alice's create, got %d: %s", w.Code, w.Body)
+	}
+	if items := actor.GetItems(); len(items) != 1 {
+		t.Fatalf("expected alice's item to stay, got %+v", items)
+	}
+	if w := serve("alice", http.MethodPost, "/undo", `{}`); w.Code != http.StatusOK {
+		t.Fatalf("expected alice to undo her own create, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve("bob", http.MethodPost, "/redo", `{}`); w.Code != http.StatusOK {
+		t.Errorf("expected bob to redo a create, got %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxTagLength is the longest tag NormalizeTags accepts.
+const MaxTagLength = 32
+
+// NormalizePriority trims and lower-cases “p” and checks it is one of the
+// Priority constants. The empty string (no priority) is allowed.
+func NormalizePriority(p string) (string, error) {
+	p = strings.ToLower(strings.TrimSpace(p))
+	switch p {
+	case "", PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent:
+		return p, nil
+	}
+	return "", &ValidationError{Field: "priority", Message: fmt.Sprintf("%q is not one of low, medium, high, urgent", p)}
+}
+
+// NormalizeTags lower-cases tags, turns inner whitespace into dashes, drops
+// empties and duplicates and sorts the result. Tags may contain letters,
+// digits and - _ : / . only.
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.Join(strings.Fields(strings.ToLower(t)), "-")
+		if t == "" || seen[t] {
+			continue
+		}
+		if len(t) > MaxTagLength {
+			return nil, &ValidationError{Field: "tags", Message: fmt.Sprintf("%q is longer than %d characters", t, MaxTagLength)}
+		}
+		for _, r := range t {
+			if !isTagRune(r) {
+				return nil, &ValidationError{Field: "tags", Message: fmt.Sprintf("%q contains %q", t, r)}
+			}
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_:/.", r)
+}
+
+// ParseDue parses a due date given either as RFC 3339 or as a plain
+// YYYY-MM-DD date (midnight local time). The empty string yields the zero time.
+func ParseDue(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, &ValidationError{Field: "due_at", Message: fmt.Sprintf("%q is neither RFC 3339 nor YYYY-MM-DD", s)}
+}
+
+// normalizeDescription trims “desc” and rejects it if nothing is left.
+func normalizeDescription(desc string) (string, error) {
+	desc = strings.TrimSpace(desc)
+	if desc == "" {
+		return "", &ValidationError{Field: "description", Message: "must not be empty"}
+	}
+	return desc, nil
+}
+
+// normalize validates and canonicalizes the fields of a new item.
+func (in ItemInput) normalize() (ItemInput, error) {
+	var err error
+	if in.Description, err = normalizeDescription(in.Description); err != nil {
+		return in, err
+	}
+	if in.Priority, err = NormalizePriority(in.Priority); err != nil {
+		return in, err
+	}
+	if in.Tags, err = NormalizeTags(in.Tags); err != nil {
+		return in, err
+	}
+	if in.Recurrence != nil {
+		if err = in.Recurrence.validate(); err != nil {
+			return in, err
+		}
+	}
+	if in.List != "" {
+		if in.List, err = normalizeListName(in.List); err != nil {
+			return in, err
+		}
+	}
+	in.AssigneeID = strings.ToLower(strings.TrimSpace(in.AssigneeID))
+	return in, nil
+}
+
+// normalize validates and canonicalizes the fields a patch sets.
+func (p ItemPatch) normalize() (ItemPatch, error) {
+	if p.Description != nil {
+		desc, err := normalizeDescription(*p.Description)
+		if err != nil {
+			return p, err
+		}
+		p.Description = &desc
+	}
+	if p.Priority != nil {
+		pr, err := NormalizePriority(*p.Priority)
+		if err != nil {
+			return p, err
+		}
+		p.Priority = &pr
+	}
+	if p.Tags != nil {
+		tags, err := NormalizeTags(*p.Tags)
+		if err != nil {
+			return p, err
+		}
+		p.Tags = &tags
+	}
+	if p.Recurrence != nil {
+		if err := p.Recurrence.validate(); err != nil {
+			return p, err
+		}
+	}
+	if p.AssigneeID != nil {
+		id := strings.ToLower(strings.TrimSpace(*p.AssigneeID))
+		p.AssigneeID = &id
+	}
+	return p, nil
+}
+
+// apply writes the fields set in “p” onto “it”.
+func (p ItemPatch) apply(it *Item) {
+	if p.Description != nil {
+		it.Description = *p.Description
+	}
+	if p.Status != nil {
+		it.Status = *p.Status
+	}
+	if p.Priority != nil {
+		it.Priority = *p.Priority
+	}
+	if p.DueAt != nil {
+		it.DueAt = *p.DueAt
+	}
+	if p.Tags != nil {
+		it.Tags = *p.Tags
+	}
+	if p.AssigneeID != nil {
+		it.AssigneeID = *p.AssigneeID
+	}
+	if p.Recurrence != nil {
+		it.Recurrence = nil
+		if p.Recurrence.Kind != "" {
+			r := *p.Recurrence
+			it.Recurrence = &r
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizePriority(t *testing.T) {
+	cases := map[string]string{
+		"":         "",
+		"low":      PriorityLow,
+		" High ":   PriorityHigh,
+		"URGENT":   PriorityUrgent,
+		"medium\n": PriorityMedium,
+	}
+	for in, want := range cases {
+		got, err := NormalizePriority(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizePriority(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	var ve *ValidationError
+	if _, err := NormalizePriority("whenever"); !errors.As(err, &ve) || ve.Field != "priority" {
+		t.Errorf("expected priority ValidationError, got %v", err)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := NormalizeTags([]string{" Home ", "errands", "home", "", "Big  Project", "café"})
+	if err != nil {
+		t.Fatalf("NormalizeTags failed: %v", err)
+	}
+	want := []string{"big-project", "café", "errands", "home"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeTags = %v, want %v", got, want)
+	}
+
+	if got, err := NormalizeTags([]string{" ", ""}); err != nil || got != nil {
+		t.Errorf("expected no tags, got %v, %v", got, err)
+	}
+	for _, bad := range []string{"no#hash", "x!", "this-tag-is-definitely-way-too-long-to-keep"} {
+		if _, err := NormalizeTags([]string{bad}); err == nil {
+			t.Errorf("expected error for tag %q", bad)
+		}
+	}
+}
+
+func TestParseDue(t *testing.T) {
+	d, err := ParseDue("2025-07-01")
+	if err != nil {
+		t.Fatalf("ParseDue date failed: %v", err)
+	}
+	if d.Year() != 2025 || d.Month() != time.July || d.Day() != 1 {
+		t.Errorf("unexpected date: %v", d)
+	}
+	if _, err := ParseDue("2025-07-01T09:30:00Z"); err != nil {
+		t.Errorf("ParseDue RFC 3339 failed: %v", err)
+	}
+	if d, err := ParseDue(""); err != nil || !d.IsZero() {
+		t.Errorf("expected zero time for empty input, got %v, %v", d, err)
+	}
+	if _, err := ParseDue("next tuesday"); err == nil {
+		t.Error("expected error for unparseable date")
+	}
+}
+
+func TestDescriptionIsRequired(t *testing.T) {
+	actor := NewToDoActor(nil)
+	serve := newTestMux(actor)
+
+	w := serve(http.MethodPost, "/items", `{"description":"  Buy milk \n"}`)
+	var item Item
+	if json.NewDecoder(w.Body).Decode(&item); w.Code != http.StatusCreated || item.Description != "Buy milk" {
+		t.Fatalf("expected the description trimmed, got %d %+v", w.Code, item)
+	}
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPost, "/items", `{"description":" \t"}`},
+		{http.MethodPost, "/items", `{}`},
+		{http.MethodPatch, "/items/1", `{"description":""}`},
+		{http.MethodPatch, "/items/1", `{"description":"   "}`},
+		{http.MethodPut, "/items/1", `{"description":"   "}`},
+		{http.MethodPost, "/batch", `{"ops":[{"op":"create","description":" "}]}`},
+	} {
+		if w := serve(req.method, req.path, req.body); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "description") {
+			t.Errorf("%s %s %s: expected 422 for the description, got %d %s", req.method, req.path, req.body, w.Code, w.Body)
+		}
+	}
+	if got, _ := actor.GetItem(1); got.Description != "Buy milk" || got.Version != 1 {
+		t.Errorf("expected the item untouched, got %+v", got)
+	}
+	// Leaving the description out of a patch keeps it.
+	if w := serve(http.MethodPatch, "/items/1", `{"status":"started"}`); w.Code != http.StatusOK {
+		t.Errorf("expected a patch without a description to succeed, got %d", w.Code)
+	}
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var eventTypes = []string{EventCreated, EventUpdated, EventDeleted}
+
+// Webhook is a URL that the item events it asks for are posted to, signed
+// with its secret; see Dispatcher.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events,omitempty"` // event types to send; none for all of them
+	Secret    string    `json:"secret,omitempty"` // HMAC-SHA256 key for the signature
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// wants reports whether events of type “typ” are sent to the webhook.
+func (h Webhook) wants(typ string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, typ)
+}
+
+// Redacted returns the webhook without its secret, for showing to anyone
+// but the one who registered it.
+func (h Webhook) Redacted() Webhook {
+	h.Secret = ""
+	return h
+}
+
+// WithWebhooks seeds the actor with the webhooks loaded from storage.
+func WithWebhooks(hooks []Webhook) ActorOption {
+	return func(a *ToDoActor) {
+		a.webhooks = hooks
+	}
+}
+
+// normalize checks a new webhook: an absolute http or https URL and known
+// event types. A missing secret is generated.
+func (h Webhook) normalize() (Webhook, error) {
+	h.URL = strings.TrimSpace(h.URL)
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return h, &ValidationError{Field: "url", Message: fmt.Sprintf("%q is not an http or https URL", h.URL)}
+	}
+	var events []string
+	for _, typ := range h.Events {
+		typ = strings.ToLower(strings.TrimSpace(typ))
+		if !slices.Contains(eventTypes, typ) {
+			return h, &ValidationError{Field: "events", Message: fmt.Sprintf("%q is not one of %s", typ, strings.Join(eventTypes, ", "))}
+		}
+		if !slices.Contains(events, typ) {
+			events = append(events, typ)
+		}
+	}
+	h.Events = events
+	if h.Secret == "" {
+		key := make([]byte, 32)
+		rand.Read(key)
+		h.Secret = hex.EncodeToString(key)
+	}
+	return h, nil
+}
+
+func putWebhook(hooks []Webhook, h Webhook) []Webhook {
+	for i := range hooks {
+		if hooks[i].ID == h.ID {
+			hooks[i] = h
+			return hooks
+		}
+	}
+	return append(hooks, h)
+}
+
+func removeWebhook(hooks []Webhook, id string) []Webhook {
+	return slices.DeleteFunc(hooks, func(h Webhook) bool { return h.ID == id })
+}
+
+type webhooksMsg struct {
+	reply chan []Webhook
+}
+type createWebhookMsg struct {
+	hook  Webhook
+	reply chan Webhook
+}
+type deleteWebhookMsg struct {
+	id    string
+	reply chan error
+}
+
+// Webhooks returns the registered webhooks, secrets included, oldest first.
+func (a *ToDoActor) Webhooks() []Webhook {
+	reply := make(chan []Webhook)
+	a.send(webhooksMsg{reply})
+	return <-reply
+}
+
+// CreateWebhook registers “h” and returns it with its ID, and its secret if
+// it had none.
+func (a *ToDoActor) CreateWebhook(ctx context.Context, h Webhook) (Webhook, error) {
+	h, err := h.normalize()
+	if err != nil {
+		return Webhook{}, err
+	}
+	h.ID = uuid.NewString()
+	h.CreatedAt = time.Now()
+	reply := make(chan Webhook)
+	a.send(createWebhookMsg{h, reply})
+	return <-reply, nil
+}
+
+// DeleteWebhook removes the webhook “id”, or fails with ErrWebhookNotFound.
+func (a *ToDoActor) DeleteWebhook(ctx context.Context, id string) error {
+	reply := make(chan error)
+	a.send(deleteWebhookMsg{strings.TrimSpace(id), reply})
+	return <-reply
+}
+
+// listWebhooks replies with a copy of the webhooks.
+func (s *actorState) listWebhooks(m webhooksMsg) {
+	m.reply <- slices.Clone(s.webhooks)
+}
+
+// createWebhook registers a webhook.
+func (s *actorState) createWebhook(m createWebhookMsg) {
+	s.webhooks = append(s.webhooks, m.hook)
+	s.a.changed(JournalEntry{Op: JournalWebhook, Webhook: &m.hook})
+	m.reply <- m.hook
+}
+
+// deleteWebhook removes a webhook.
+func (s *actorState) deleteWebhook(m deleteWebhookMsg) {
+	if !slices.ContainsFunc(s.webhooks, func(h Webhook) bool { return h.ID == m.id }) {
+		m.reply <- ErrWebhookNotFound
+		return
+	}
+	s.webhooks = removeWebhook(s.webhooks, m.id)
+	s.a.changed(JournalEntry{Op: JournalWebhookDelete, Webhook: &Webhook{ID: m.id}})
+	m.reply <- nil
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestToDoActor_Webhooks(t *testing.T) {
+	ctx := testCtx()
+	actor := NewToDoActor(nil)
+
+	var ve *ValidationError
+	for _, h := range []Webhook{
+		{URL: "ftp://example.com/hook"},
+		{URL: "/hook"},
+		{URL: "http://example.com/hook", Events: []string{"purged"}},
+	} {
+		if _, err := actor.CreateWebhook(ctx, h); !errors.As(err, &ve) {
+			t.Errorf("expected %+v to be invalid, got %v", h, err)
+		}
+	}
+
+	hook, err := actor.CreateWebhook(ctx, Webhook{URL: " http://example.com/hook ", Events: []string{"Created", "created", "deleted"}})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	if hook.ID == "" || hook.URL != "http://example.com/hook" || !slices.Equal(hook.Events, []string{EventCreated, EventDeleted}) || len(hook.Secret) != 64 {
+		t.Errorf("unexpected webhook %+v", hook)
+	}
+	if hook.wants(EventUpdated) || !hook.wants(EventDeleted) {
+		t.Errorf("expected %v to select the events sent", hook.Events)
+	}
+	other, _ := actor.CreateWebhook(ctx, Webhook{URL: "https://example.com/ci", Secret: "s3cret"})
+	if other.Secret != "s3cret" || !other.wants(EventUpdated) {
+		t.Errorf("expected the given secret and every event, got %+v", other)
+	}
+
+	if err := actor.DeleteWebhook(ctx, "nope"); !errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("expected ErrWebhookNotFound, got %v", err)
+	}
+	if err := actor.DeleteWebhook(ctx, hook.ID); err != nil {
+		t.Fatalf("DeleteWebhook failed: %v", err)
+	}
+	if hooks := actor.Webhooks(); len(hooks) != 1 || hooks[0].ID != other.ID {
+		t.Errorf("expected only the second webhook left, got %+v", hooks)
+	}
+}
+
+func TestSnapshot_WebhooksRoundTrip(t *testing.T) {
+	ctx := testCtx()
+	path := filepath.Join(t.TempDir(), "todos.json")
+	s := NewJSONFileStorage(path)
+	s.Journal = true
+
+	var changes []JournalEntry
+	actor := NewToDoActor(nil, WithChangeListener(func(e JournalEntry) { changes = append(changes, e) }))
+	kept, _ := actor.CreateWebhook(ctx, Webhook{URL: "http://example.com/bot", Events: []string{EventCreated}})
+	dropped, _ := actor.CreateWebhook(ctx, Webhook{URL: "http://example.com/ci"})
+	actor.DeleteWebhook(ctx, dropped.ID)
+	if err := s.Apply(ctx, changes...); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	snap, err := s.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(snap.Webhooks) != 1 || snap.Webhooks[0].ID != kept.ID || snap.Webhooks[0].Secret != kept.Secret {
+		t.Fatalf("expected the first webhook after replaying the journal, got %+v", snap.Webhooks)
+	}
+	// Saving the snapshot compacts the journal without losing them.
+	if err := s.Save(ctx, snap); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	snap, _ = s.Load(ctx)
+	reloaded := NewToDoActor(snap.Items, WithWebhooks(snap.Webhooks))
+	if hooks := reloaded.Webhooks(); len(hooks) != 1 || !slices.Equal(hooks[0].Events, kept.Events) {
+		t.Errorf("expected the webhook after a reload, got %+v", hooks)
+	}
+}
+
+func TestAPI_Webhooks(t *testing.T) {
+	actor := NewToDoActor(nil)
+	serve := newTestMux(actor)
+
+	w := serve(http.MethodPost, "/webhooks", `{"url":"http://example.com/hook","events":["updated"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var hook Webhook
+	json.NewDecoder(w.Body).Decode(&hook)
+	if hook.Secret == "" || w.Header().Get("Location") != "/webhooks/"+hook.ID {
+		t.Errorf("expected the secret and a Location, got %+v %v", hook, w.Header())
+	}
+
+	w = serve(http.MethodGet, "/webhooks", "")
+	var hooks []Webhook
+	json.NewDecoder(w.Body).Decode(&hooks)
+	if len(hooks) != 1 || hooks[0].ID != hook.ID || hooks[0].Secret != "" {
+		t.Errorf("expected the webhook without its secret, got %+v", hooks)
+	}
+
+	if w := serve(http.MethodPost, "/webhooks", `{"url":"example.com"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a URL without a scheme, got %d", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/webhooks/"+hook.ID, ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/webhooks/"+hook.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted webhook, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/webhooks/dead-letters", ""); w.Code != http.StatusOK || w.Body.String() != "[]\n" {
+		t.Errorf("expected no dead letters, got %d %s", w.Code, w.Body)
+	}
+}